@@ -0,0 +1,394 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubmodels"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+)
+
+// Editing files prints the error of each file to the server's stderr, so
+// requests none of whose files could be edited fail with this.
+var errSeeLog = errors.New("the operation failed; see the server log for details")
+
+// engineStatus is the status of a request whose engine operation failed
+// with err: the model's status when it cannot be used, else status.
+func engineStatus(err error, status int) int {
+	if errors.Is(err, engine.ErrModel) {
+		return modelStatus(err)
+	}
+	return status
+}
+
+// modelStatus is the status of a request whose model cannot be used: not
+// found when it does not exist or has not been pulled, unavailable when
+// its provider cannot be reached.
+func modelStatus(err error) int {
+	if errors.Is(err, models.ErrNotPulled) || errors.Is(err, githubmodels.ErrUnknownModel) {
+		return http.StatusNotFound
+	}
+	return http.StatusServiceUnavailable
+}
+
+// fileExists rejects requests for files the server cannot read or that
+// are outside its working directory.
+func fileExists(w http.ResponseWriter, r *http.Request, path string) bool {
+	if path == "" {
+		writeError(w, r, http.StatusBadRequest, errors.New("file is required"))
+		return false
+	}
+	if !inside(w, r, path) {
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, fs.ErrNotExist) {
+			status = http.StatusNotFound
+		}
+		writeError(w, r, status, err)
+		return false
+	}
+	return true
+}
+
+// inside rejects requests for paths outside the server's working
+// directory, after following symlinks. A path that does not exist is
+// checked through its directory.
+func inside(w http.ResponseWriter, r *http.Request, path string) bool {
+	if path == "" {
+		return true
+	}
+	if err := checkInside(path); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func checkInside(path string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(cwd)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		var dir string
+		if dir, err = filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+			resolved = filepath.Join(dir, filepath.Base(abs))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside the server's working directory", path)
+	}
+	return nil
+}
+
+// analyze classifies the server's working directory and returns the
+// result saved in .codeforge.json.
+func (s *Server) analyze(w http.ResponseWriter, r *http.Request) {
+	if !decode(w, r, &struct{}{}) {
+		return
+	}
+	if err := s.eng.RunAnalysis(); err != nil {
+		writeError(w, r, engineStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	root, _ := os.Getwd()
+	data, err := os.ReadFile(filepath.Join(root, ".codeforge.json"))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	var analysis interface{} = string(data)
+	if json.Valid(data) {
+		analysis = json.RawMessage(data)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"root": root, "analysis": analysis})
+}
+
+type explainRequest struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Symbol    string `json:"symbol"`
+	Blame     bool   `json:"blame"`
+	Depth     string `json:"depth"`
+	Format    string `json:"format"`
+}
+
+// explain explains a file, a line range or a symbol. JSON explanations
+// are returned as objects.
+func (s *Server) explain(w http.ResponseWriter, r *http.Request) {
+	req := explainRequest{Depth: engine.DepthWalkthrough, Format: engine.FormatMarkdown}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Symbol == "" && !fileExists(w, r, req.File) || !inside(w, r, req.File) {
+		return
+	}
+	explanation, err := s.eng.ExplainCode(r.Context(), req.File, engine.ExplainOptions{
+		StartLine: req.StartLine, EndLine: req.EndLine, Symbol: req.Symbol,
+		Blame: req.Blame, Depth: req.Depth, Format: req.Format,
+	})
+	if err != nil {
+		writeError(w, r, engineStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	var result interface{} = explanation
+	if req.Format == engine.FormatJSON {
+		result = json.RawMessage(explanation)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"explanation": result})
+}
+
+type suggestRequest struct {
+	File    string   `json:"file"`
+	Line    int      `json:"line"`
+	Col     int      `json:"col"`
+	Content string   `json:"content"`
+	Snippet []string `json:"snippet"`
+	Entire  bool     `json:"entire"`
+	FIM     bool     `json:"fim"`
+}
+
+// suggest returns a suggestion for a snippet or part of a file, or with
+// "fim" the completion at line and col of content (or the file).
+func (s *Server) suggest(w http.ResponseWriter, r *http.Request) {
+	var req suggestRequest
+	if !decode(w, r, &req) || !inside(w, r, req.File) {
+		return
+	}
+	if req.FIM {
+		if req.Line < 1 {
+			writeError(w, r, http.StatusBadRequest, errors.New("fim needs line"))
+			return
+		}
+		if req.Content == "" && !fileExists(w, r, req.File) {
+			return
+		}
+		completion, err := s.eng.Complete(r.Context(), req.File, req.Content, req.Line, max(req.Col, 1))
+		if err != nil {
+			writeError(w, r, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"suggestion": completion})
+		return
+	}
+
+	if len(req.Snippet) == 0 && req.Content != "" {
+		req.Snippet = strings.Split(req.Content, "\n")
+	}
+	if len(req.Snippet) == 0 && !fileExists(w, r, req.File) {
+		return
+	}
+	suggestion, err := s.eng.ProvideSuggestion(req.File, req.Line, req.Snippet, req.Entire)
+	if err != nil {
+		writeError(w, r, engineStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"suggestion": suggestion})
+}
+
+type editRequest struct {
+	Paths       []string `json:"paths"`
+	Prompt      string   `json:"prompt"`
+	Symbol      string   `json:"symbol"`
+	File        string   `json:"file"`
+	AllowIgnore bool     `json:"allow_ignore"`
+}
+
+type editResult struct {
+	File     string `json:"file"`
+	EditFile string `json:"edit_file"`
+	Content  string `json:"content"`
+}
+
+// edit edits files, directories or one symbol like the edit command,
+// which saves each result next to the original as a .codeforgedit file,
+// and returns those files.
+func (s *Server) edit(w http.ResponseWriter, r *http.Request) {
+	var req editRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Prompt == "" || req.Symbol == "" && len(req.Paths) == 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("edit needs a prompt and paths or a symbol"))
+		return
+	}
+	if !inside(w, r, req.File) {
+		return
+	}
+	if req.Symbol == "" {
+		for _, path := range req.Paths {
+			if !fileExists(w, r, path) {
+				return
+			}
+		}
+	}
+
+	if req.Symbol != "" {
+		editFile, err := s.eng.EditSymbol(req.Symbol, req.File, req.Prompt)
+		if err != nil {
+			writeError(w, r, engineStatus(err, http.StatusBadGateway), err)
+			return
+		}
+		data, err := os.ReadFile(editFile)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		file := strings.TrimSuffix(editFile, ".codeforgedit")
+		writeJSON(w, http.StatusOK, map[string]interface{}{"edits": []editResult{{file, editFile, string(data)}}})
+		return
+	}
+
+	start := time.Now()
+	if err := s.eng.EditFiles(req.Paths, req.Prompt, req.AllowIgnore); err != nil {
+		writeError(w, r, engineStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	// Files that failed or were skipped are left out.
+	edits := []editResult{}
+	for _, path := range req.Paths {
+		filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, ".codeforgedit") {
+				return nil
+			}
+			if info, err := d.Info(); err != nil || info.ModTime().Before(start) {
+				return nil
+			}
+			if data, err := os.ReadFile(p); err == nil {
+				edits = append(edits, editResult{strings.TrimSuffix(p, ".codeforgedit"), p, string(data)})
+			}
+			return nil
+		})
+		if info, err := os.Stat(path + ".codeforgedit"); err == nil && !info.ModTime().Before(start) {
+			data, _ := os.ReadFile(path + ".codeforgedit")
+			edits = append(edits, editResult{path, path + ".codeforgedit", string(data)})
+		}
+	}
+	if len(edits) == 0 {
+		writeError(w, r, http.StatusInternalServerError, errSeeLog)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"edits": edits})
+}
+
+// commitMessage writes a commit message for diff, or for the staged (else
+// unstaged) changes of the server's working directory.
+func (s *Server) commitMessage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Diff string `json:"diff"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Diff == "" {
+		diff, err := s.eng.GetGitDiff()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		req.Diff = diff
+	}
+	if strings.TrimSpace(req.Diff) == "" {
+		writeError(w, r, http.StatusBadRequest, errors.New("no changes to describe"))
+		return
+	}
+	message, err := s.eng.ProcessCommitMessage(req.Diff)
+	if err != nil {
+		writeError(w, r, engineStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+type chatRequest struct {
+	Messages []modeliface.Message `json:"messages"`
+	// Model is "general" (the default) or "code".
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// chat continues a conversation with the general or code model. With
+// "stream" the reply comes as server-sent events: {"delta": "..."} for
+// each chunk, then a "done" event with the whole reply, or an "error"
+// event.
+func (s *Server) chat(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Model == "" {
+		req.Model = "general"
+	}
+	if req.Model != "general" && req.Model != "code" {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("model must be general or code, not %q", req.Model))
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("messages is required"))
+		return
+	}
+	cfg, err := config.EnsureConfigPrompts("")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	model, err := models.GetModelFromConfig(&cfg, req.Model)
+	if err != nil {
+		writeError(w, r, modelStatus(err), err)
+		return
+	}
+	chatModel := model.(modeliface.StreamingChatModel)
+	opts := map[string]interface{}{"operation": "chat"}
+
+	if !req.Stream {
+		reply, err := chatModel.ChatStream(req.Messages, opts, nil)
+		if err != nil {
+			writeError(w, r, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"reply": reply, "usage": usageOf(model)})
+		return
+	}
+	events := newEventStream(w)
+	reply, err := chatModel.ChatStream(req.Messages, opts, func(chunk string) {
+		events.send("", map[string]string{"delta": chunk})
+	})
+	if err != nil {
+		events.send("error", map[string]string{"error": err.Error()})
+		return
+	}
+	events.send("done", map[string]interface{}{"reply": reply, "usage": usageOf(model)})
+}
+
+// usageOf returns the token counts of the model's last reply.
+func usageOf(model models.Model) modeliface.Usage {
+	if ur, ok := model.(modeliface.UsageReporter); ok {
+		return ur.LastUsage()
+	}
+	return modeliface.Usage{}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubmodels"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/ollama"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+)
+
+// chdir makes dir the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+func TestCheckInside(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	for _, dir := range []string{filepath.Join(root, "src"), filepath.Join(outside, "secret")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(root, "src", "main.go"), nil, 0o644)
+	os.WriteFile(filepath.Join(outside, "secret", "key"), nil, 0o644)
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "escape")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.Symlink(filepath.Join(root, "src"), filepath.Join(root, "alias"))
+	chdir(t, root)
+
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"src/main.go", true},
+		{"./src/../src/main.go", true},
+		{filepath.Join(root, "src", "main.go"), true},
+		{"alias/main.go", true},
+		{"src/new.go", true},
+		{"..dotted", true},
+		{".", true},
+		{"../", false},
+		{filepath.Join(outside, "secret", "key"), false},
+		{"escape/key", false},
+		{"escape/new", false},
+		{"src/../../" + filepath.Base(outside) + "/secret/key", false},
+	}
+	for _, tt := range tests {
+		err := checkInside(tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkInside(%q) = %v, want allowed %v", tt.path, err, tt.ok)
+		}
+	}
+}
+
+func TestPathsOutsideRejected(t *testing.T) {
+	chdir(t, t.TempDir())
+	outside := filepath.Join(t.TempDir(), "file.go")
+	os.WriteFile(outside, []byte("package x"), 0o644)
+	srv := NewServer(nil, "")
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/explain", `{"file": "` + outside + `"}`},
+		{"/explain", `{"file": "` + outside + `", "symbol": "X"}`},
+		{"/suggest", `{"file": "` + outside + `", "entire": true}`},
+		{"/suggest", `{"file": "` + outside + `", "fim": true, "line": 1, "content": "x"}`},
+		{"/edit", `{"paths": ["` + outside + `"], "prompt": "p"}`},
+		{"/edit", `{"symbol": "X", "file": "` + outside + `", "prompt": "p"}`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "outside the server's working directory") {
+			t.Errorf("POST %s %s = %d %s, want 400", tt.path, tt.body, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestModelStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: m", models.ErrNotPulled), http.StatusNotFound},
+		{fmt.Errorf("%w \"m\"", githubmodels.ErrUnknownModel), http.StatusNotFound},
+		{fmt.Errorf("%w; start it", ollama.ErrNotRunning), http.StatusServiceUnavailable},
+		{errors.New("not signed in"), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		if got := modelStatus(tt.err); got != tt.want {
+			t.Errorf("modelStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestEngineStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: %w", engine.ErrModel, fmt.Errorf("%w: m", models.ErrNotPulled)), http.StatusNotFound},
+		{fmt.Errorf("%w: %w", engine.ErrModel, ollama.ErrNotRunning), http.StatusServiceUnavailable},
+		{fmt.Errorf("error reading file: %w", fs.ErrNotExist), http.StatusInternalServerError},
+		{fmt.Errorf("error explaining code: %w", models.ErrNotPulled), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := engineStatus(tt.err, http.StatusInternalServerError); got != tt.want {
+			t.Errorf("engineStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
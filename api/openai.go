@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubcopilot"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+)
+
+// knownProviders are the prefixes accepted in "provider/model" names.
+var knownProviders = []string{"ollama", "githubmodels", "githubcopilot"}
+
+// completionRequest is the part of an OpenAI chat completion request the
+// proxy understands; other fields are ignored.
+type completionRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role string `json:"role"`
+		// Content is a string or an array of parts, of which only the
+		// text ones are kept.
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	Stream      bool     `json:"stream"`
+	Temperature *float64 `json:"temperature"`
+	MaxTokens   *int     `json:"max_tokens"`
+}
+
+// messages converts the request's messages, flattening content parts.
+func (req *completionRequest) messages() ([]modeliface.Message, error) {
+	var out []modeliface.Message
+	for _, m := range req.Messages {
+		var text string
+		if err := json.Unmarshal(m.Content, &text); err != nil {
+			var parts []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(m.Content, &parts); err != nil {
+				return nil, fmt.Errorf("unsupported content in %s message", m.Role)
+			}
+			var texts []string
+			for _, p := range parts {
+				if p.Type == "text" {
+					texts = append(texts, p.Text)
+				}
+			}
+			text = strings.Join(texts, "\n")
+		}
+		out = append(out, modeliface.Message{Role: m.Role, Content: text})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("messages is required")
+	}
+	return out, nil
+}
+
+// proxyModel returns the model named by a request. "", "general" and
+// "code" are the configured models, tried through the fallback chain;
+// "provider/model" is that model of one provider, and any other name is
+// a model of the default provider.
+func proxyModel(cfg *config.Config, name string) (models.Model, string, error) {
+	switch name {
+	case "", "general", "code":
+		if name == "" {
+			name = "general"
+		}
+		model, err := models.GetModelFromConfig(cfg, name)
+		return model, name, err
+	}
+	provider, modelName := proxyProvider(cfg, name)
+	model, err := models.NewModel(cfg, provider, modelName)
+	if err == nil {
+		err = models.Preflight(cfg, provider, modelName)
+	}
+	return model, name, err
+}
+
+// proxyProvider returns the provider a request for the model name is sent
+// to (first, for the configured models) and the model's name there.
+func proxyProvider(cfg *config.Config, name string) (string, string) {
+	if name == "" || name == "general" || name == "code" {
+		return cfg.Integrations.Default, ""
+	}
+	for _, p := range knownProviders {
+		if rest, ok := strings.CutPrefix(name, p+"/"); ok {
+			return p, rest
+		}
+	}
+	return cfg.Integrations.Default, name
+}
+
+// chatCompletions answers OpenAI chat completion requests, so existing
+// OpenAI clients can be pointed at the server. Requests go through the
+// same pipeline as the command line: redaction, caching, usage and audit
+// logging.
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req completionRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	messages, err := req.messages()
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	cfg, err := config.EnsureConfigPrompts("")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// Copilot Chat has no sampling settings; say so rather than ignore them.
+	sampling := req.Temperature != nil || req.MaxTokens != nil
+	if provider, _ := proxyProvider(&cfg, req.Model); sampling && provider == "githubcopilot" {
+		writeError(w, r, http.StatusBadRequest, githubcopilot.ErrSamplingUnsupported)
+		return
+	}
+	model, name, err := proxyModel(&cfg, req.Model)
+	if err != nil {
+		writeError(w, r, modelStatus(err), err)
+		return
+	}
+	chatModel, ok := model.(modeliface.StreamingChatModel)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("model %q cannot chat", name))
+		return
+	}
+	// Providers map these to their own settings, e.g. Ollama's num_predict.
+	opts := map[string]interface{}{"operation": "api_chat_completion"}
+	if req.Temperature != nil {
+		opts["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens != nil {
+		opts["max_tokens"] = *req.MaxTokens
+	}
+
+	id := completionID()
+	created := time.Now().Unix()
+	if !req.Stream {
+		reply, err := chatModel.ChatStream(messages, opts, nil)
+		if errors.Is(err, githubcopilot.ErrSamplingUnsupported) {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadGateway, err)
+			return
+		}
+		u := usageOf(model)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id": id, "object": "chat.completion", "created": created, "model": name,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": reply},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]int{"prompt_tokens": u.PromptTokens, "completion_tokens": u.CompletionTokens, "total_tokens": u.Total()},
+		})
+		return
+	}
+
+	events := newEventStream(w)
+	chunk := func(delta map[string]string, finish interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "object": "chat.completion.chunk", "created": created, "model": name,
+			"choices": []map[string]interface{}{{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+	}
+	events.send("", chunk(map[string]string{"role": "assistant"}, nil))
+	_, err = chatModel.ChatStream(messages, opts, func(s string) {
+		events.send("", chunk(map[string]string{"content": s}, nil))
+	})
+	if err != nil {
+		// The status is already sent: report the error in the stream.
+		events.send("", map[string]interface{}{"error": map[string]string{"message": err.Error(), "type": "server_error"}})
+		return
+	}
+	events.send("", chunk(map[string]string{}, "stop"))
+	events.send("", "[DONE]")
+}
+
+// listModels lists the names chatCompletions accepts for the configured
+// models.
+func (s *Server) listModels(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.EnsureConfigPrompts("")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	type entry struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	data := []entry{{"general", "model", "codeforgeai"}, {"code", "model", "codeforgeai"}}
+	seen := map[string]bool{}
+	for _, provider := range models.Providers(&cfg) {
+		for _, t := range []string{"general", "code"} {
+			name := models.ModelName(&cfg, provider, t)
+			id := provider + "/" + name
+			if name != "" && !seen[id] {
+				seen[id] = true
+				data = append(data, entry{id, "model", provider})
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data})
+}
+
+func completionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
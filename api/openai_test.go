@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+)
+
+func TestCopilotSamplingRejected(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	srv := NewServer(nil, "")
+	for _, body := range []string{
+		`{"model": "githubcopilot/gpt-4o", "temperature": 0.5, "messages": [{"role": "user", "content": "hi"}]}`,
+		`{"model": "githubcopilot/gpt-4o", "max_tokens": 10, "messages": [{"role": "user", "content": "hi"}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "does not support temperature or max_tokens") {
+			t.Errorf("%s = %d %s, want 400", body, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestProxyProvider(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Integrations.Default = "ollama"
+	tests := []struct {
+		name, provider, model string
+	}{
+		{"", "ollama", ""},
+		{"general", "ollama", ""},
+		{"code", "ollama", ""},
+		{"qwen2.5-coder:7b", "ollama", "qwen2.5-coder:7b"},
+		{"githubmodels/gpt-4o", "githubmodels", "gpt-4o"},
+		{"githubcopilot/claude", "githubcopilot", "claude"},
+		{"other/model", "ollama", "other/model"},
+	}
+	for _, tt := range tests {
+		provider, model := proxyProvider(cfg, tt.name)
+		if provider != tt.provider || model != tt.model {
+			t.Errorf("proxyProvider(%q) = %q, %q, want %q, %q", tt.name, provider, model, tt.provider, tt.model)
+		}
+	}
+}
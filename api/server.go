@@ -0,0 +1,157 @@
+// Package api serves the engine operations and an OpenAI-compatible chat
+// completions endpoint over HTTP, for dashboards and bots that should use
+// the same prompts and providers as the command line.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+)
+
+// maxBody caps request bodies; diffs and whole files fit comfortably.
+const maxBody = 16 << 20
+
+// Server routes requests to the engine and the configured models.
+type Server struct {
+	// Token, if set, must be sent as "Authorization: Bearer <token>" with
+	// every request except GET /health.
+	Token string
+	// Logger receives a line for each request; slog.Default() unless set.
+	Logger *slog.Logger
+
+	eng *engine.Engine
+	mux *http.ServeMux
+}
+
+func NewServer(eng *engine.Engine, token string) *Server {
+	s := &Server{Token: token, Logger: slog.Default(), eng: eng, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /health", s.health)
+	s.mux.HandleFunc("POST /analyze", s.analyze)
+	s.mux.HandleFunc("POST /explain", s.explain)
+	s.mux.HandleFunc("POST /suggest", s.suggest)
+	s.mux.HandleFunc("POST /edit", s.edit)
+	s.mux.HandleFunc("POST /commit-message", s.commitMessage)
+	s.mux.HandleFunc("POST /chat", s.chat)
+	s.mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.listModels)
+	return s
+}
+
+// ServeHTTP checks the token, serves the request and logs it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if s.authorized(r) {
+		s.mux.ServeHTTP(rec, r)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(rec, r, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+	}
+	s.Logger.Info("api request", "method", r.Method, "path", r.URL.Path, "status", rec.status,
+		"latency", time.Since(start).Round(time.Millisecond), "remote", r.RemoteAddr)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" || r.Method == http.MethodGet && r.URL.Path == "/health" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// statusRecorder remembers the status code for the request log. It
+// passes flushes through so event streams are not buffered.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decode reads the JSON body of r into v. An empty body leaves v as is.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError reports err as {"error": "..."}, or in the OpenAI format on
+// the /v1 endpoints so their clients can show it.
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		kind := "server_error"
+		switch status {
+		case http.StatusBadRequest, http.StatusNotFound:
+			kind = "invalid_request_error"
+		case http.StatusUnauthorized:
+			kind = "authentication_error"
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"error": map[string]string{"message": err.Error(), "type": kind},
+		})
+		return
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// eventStream starts a server-sent events response.
+type eventStream struct {
+	w http.ResponseWriter
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return &eventStream{w: w}
+}
+
+// send writes one event; an empty name is the default "message" event.
+func (e *eventStream) send(event string, data interface{}) {
+	if event != "" {
+		fmt.Fprintf(e.w, "event: %s\n", event)
+	}
+	if s, ok := data.(string); ok {
+		fmt.Fprintf(e.w, "data: %s\n\n", s)
+	} else {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(e.w, "data: %s\n\n", b)
+	}
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// Entry is one logged model request and its response. Prompts are stored
+// after redaction, so the log never holds the masked secrets.
+type Entry struct {
+	ID         string                 `json:"id"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Operation  string                 `json:"operation"`
+	Project    string                 `json:"project,omitempty"`
+	Provider   string                 `json:"provider"`
+	Model      string                 `json:"model"`
+	PromptHash string                 `json:"prompt_hash"`
+	Prompt     string                 `json:"prompt"`
+	Options    map[string]interface{} `json:"options,omitempty"`
+	Response   string                 `json:"response"`
+	Error      string                 `json:"error,omitempty"`
+	LatencyMS  int64                  `json:"latency_ms"`
+	Usage      modeliface.Usage       `json:"usage"`
+	ReplayOf   string                 `json:"replay_of,omitempty"`
+}
+
+var mu sync.Mutex
+
+// LogFile returns the path of the JSONL audit log.
+func LogFile() string {
+	return filepath.Join(config.DataDir(), "audit.jsonl")
+}
+
+// HashPrompt returns the hex SHA-256 of a prompt.
+func HashPrompt(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID returns a short random identifier for an entry.
+func NewID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Append writes an entry to the audit log, filling in ID, timestamp and
+// prompt hash when they are empty.
+func Append(e Entry) error {
+	if e.ID == "" {
+		e.ID = NewID()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	if e.PromptHash == "" {
+		e.PromptHash = HashPrompt(e.Prompt)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	f, err := os.OpenFile(LogFile(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// List returns logged entries, oldest first. Malformed lines are skipped.
+func List() ([]Entry, error) {
+	f, err := os.Open(LogFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Find returns the entry whose ID starts with id.
+func Find(id string) (*Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var found *Entry
+	for i := range entries {
+		if strings.HasPrefix(entries[i].ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("ambiguous log id: %s", id)
+			}
+			found = &entries[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no log entry with id %s", id)
+	}
+	return found, nil
+}
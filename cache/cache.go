@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/utils"
+)
+
+var disabled atomic.Bool
+
+// SetDisabled bypasses the cache for this process (the --no-cache flag).
+func SetDisabled(off bool) {
+	disabled.Store(off)
+}
+
+// Disabled reports whether the cache is bypassed for this process.
+func Disabled() bool {
+	return disabled.Load()
+}
+
+// Entry is a cached model response.
+type Entry struct {
+	Provider string           `json:"provider"`
+	Model    string           `json:"model"`
+	Response string           `json:"response"`
+	Usage    modeliface.Usage `json:"usage"`
+	Created  time.Time        `json:"created"`
+}
+
+// Stats summarizes the cache contents and hit rate.
+type Stats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+var statsMu sync.Mutex
+
+// Dir returns the cache directory.
+func Dir() string {
+	dir := filepath.Join(config.DataDir(), "cache")
+	os.MkdirAll(dir, 0700)
+	return dir
+}
+
+func statsFile() string {
+	return filepath.Join(config.DataDir(), "cache_stats.json")
+}
+
+// Key derives the content address of a request from everything that can
+// change the response.
+func Key(provider, model, prompt string, opts map[string]interface{}) string {
+	// encoding/json sorts map keys, so the encoding is canonical.
+	b, _ := json.Marshal(struct {
+		Provider string                 `json:"provider"`
+		Model    string                 `json:"model"`
+		Prompt   string                 `json:"prompt"`
+		Options  map[string]interface{} `json:"options"`
+	}{provider, model, prompt, opts})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the entry for key if present and younger than ttl.
+func Get(key string, ttl time.Duration) (*Entry, bool) {
+	path := filepath.Join(Dir(), key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		recordLookup(false)
+		return nil, false
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil || (ttl > 0 && time.Since(e.Created) > ttl) {
+		os.Remove(path)
+		recordLookup(false)
+		return nil, false
+	}
+	recordLookup(true)
+	return &e, true
+}
+
+// Put stores an entry and evicts the oldest entries beyond maxBytes.
+func Put(key string, e Entry, maxBytes int64) error {
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(Dir(), key+".json"), data, 0600); err != nil {
+		return err
+	}
+	if maxBytes > 0 {
+		return evict(maxBytes)
+	}
+	return nil
+}
+
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func listFiles() ([]cachedFile, error) {
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		return nil, err
+	}
+	var files []cachedFile
+	for _, de := range entries {
+		if !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{filepath.Join(Dir(), de.Name()), info.Size(), info.ModTime()})
+	}
+	return files, nil
+}
+
+// evict removes the oldest entries until the cache fits in maxBytes.
+func evict(maxBytes int64) error {
+	files, err := listFiles()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	if total <= maxBytes {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+	return nil
+}
+
+// Prune removes entries older than ttl and returns how many were removed.
+func Prune(ttl time.Duration) (int, error) {
+	files, err := listFiles()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, f := range files {
+		if time.Since(f.modTime) > ttl && os.Remove(f.path) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Clear deletes every cached entry and resets the hit counters.
+func Clear() (int, error) {
+	files, err := listFiles()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, f := range files {
+		if os.Remove(f.path) == nil {
+			removed++
+		}
+	}
+	os.Remove(statsFile())
+	return removed, nil
+}
+
+// GetStats returns the current cache size and hit counters.
+func GetStats() (Stats, error) {
+	s := loadCounters()
+	files, err := listFiles()
+	if err != nil {
+		return s, err
+	}
+	s.Entries = len(files)
+	for _, f := range files {
+		s.Bytes += f.size
+	}
+	return s, nil
+}
+
+func loadCounters() Stats {
+	var s Stats
+	data, err := os.ReadFile(statsFile())
+	if err == nil {
+		json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+func recordLookup(hit bool) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	s := loadCounters()
+	if hit {
+		s.Hits++
+	} else {
+		s.Misses++
+	}
+	if data, err := json.Marshal(Stats{Hits: s.Hits, Misses: s.Misses}); err == nil {
+		os.WriteFile(statsFile(), data, 0600)
+	}
+}
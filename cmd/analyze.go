@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	// "log"
 
@@ -44,7 +45,10 @@ var analyzeCmd = &cobra.Command{
 			}
 		}
 
-		eng.RunAnalysis()
+		if err := eng.RunAnalysis(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
 
 		fmt.Println("🔍 Analysis Results:")
 		if mcpContext != "" {
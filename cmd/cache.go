@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/cache"
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and clear the model response cache",
+	Long: `Deterministic model calls (an explicit temperature of 0) are cached
+under ~/.codeforgeai/cache, keyed on provider, model, prompt and options.
+With Ollama, explanations, commit messages, gitmoji and directory
+classification default to it (see "operations" in the ollama config
+section). Other calls, including those with other providers, are only
+cached with "force": true in the cache config section.
+Use --no-cache to bypass it for a single run.`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache size and hit rate",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		cfg, _ := config.EnsureConfigPrompts("")
+		s, err := cache.GetStats()
+		if err != nil {
+			fmt.Println("Error reading cache:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(s, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		state := "enabled"
+		if !cfg.Cache.Enabled {
+			state = "disabled"
+		}
+		fmt.Printf("Cache:     %s (%s)\n", state, cache.Dir())
+		fmt.Printf("Entries:   %d\n", s.Entries)
+		fmt.Printf("Size:      %.1f KiB of %d MiB\n", float64(s.Bytes)/1024, cfg.Cache.MaxSizeMB)
+		fmt.Printf("TTL:       %s\n", time.Duration(cfg.Cache.TTLMinutes)*time.Minute)
+		lookups := s.Hits + s.Misses
+		if lookups > 0 {
+			fmt.Printf("Hit rate:  %d/%d (%.0f%%)\n", s.Hits, lookups, float64(s.Hits)*100/float64(lookups))
+		} else {
+			fmt.Println("Hit rate:  no lookups yet")
+		}
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete cached responses",
+	Run: func(cmd *cobra.Command, args []string) {
+		expired, _ := cmd.Flags().GetBool("expired")
+		var (
+			n   int
+			err error
+		)
+		if expired {
+			cfg, _ := config.EnsureConfigPrompts("")
+			n, err = cache.Prune(time.Duration(cfg.Cache.TTLMinutes) * time.Minute)
+		} else {
+			n, err = cache.Clear()
+		}
+		if err != nil {
+			fmt.Println("Error clearing cache:", err)
+			return
+		}
+		fmt.Printf("Removed %d cached response(s).\n", n)
+	},
+}
+
+func init() {
+	cacheStatsCmd.Flags().Bool("json", false, "Print stats as JSON")
+	cacheClearCmd.Flags().Bool("expired", false, "Only remove entries older than the configured TTL")
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
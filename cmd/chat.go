@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/conversation"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/codeforge-ide/codeforgeai.go/parser"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const chatHelp = `Commands:
+  /file PATH...          attach files to the conversation
+  /symbol NAME...        attach functions, classes or methods by name, e.g. Client.fetch
+  /diff                  attach the current git diff
+  /model [PROVIDER] NAME switch model (no argument shows the current one)
+  /save [PATH]           save the session, or export it as a JSON/YAML/markdown transcript
+  /clear                 forget the conversation, keeping the system prompt
+  /tokens                show the size of the conversation and the tokens used
+  /compact               summarize older messages now to free up context
+  /uncompact             undo every compaction, restoring the full history
+  /help                  show this help
+  /exit                  save and quit (also Ctrl-D)
+End a line with \ to continue on the next line.`
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat interactively with the general model",
+	Long: `Starts an interactive chat. Replies are streamed as they are generated, and
+the session is saved after every reply under ~/.codeforgeai/chats so it can
+be continued later with --resume. When the conversation outgrows the
+"context" budget in the config, older messages are summarized with the
+general model; attached files and the system prompt are kept verbatim.
+
+` + chatHelp,
+	Run: func(cmd *cobra.Command, args []string) {
+		resume, _ := cmd.Flags().GetString("resume")
+		provider, _ := cmd.Flags().GetString("provider")
+		model, _ := cmd.Flags().GetString("model")
+		system, _ := cmd.Flags().GetString("system")
+
+		cfg, _ := config.EnsureConfigPrompts("")
+		var s *conversation.Session
+		if resume != "" {
+			var err error
+			if s, err = conversation.LoadSession(resume); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if provider != "" {
+				s.Provider = provider
+			}
+			if model != "" {
+				s.Model = model
+			}
+		} else {
+			if provider == "" {
+				provider = cfg.Integrations.Default
+			}
+			if model == "" {
+				model = models.ModelName(&cfg, provider, "general")
+			}
+			s = conversation.NewSession(provider, model)
+			if system != "" {
+				s.Append("system", system)
+			}
+		}
+		m, err := openChatModel(&cfg, s.Provider, s.Model)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		r := &chatREPL{cfg: &cfg, session: s, model: m, in: bufio.NewReader(os.Stdin), out: os.Stdout,
+			projectMap: repomap.Context(&cfg, config.ProjectRoot())}
+		r.run()
+	},
+}
+
+var chatListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved chat sessions",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		sessions, err := conversation.ListSessions()
+		if err != nil {
+			fmt.Println("Error listing chat sessions:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(sessions, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No saved chat sessions.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tMODEL\tTITLE")
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s/%s\t%s\n", s.ID, s.Updated.Format("2006-01-02 15:04"), len(s.Messages), s.Provider, s.Model, s.Title)
+		}
+		w.Flush()
+	},
+}
+
+// openChatModel returns a ready model of provider that can take a whole
+// conversation.
+func openChatModel(cfg *config.Config, provider, model string) (modeliface.StreamingChatModel, error) {
+	m, err := models.NewModel(cfg, provider, model)
+	if err != nil {
+		return nil, err
+	}
+	if err := models.Preflight(cfg, provider, model); err != nil {
+		return nil, err
+	}
+	sm, ok := m.(modeliface.StreamingChatModel)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support chat", provider)
+	}
+	return sm, nil
+}
+
+// chatREPL is the state of an interactive chat.
+type chatREPL struct {
+	cfg     *config.Config
+	session *conversation.Session
+	model   modeliface.StreamingChatModel
+	in      *bufio.Reader
+	out     io.Writer
+	last    modeliface.Usage
+	// projectMap is the repo map, built once so every request starts the
+	// same way.
+	projectMap string
+}
+
+func (r *chatREPL) run() {
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	s := r.session
+	if interactive {
+		fmt.Fprintf(r.out, "Chatting with %s/%s (session %s). Type /help for commands.\n", s.Provider, s.Model, s.ID)
+		if n := len(s.Messages); n > 0 {
+			fmt.Fprintf(r.out, "Resumed %d message(s).\n", n)
+		}
+	}
+	for {
+		if interactive {
+			fmt.Fprint(r.out, "\n> ")
+		}
+		line, err := r.readInput()
+		if err != nil {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			if !r.command(line) {
+				break
+			}
+			continue
+		}
+		r.send(line)
+	}
+	if len(s.Messages) > 0 {
+		if err := s.Save(); err != nil {
+			fmt.Println("Error saving chat session:", err)
+			return
+		}
+		if interactive {
+			fmt.Fprintf(r.out, "\nSession saved; continue it with 'codeforgeai chat --resume %s'.\n", s.ID)
+		}
+	}
+}
+
+// readInput reads one message, joining lines that end in a backslash.
+func (r *chatREPL) readInput() (string, error) {
+	var lines []string
+	for {
+		line, err := r.in.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			if len(lines) > 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasSuffix(line, "\\") {
+			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		lines = append(lines, line)
+		return strings.TrimSpace(strings.Join(lines, "\n")), nil
+	}
+}
+
+// send adds a user message, streams the reply and saves the session.
+func (r *chatREPL) send(text string) {
+	s := r.session
+	s.Append("user", text)
+	if err := compactTranscript(r.cfg, &s.Transcript, false); err != nil {
+		fmt.Fprintln(r.out, "Warning: could not compact the conversation:", err)
+	}
+	messages := s.Active()
+	if related := index.Context(r.cfg, text, ""); related != "" {
+		// Retrieved code is sent with the question but not saved.
+		last := len(messages) - 1
+		messages[last].Content = related + "\n\n" + messages[last].Content
+	}
+	if r.projectMap != "" {
+		// So is the repo map, ahead of the first user message.
+		for i := range messages {
+			if messages[i].Role == "user" {
+				messages[i].Content = r.projectMap + "\n\n" + messages[i].Content
+				break
+			}
+		}
+	}
+	reply, err := r.model.ChatStream(messages, map[string]interface{}{"operation": "chat"}, func(chunk string) {
+		fmt.Fprint(r.out, chunk)
+	})
+	fmt.Fprintln(r.out)
+	if err != nil {
+		s.Messages = s.Messages[:len(s.Messages)-1]
+		fmt.Fprintln(r.out, "Error:", err)
+		return
+	}
+	msg := s.Append("assistant", reply)
+	if ur, ok := r.model.(modeliface.UsageReporter); ok {
+		r.last = ur.LastUsage()
+		s.Usage.PromptTokens += r.last.PromptTokens
+		s.Usage.CompletionTokens += r.last.CompletionTokens
+		if r.last.CompletionTokens > 0 {
+			msg.Tokens = r.last.CompletionTokens
+		}
+	}
+	if err := s.Save(); err != nil {
+		fmt.Fprintln(r.out, "Error saving chat session:", err)
+	}
+}
+
+// command runs a slash command and reports whether the chat continues.
+func (r *chatREPL) command(line string) bool {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+	s := r.session
+	switch name {
+	case "/exit", "/quit":
+		return false
+	case "/help":
+		fmt.Fprintln(r.out, chatHelp)
+	case "/file":
+		if len(args) == 0 {
+			fmt.Fprintln(r.out, "Usage: /file PATH...")
+		}
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(r.out, "Error:", err)
+				continue
+			}
+			s.Append("user", fmt.Sprintf("Attached file %s:\n```\n%s\n```", path, strings.TrimRight(string(data), "\n"))).Pinned = true
+			fmt.Fprintf(r.out, "Attached %s (~%d tokens)\n", path, conversation.EstimateTokens(string(data)))
+		}
+	case "/symbol":
+		if len(args) == 0 {
+			fmt.Fprintln(r.out, "Usage: /symbol NAME...")
+		}
+		for _, name := range args {
+			target, err := parser.Resolve(config.ProjectRoot(), name, "")
+			if err != nil {
+				fmt.Fprintln(r.out, "Error:", err)
+				continue
+			}
+			source, err := target.Source()
+			if err != nil {
+				fmt.Fprintln(r.out, "Error:", err)
+				continue
+			}
+			s.Append("user", fmt.Sprintf("Attached %s from %s:\n```\n%s\n```", target.ID, target.File, source)).Pinned = true
+			fmt.Fprintf(r.out, "Attached %s (~%d tokens)\n", target.ID, conversation.EstimateTokens(source))
+		}
+	case "/diff":
+		diff, err := workingTreeDiff()
+		if err != nil {
+			fmt.Fprintln(r.out, "Error:", err)
+			break
+		}
+		if strings.TrimSpace(diff) == "" {
+			fmt.Fprintln(r.out, "No changes to attach.")
+			break
+		}
+		s.Append("user", "Current git diff:\n```diff\n"+strings.TrimRight(diff, "\n")+"\n```").Pinned = true
+		fmt.Fprintf(r.out, "Attached git diff (~%d tokens)\n", conversation.EstimateTokens(diff))
+	case "/model":
+		if len(args) == 0 {
+			fmt.Fprintf(r.out, "Using %s/%s\n", s.Provider, s.Model)
+			break
+		}
+		provider, model := s.Provider, args[0]
+		if len(args) > 1 {
+			provider, model = args[0], args[1]
+		}
+		m, err := openChatModel(r.cfg, provider, model)
+		if err != nil {
+			fmt.Fprintln(r.out, "Error:", err)
+			break
+		}
+		r.model, s.Provider, s.Model = m, provider, model
+		fmt.Fprintf(r.out, "Switched to %s/%s\n", provider, model)
+	case "/save":
+		if len(args) > 0 {
+			if err := s.Transcript.Save(args[0], conversation.FormatForPath(args[0])); err != nil {
+				fmt.Fprintln(r.out, "Error:", err)
+				break
+			}
+			fmt.Fprintf(r.out, "Transcript written to %s\n", args[0])
+			break
+		}
+		if err := s.Save(); err != nil {
+			fmt.Fprintln(r.out, "Error saving chat session:", err)
+			break
+		}
+		fmt.Fprintf(r.out, "Saved session %s\n", s.ID)
+	case "/clear":
+		var kept []conversation.Message
+		for _, m := range s.Messages {
+			if m.Role == "system" && !m.Summary {
+				kept = append(kept, m)
+			}
+		}
+		s.Messages = kept
+		fmt.Fprintln(r.out, "Conversation cleared.")
+	case "/compact":
+		before := len(s.Active())
+		if err := compactTranscript(r.cfg, &s.Transcript, true); err != nil {
+			fmt.Fprintln(r.out, "Error:", err)
+			break
+		}
+		if len(s.Active()) == before {
+			fmt.Fprintln(r.out, "Nothing to compact yet.")
+		}
+	case "/uncompact":
+		fmt.Fprintf(r.out, "Restored %d compacted message(s).\n", s.Uncompact())
+	case "/tokens":
+		total := s.Tokens()
+		byRole := map[string]int{}
+		pinned, compacted := 0, 0
+		for _, m := range s.Messages {
+			if m.Compacted {
+				compacted++
+				continue
+			}
+			byRole[m.Role] += m.Tokens
+			if m.IsPinned() {
+				pinned++
+			}
+		}
+		fmt.Fprintf(r.out, "Conversation: %d active message(s), ~%d tokens (system %d, user %d, assistant %d)\n",
+			len(s.Messages)-compacted, total, byRole["system"], byRole["user"], byRole["assistant"])
+		fmt.Fprintf(r.out, "Context:      %d pinned, %d compacted; compacts above ~%d of %d tokens\n",
+			pinned, compacted, int(float64(r.cfg.Context.MaxTokens)*r.cfg.Context.CompactAt), r.cfg.Context.MaxTokens)
+		fmt.Fprintf(r.out, "Last reply:   %d prompt + %d completion tokens\n", r.last.PromptTokens, r.last.CompletionTokens)
+		fmt.Fprintf(r.out, "Session:      %d prompt + %d completion tokens\n", s.Usage.PromptTokens, s.Usage.CompletionTokens)
+	default:
+		fmt.Fprintf(r.out, "Unknown command %s; type /help for the list.\n", name)
+	}
+	return true
+}
+
+// workingTreeDiff returns the staged and unstaged changes against HEAD, or
+// all changes in a repository without commits.
+func workingTreeDiff() (string, error) {
+	out, err := exec.Command("git", "diff", "HEAD").Output()
+	if err != nil {
+		out, err = exec.Command("git", "diff").Output()
+		if err != nil {
+			return "", fmt.Errorf("error getting git diff: %w", err)
+		}
+	}
+	return string(out), nil
+}
+
+func init() {
+	chatCmd.Flags().String("resume", "", "Continue a saved session by ID (or \"last\")")
+	chatCmd.Flags().String("provider", "", "Provider to chat with (default: integrations.default)")
+	chatCmd.Flags().String("model", "", "Model to chat with (default: the provider's general model)")
+	chatCmd.Flags().String("system", "", "System prompt for a new session")
+	chatListCmd.Flags().Bool("json", false, "Output as JSON")
+	chatCmd.AddCommand(chatListCmd)
+	rootCmd.AddCommand(chatCmd)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubcopilot"
+)
+
+// startCopilot starts the Copilot language server of the config for one
+// command. The caller closes it.
+func startCopilot(ctx context.Context) (*githubcopilot.Client, error) {
+	cfg, _ := config.EnsureConfigPrompts("")
+	if err := httpclient.CheckURL("github copilot", githubcopilot.APIURL); err != nil {
+		return nil, err
+	}
+	return githubcopilot.Start(ctx, cfg.Copilot.Command)
+}
+
+func copilotLogin() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := startCopilot(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	prompt, err := client.SignInInitiate(ctx)
+	if err != nil {
+		return err
+	}
+	if prompt.Status == "AlreadySignedIn" {
+		fmt.Println("Already signed in to GitHub Copilot as", prompt.User+".")
+		return nil
+	}
+	fmt.Printf("Open %s and enter the code %s\n", prompt.VerificationURI, prompt.UserCode)
+	fmt.Println("Waiting for authorization...")
+	expires := time.Duration(prompt.ExpiresIn) * time.Second
+	if expires == 0 {
+		expires = 15 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, expires)
+	defer cancel()
+	status, err := client.SignInConfirm(ctx, prompt.UserCode)
+	if err != nil {
+		return err
+	}
+	if !status.SignedIn() {
+		return fmt.Errorf("sign-in did not complete: %s", status.Status)
+	}
+	fmt.Println("Signed in to GitHub Copilot as", status.User+".")
+	return nil
+}
+
+func copilotLogout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := startCopilot(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.SignOut(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Signed out of GitHub Copilot.")
+	return nil
+}
+
+func copilotStatus() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := startCopilot(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	status, err := client.CheckStatus(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Language server: %s %s\n", client.ServerInfo.Name, client.ServerInfo.Version)
+	switch {
+	case status.SignedIn():
+		fmt.Println("Signed in as", status.User)
+	case status.Status == "NotAuthorized":
+		fmt.Println("Signed in as", status.User, "without an active Copilot subscription")
+	default:
+		fmt.Println("Not signed in; run 'codeforgeai github copilot login'")
+	}
+	return nil
+}
+
+// copilotInstall installs the language server with npm unless the
+// configured command is already on PATH.
+func copilotInstall() error {
+	cfg, _ := config.EnsureConfigPrompts("")
+	command := cfg.Copilot.Command
+	if len(command) == 0 {
+		command = githubcopilot.DefaultCommand
+	}
+	if path, err := exec.LookPath(command[0]); err == nil {
+		fmt.Println("Copilot language server already installed:", path)
+		return nil
+	}
+	if _, err := exec.LookPath("npm"); err != nil {
+		return fmt.Errorf("npm not found; install Node.js, then run 'npm install -g %s'", githubcopilot.Package)
+	}
+	fmt.Println("Installing", githubcopilot.Package, "with npm...")
+	install := exec.Command("npm", "install", "-g", githubcopilot.Package)
+	install.Stdout, install.Stderr = os.Stdout, os.Stderr
+	if err := install.Run(); err != nil {
+		return fmt.Errorf("npm install failed: %w", err)
+	}
+	fmt.Println("Installed. Sign in with 'codeforgeai github copilot login'.")
+	return nil
+}
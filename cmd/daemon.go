@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/daemon"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/spf13/cobra"
+)
+
+var noDaemon bool
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep the config, project state and models warm for other commands",
+	Long: `Runs in the foreground until stopped, answering other codeforgeai commands
+on a Unix socket in the data directory. It keeps the parsed config (reloaded
+when the file changes), the directory tree, repo map and index of each
+project it is asked about (refreshed when files change), and the general and
+code models set up with their provider checked. The index is updated on the
+next search, or with --reindex as soon as files change.
+
+Commands use the daemon automatically when it is running, and do the work
+themselves when it is not, or with --no-daemon.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		poll, _ := cmd.Flags().GetDuration("poll")
+		reindex, _ := cmd.Flags().GetBool("reindex")
+		if daemon.Running() {
+			fmt.Println("Daemon is already running. Use 'codeforgeai daemon stop' first to restart it.")
+			return
+		}
+		// Commands are told about missing models rather than the daemon
+		// asking on its own terminal.
+		models.SetInteractive(false)
+		srv := daemon.NewServer(poll, reindex)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			srv.Close()
+		}()
+		fmt.Fprintf(os.Stderr, "Daemon listening on %s\n", daemon.SocketPath())
+		if err := srv.Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error running daemon:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running and what it keeps",
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := daemon.Dial()
+		if err != nil {
+			fmt.Println("Not running:", err)
+			return
+		}
+		defer c.Close()
+		status, err := c.Status()
+		if err != nil {
+			fmt.Println("Not running:", err)
+			return
+		}
+		fmt.Printf("Running (pid %d, socket %s)\n", status.PID, daemon.SocketPath())
+		fmt.Printf("Uptime: %s, requests: %d\n", time.Since(status.Started).Round(time.Second), status.Requests)
+		models := "none"
+		if len(status.Models) > 0 {
+			models = strings.Join(status.Models, ", ")
+		}
+		fmt.Println("Warm models:", models)
+		if len(status.Projects) == 0 {
+			fmt.Println("Projects: none")
+			return
+		}
+		fmt.Println("Projects:")
+		for _, p := range status.Projects {
+			indexed := "not indexed"
+			if p.Indexed {
+				indexed = "indexed"
+			}
+			fmt.Printf("  %s (%d files, %s, refreshed %s ago)\n", p.Root, p.Files, indexed, time.Since(p.Changed).Round(time.Second))
+		}
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := daemon.Dial()
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer c.Close()
+		if err := c.Shutdown(); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("Daemon stopped.")
+	},
+}
+
+// connectDaemon hands the work of this run to the daemon when one is
+// running, unless the daemon was disabled for it.
+func connectDaemon(cmd *cobra.Command) {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == daemonCmd {
+			return
+		}
+	}
+	if c := daemon.Connect(); c != nil {
+		c.Install()
+	}
+}
+
+func init() {
+	daemonCmd.Flags().Duration("poll", daemon.DefaultPoll, "How often to check project files for changes")
+	daemonCmd.Flags().Bool("reindex", false, "Update the search index, re-embedding changed files, as soon as files change rather than on the next search")
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build or update the project's code search index",
+	Long: `Chunks the project's files (the useful files from 'analyze', or every file
+not ignored by .gitignore), embeds them with the "index" provider and model
+from the config and stores the vectors under ~/.codeforgeai/index. Only
+files that changed since the last run are embedded again.
+
+Once a project is indexed, explain, suggestion, edit and chat add the most
+relevant chunks to their prompts; pass --no-context to skip that.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rebuild, _ := cmd.Flags().GetBool("rebuild")
+		cfg, _ := config.EnsureConfigPrompts("")
+		root := config.ProjectRoot()
+		ix, stats, err := index.Update(&cfg, root, rebuild, indexProgress())
+		if err != nil {
+			fmt.Println("Error indexing project:", err)
+			if ix == nil {
+				return
+			}
+		}
+		fmt.Printf("Indexed %s: %d file(s), %d chunk(s) (%d added, %d changed, %d removed, %d unchanged)\n",
+			root, stats.Files, ix.Chunks(), stats.Added, stats.Changed, stats.Removed, stats.Unchanged)
+	},
+}
+
+var indexStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show what the project index holds",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		root := config.ProjectRoot()
+		ix, err := index.Load(root)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(map[string]interface{}{
+				"root": ix.Root, "provider": ix.Provider, "model": ix.Model,
+				"updated": ix.Updated, "files": len(ix.Files), "chunks": ix.Chunks(), "path": index.Path(root),
+			}, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		fmt.Printf("Project:   %s\n", ix.Root)
+		fmt.Printf("Embedding: %s/%s\n", ix.Provider, ix.Model)
+		fmt.Printf("Files:     %d\n", len(ix.Files))
+		fmt.Printf("Chunks:    %d\n", ix.Chunks())
+		fmt.Printf("Updated:   %s\n", ix.Updated.Format("2006-01-02 15:04"))
+		fmt.Printf("Stored in: %s\n", index.Path(root))
+	},
+}
+
+var indexClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the project index",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := index.Clear(config.ProjectRoot()); err != nil {
+			fmt.Println("Error clearing index:", err)
+			return
+		}
+		fmt.Println("Project index removed.")
+	},
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search the project semantically using the code index",
+	Long: `Embeds the query and prints the most similar chunks of the project. The
+index is brought up to date first (see 'codeforgeai index').`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		k, _ := cmd.Flags().GetInt("top")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		noUpdate, _ := cmd.Flags().GetBool("no-update")
+		cfg, _ := config.EnsureConfigPrompts("")
+		root := config.ProjectRoot()
+
+		var ix *index.Index
+		var err error
+		if noUpdate {
+			ix, err = index.Load(root)
+		} else {
+			ix, _, err = index.Update(&cfg, root, false, indexProgress())
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			if ix == nil || errors.Is(err, index.ErrNoIndex) {
+				return
+			}
+		}
+		results, err := index.Query(&cfg, ix, strings.Join(args, " "), k, 0, "")
+		if err != nil {
+			fmt.Println("Error searching index:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(results, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		if len(results) == 0 {
+			fmt.Println("No matches.")
+			return
+		}
+		for _, r := range results {
+			fmt.Printf("%s:%d-%d  (score %.3f)\n", r.Path, r.StartLine, r.EndLine, r.Score)
+			for _, line := range strings.Split(previewLines(r.Text, 6), "\n") {
+				fmt.Println("    " + line)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+// indexProgress reports embedding progress on stderr when it is a
+// terminal.
+func indexProgress() func(done, total int) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rEmbedding chunks: %d/%d", done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// previewLines returns the first n non-blank lines of text.
+func previewLines(text string, n int) string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(out) == n {
+			out = append(out, "...")
+			break
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func init() {
+	indexCmd.Flags().Bool("rebuild", false, "Re-embed every file instead of only changed ones")
+	indexStatusCmd.Flags().Bool("json", false, "Output as JSON")
+	indexCmd.AddCommand(indexStatusCmd)
+	indexCmd.AddCommand(indexClearCmd)
+	rootCmd.AddCommand(indexCmd)
+
+	searchCmd.Flags().IntP("top", "k", 5, "Number of results")
+	searchCmd.Flags().Bool("json", false, "Output as JSON")
+	searchCmd.Flags().Bool("no-update", false, "Search the index as is, without updating it first")
+	rootCmd.AddCommand(searchCmd)
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codeforge-ide/codeforgeai.go/audit"
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/spf13/cobra"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Inspect and replay the prompt/response audit log",
+	Long: `The audit log records every model request (redacted prompt, response, latency
+and token usage) to ~/.codeforgeai/audit.jsonl when "audit_log" is enabled.`,
+}
+
+var logListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List logged model requests, newest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		entries, err := audit.List()
+		if err != nil {
+			fmt.Println("Error reading audit log:", err)
+			return
+		}
+		if len(entries) == 0 {
+			fmt.Println("Audit log is empty. Enable it with 'codeforgeai log enable'.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tOPERATION\tPROVIDER/MODEL\tLATENCY\tTOKENS\tSTATUS")
+		for i, shown := len(entries)-1, 0; i >= 0 && (limit <= 0 || shown < limit); i, shown = i-1, shown+1 {
+			e := entries[i]
+			status := "ok"
+			if e.Error != "" {
+				status = "error"
+			}
+			if e.ReplayOf != "" {
+				status += " (replay of " + e.ReplayOf + ")"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%dms\t%d\t%s\n",
+				e.ID, e.Timestamp.Local().Format("2006-01-02 15:04:05"), e.Operation,
+				e.Provider, e.Model, e.LatencyMS, e.Usage.Total(), status)
+		}
+		w.Flush()
+	},
+}
+
+var logShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show a logged request and response",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		e, err := audit.Find(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(e, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		fmt.Printf("ID:          %s\n", e.ID)
+		fmt.Printf("Time:        %s\n", e.Timestamp.Local().Format("2006-01-02 15:04:05"))
+		fmt.Printf("Operation:   %s\n", e.Operation)
+		fmt.Printf("Provider:    %s\n", e.Provider)
+		fmt.Printf("Model:       %s\n", e.Model)
+		fmt.Printf("Latency:     %dms\n", e.LatencyMS)
+		fmt.Printf("Tokens:      %d prompt, %d completion\n", e.Usage.PromptTokens, e.Usage.CompletionTokens)
+		fmt.Printf("Prompt hash: %s\n", e.PromptHash)
+		if e.ReplayOf != "" {
+			fmt.Printf("Replay of:   %s\n", e.ReplayOf)
+		}
+		if e.Error != "" {
+			fmt.Printf("Error:       %s\n", e.Error)
+		}
+		fmt.Println("\n--- Prompt ---")
+		fmt.Println(e.Prompt)
+		fmt.Println("\n--- Response ---")
+		fmt.Println(e.Response)
+	},
+}
+
+var logReplayCmd = &cobra.Command{
+	Use:   "replay [id]",
+	Short: "Re-run a logged request against the same or a different model",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		modelName, _ := cmd.Flags().GetString("model")
+		e, err := audit.Find(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if provider == "" {
+			provider = e.Provider
+		}
+		if modelName == "" {
+			modelName = e.Model
+		}
+
+		cfg, _ := config.EnsureConfigPrompts("")
+		model, err := models.NewModel(&cfg, provider, modelName)
+		if err != nil {
+			fmt.Println("Error creating model:", err)
+			return
+		}
+		opts := map[string]interface{}{}
+		for k, v := range e.Options {
+			opts[k] = v
+		}
+		opts["replay_of"] = e.ID
+
+		fmt.Fprintf(os.Stderr, "Replaying %s (%s) against %s/%s...\n", e.ID, e.Operation, provider, modelName)
+		resp, err := model.SendRequest(e.Prompt, opts)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println(resp)
+		if strings.TrimSpace(resp) == strings.TrimSpace(e.Response) {
+			fmt.Fprintln(os.Stderr, "(response identical to the logged one)")
+		}
+	},
+}
+
+var logEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Turn on the audit log",
+	Run: func(cmd *cobra.Command, args []string) {
+		setAuditLog(true)
+	},
+}
+
+var logDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Turn off the audit log",
+	Run: func(cmd *cobra.Command, args []string) {
+		setAuditLog(false)
+	},
+}
+
+func setAuditLog(enabled bool) {
+	cfg, _ := config.EnsureConfigPrompts("")
+	cfg.AuditLog = enabled
+	if err := config.SaveConfig("", cfg); err != nil {
+		fmt.Println("Error saving config:", err)
+		return
+	}
+	if enabled {
+		fmt.Println("Audit log enabled:", audit.LogFile())
+	} else {
+		fmt.Println("Audit log disabled.")
+	}
+}
+
+func init() {
+	logListCmd.Flags().Int("limit", 20, "Maximum number of entries to show (0 for all)")
+	logShowCmd.Flags().Bool("json", false, "Print the raw entry as JSON")
+	logReplayCmd.Flags().String("provider", "", "Provider to replay against (default: the logged one)")
+	logReplayCmd.Flags().String("model", "", "Model to replay against (default: the logged one)")
+	logCmd.AddCommand(logListCmd)
+	logCmd.AddCommand(logShowCmd)
+	logCmd.AddCommand(logReplayCmd)
+	logCmd.AddCommand(logEnableCmd)
+	logCmd.AddCommand(logDisableCmd)
+	rootCmd.AddCommand(logCmd)
+}
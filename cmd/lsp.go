@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+	"github.com/codeforge-ide/codeforgeai.go/lsp"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server over stdio for editor integration",
+	Long: `Runs a Language Server Protocol server on stdin and stdout, for any editor
+with LSP support. It provides:
+
+  - completions and inline completions at the cursor (as suggestion --fim)
+  - hover explanations of the symbol under the cursor (as explain --symbol)
+  - code actions "Improve with AI", "Add docs" and "Generate test" on the
+    selection or the declaration around the cursor
+  - the workspace commands codeforgeai.improve, codeforgeai.document,
+    codeforgeai.generateTests and codeforgeai.commitMessage
+
+Configure the editor to start "codeforgeai lsp" for the languages you want.
+Logs go to stderr.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.EnsureConfigPrompts("")
+		// The protocol owns stdout: anything else printed goes to stderr.
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		// Stdin is the protocol too, so nothing may ask on it.
+		models.SetInteractive(false)
+		server := lsp.NewServer(engine.NewEngine(&cfg))
+		if err := server.Serve(os.Stdin, stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	// Many editors start language servers with --stdio.
+	lspCmd.Flags().Bool("stdio", true, "Communicate over stdin and stdout (the only transport)")
+	rootCmd.AddCommand(lspCmd)
+}
@@ -5,13 +5,15 @@ import (
 	"log"
 
 	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/secrets"
 	"github.com/spf13/cobra"
 )
 
 var mcpCmd = &cobra.Command{
 	Use:   "mcp",
 	Short: "Manage MCP server integrations",
-	Long:  "Enable, disable, and configure Model Context Protocol server integrations",
+	Long: `Enable, disable, and configure Model Context Protocol server integrations.
+A server's credential is read from the mcp.<server> secret (see 'secrets').`,
 }
 
 var mcpEnableCmd = &cobra.Command{
@@ -32,6 +34,9 @@ var mcpEnableCmd = &cobra.Command{
 		default:
 			log.Fatalf("Unknown MCP server: %s", server)
 		}
+		if secrets.MCPCredential(server) != "" {
+			fmt.Printf("🔑 Requests will carry the %s secret\n", secrets.MCPCredentialName(server))
+		}
 
 		// if err := config.SaveConfig("", cfg); err != nil {
 		// 	log.Fatalf("Failed to save config: %v", err)
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/conversation"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/spf13/cobra"
+)
+
+var multiTurnCmd = &cobra.Command{
+	Use:   "multi-turn [transcript|-]",
+	Short: "Continue a conversation stored in a JSON, YAML or markdown transcript",
+	Long: `Reads a conversation from a transcript file (or stdin with "-"), sends it to
+the provider, appends the assistant's reply and writes the transcript back.
+
+The provider and model come from --provider/--model, then from the
+transcript's own "provider" and "model" fields, then from the config. When
+the transcript is read from stdin it is written to stdout unless --output
+is given; otherwise the reply is printed and the file is updated in place.
+
+Long transcripts are compacted before they are sent: older messages are
+summarized with the general model and marked "compacted" in the transcript,
+so the change is visible and can be undone by removing the summary and the
+flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, _ := cmd.Flags().GetString("provider")
+		model, _ := cmd.Flags().GetString("model")
+		runMultiTurn(cmd, args[0], provider, model)
+	},
+}
+
+// runMultiTurn answers the transcript at path with the given provider and
+// model, falling back to the transcript and then the config for each.
+func runMultiTurn(cmd *cobra.Command, path, provider, model string) {
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	noCompact, _ := cmd.Flags().GetBool("no-compact")
+
+	t, err := conversation.Load(path)
+	if err != nil {
+		fmt.Println("Error reading transcript:", err)
+		return
+	}
+	if !t.Pending() {
+		fmt.Println("Error: the transcript must end with a user message to reply to")
+		return
+	}
+
+	cfg, _ := config.EnsureConfigPrompts("")
+	if provider == "" {
+		provider = t.Provider
+	}
+	if provider == "" {
+		provider = cfg.Integrations.Default
+	}
+	if model == "" {
+		model = t.Model
+	}
+	if model == "" {
+		model = models.ModelName(&cfg, provider, "general")
+	}
+	m, err := models.NewModel(&cfg, provider, model)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := models.Preflight(&cfg, provider, model); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if !noCompact {
+		if err := compactTranscript(&cfg, t, false); err != nil {
+			fmt.Println("Error compacting transcript:", err)
+			return
+		}
+	}
+	reply, err := m.(modeliface.ChatModel).Chat(t.Active(), map[string]interface{}{"operation": "multi_turn"})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	msg := t.Append("assistant", strings.TrimSpace(reply))
+	if u := m.(modeliface.UsageReporter).LastUsage(); u.CompletionTokens > 0 {
+		msg.Tokens = u.CompletionTokens
+	}
+
+	if output == "" {
+		output = path
+	}
+	if format == "" {
+		format = conversation.FormatForPath(output)
+	}
+	if output != "-" {
+		fmt.Println(reply)
+	}
+	if err := t.Save(output, format); err != nil {
+		fmt.Println("Error writing transcript:", err)
+	}
+}
+
+// compactTranscript summarizes the older part of t with the general model
+// once it has grown past the configured threshold, or whenever force is
+// set, and reports what it did on stderr.
+func compactTranscript(cfg *config.Config, t *conversation.Transcript, force bool) error {
+	o := conversation.CompactOptions{
+		MaxTokens:  cfg.Context.MaxTokens,
+		CompactAt:  cfg.Context.CompactAt,
+		KeepRecent: cfg.Context.KeepRecent,
+	}
+	if !force && !t.NeedsCompaction(o) {
+		return nil
+	}
+	model, err := models.GetModelFromConfig(cfg, "general")
+	if err != nil {
+		return err
+	}
+	before := t.Tokens()
+	n, err := t.Compact(o, conversation.ModelSummarizer(model, cfg.ConversationSummaryPrompt))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "📦 Compacted %d earlier message(s) into a summary (~%d → ~%d tokens)\n", n, before, t.Tokens())
+	}
+	return nil
+}
+
+// addTranscriptFlags registers the flags shared by the multi-turn commands.
+func addTranscriptFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", "", "Transcript output format: json, yaml or markdown (default: the input format)")
+	cmd.Flags().StringP("output", "o", "", "Write the transcript here instead of back to the input (\"-\" for stdout)")
+	cmd.Flags().Bool("no-compact", false, "Send the transcript as is, even when it exceeds the context budget")
+}
+
+func init() {
+	multiTurnCmd.Flags().String("provider", "", "Provider to send the conversation to (default: transcript, then config)")
+	multiTurnCmd.Flags().String("model", "", "Model to use (default: transcript, then the provider's general model)")
+	addTranscriptFlags(multiTurnCmd)
+	rootCmd.AddCommand(multiTurnCmd)
+}
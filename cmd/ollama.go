@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/ollama"
+	"github.com/spf13/cobra"
+)
+
+var ollamaCmd = &cobra.Command{
+	Use:   "ollama",
+	Short: "Manage local Ollama models",
+	Long: `Wraps the Ollama server API (default http://localhost:11434, or the host of
+$OLLAMA_API_ENDPOINT) to list, pull, inspect, remove and preload models.`,
+}
+
+var ollamaStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Check that the Ollama server is up and the configured models are pulled",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.EnsureConfigPrompts("")
+		client := ollama.NewClient("")
+		version, err := client.Version()
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Ollama %s at %s\n", version, client.BaseURL)
+		for _, m := range configuredOllamaModels(&cfg) {
+			ok, err := client.Has(m)
+			switch {
+			case err != nil:
+				fmt.Printf("  %s: error: %v\n", m, err)
+			case ok:
+				fmt.Printf("  %s: pulled\n", m)
+			default:
+				fmt.Printf("  %s: MISSING (run 'codeforgeai ollama pull %s')\n", m, m)
+			}
+		}
+	},
+}
+
+var ollamaListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List pulled models",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		models, err := ollama.NewClient("").List()
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(models, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tPARAMS\tQUANT\tMODIFIED")
+		for _, m := range models {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Name, ollama.FormatSize(m.Size),
+				m.Details.ParameterSize, m.Details.QuantizationLevel, m.ModifiedAt.Local().Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
+var ollamaPullCmd = &cobra.Command{
+	Use:   "pull [model...]",
+	Short: "Pull models (default: the configured general and code models)",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cfg, _ := config.EnsureConfigPrompts("")
+			args = configuredOllamaModels(&cfg)
+		}
+		client := ollama.NewClient("")
+		for _, m := range args {
+			fmt.Printf("Pulling %s...\n", m)
+			if err := client.Pull(m, ollama.ProgressBar()); err != nil {
+				fmt.Println("Error pulling", m+":", err)
+				return
+			}
+		}
+	},
+}
+
+var ollamaShowCmd = &cobra.Command{
+	Use:   "show [model]",
+	Short: "Show model details, parameters and template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		info, err := ollama.NewClient("").Show(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(info, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		fmt.Printf("Model:        %s\n", args[0])
+		fmt.Printf("Family:       %s\n", info.Details.Family)
+		fmt.Printf("Parameters:   %s\n", info.Details.ParameterSize)
+		fmt.Printf("Quantization: %s\n", info.Details.QuantizationLevel)
+		if ctx := contextLength(info); ctx > 0 {
+			fmt.Printf("Context:      %d tokens\n", ctx)
+		}
+		if len(info.Capabilities) > 0 {
+			fmt.Printf("Capabilities: %s\n", strings.Join(info.Capabilities, ", "))
+		}
+		if info.Parameters != "" {
+			fmt.Println("\n--- Parameters ---")
+			fmt.Println(strings.TrimSpace(info.Parameters))
+		}
+		if info.Template != "" {
+			fmt.Println("\n--- Template ---")
+			fmt.Println(strings.TrimSpace(info.Template))
+		}
+	},
+}
+
+var ollamaRmCmd = &cobra.Command{
+	Use:   "rm [model...]",
+	Short: "Remove models from the Ollama server",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := ollama.NewClient("")
+		for _, m := range args {
+			if err := client.Delete(m); err != nil {
+				fmt.Println("Error removing", m+":", err)
+				return
+			}
+			fmt.Println("Removed", m)
+		}
+	},
+}
+
+var ollamaPsCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List models currently loaded in memory",
+	Run: func(cmd *cobra.Command, args []string) {
+		models, err := ollama.NewClient("").Running()
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if len(models) == 0 {
+			fmt.Println("No models loaded.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tPROCESSOR\tUNTIL")
+		for _, m := range models {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Name, ollama.FormatSize(m.Size), processor(m),
+				time.Until(m.ExpiresAt).Round(time.Second))
+		}
+		w.Flush()
+	},
+}
+
+var ollamaWarmCmd = &cobra.Command{
+	Use:   "warm [model...]",
+	Short: "Preload models so the next request is not a cold start",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.EnsureConfigPrompts("")
+		keepAlive, _ := cmd.Flags().GetString("keep-alive")
+		if keepAlive == "" {
+			keepAlive = cfg.Ollama.KeepAlive
+		}
+		if len(args) == 0 {
+			args = configuredOllamaModels(&cfg)
+		}
+		client := ollama.NewClient("")
+		for _, m := range args {
+			start := time.Now()
+			if err := client.WarmUp(m, keepAlive); err != nil {
+				fmt.Println("Error warming", m+":", err)
+				return
+			}
+			fmt.Printf("Loaded %s in %s (kept for %s)\n", m, time.Since(start).Round(time.Millisecond), keepAlive)
+		}
+	},
+}
+
+// configuredOllamaModels returns the distinct general and code models.
+func configuredOllamaModels(cfg *config.Config) []string {
+	if cfg.GeneralModel == cfg.CodeModel {
+		return []string{cfg.GeneralModel}
+	}
+	return []string{cfg.GeneralModel, cfg.CodeModel}
+}
+
+// contextLength finds the "<arch>.context_length" entry of a model.
+func contextLength(info *ollama.ModelInfo) int {
+	for k, v := range info.ModelInfo {
+		if strings.HasSuffix(k, ".context_length") {
+			if f, ok := v.(float64); ok {
+				return int(f)
+			}
+		}
+	}
+	return 0
+}
+
+func processor(m ollama.RunningModel) string {
+	switch {
+	case m.Size == 0 || m.SizeVRAM == 0:
+		return "100% CPU"
+	case m.SizeVRAM >= m.Size:
+		return "100% GPU"
+	}
+	gpu := m.SizeVRAM * 100 / m.Size
+	return fmt.Sprintf("%d%%/%d%% CPU/GPU", 100-gpu, gpu)
+}
+
+func init() {
+	ollamaListCmd.Flags().Bool("json", false, "Print models as JSON")
+	ollamaShowCmd.Flags().Bool("json", false, "Print the raw /api/show response")
+	ollamaWarmCmd.Flags().String("keep-alive", "", "How long to keep the models loaded (default: ollama.keep_alive from the config)")
+	ollamaCmd.AddCommand(ollamaStatusCmd)
+	ollamaCmd.AddCommand(ollamaListCmd)
+	ollamaCmd.AddCommand(ollamaPullCmd)
+	ollamaCmd.AddCommand(ollamaShowCmd)
+	ollamaCmd.AddCommand(ollamaRmCmd)
+	ollamaCmd.AddCommand(ollamaPsCmd)
+	ollamaCmd.AddCommand(ollamaWarmCmd)
+	rootCmd.AddCommand(ollamaCmd)
+}
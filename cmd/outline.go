@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codeforge-ide/codeforgeai.go/parser"
+	"github.com/spf13/cobra"
+)
+
+var outlineCmd = &cobra.Command{
+	Use:   "outline [files...]",
+	Short: "List the imports, functions, classes and methods of source files",
+	Long: `Prints the outline of each file: its imports and declarations with their
+line ranges. Go, TypeScript, JavaScript, Python, Rust and Solidity files are
+supported. The names shown are the ones explain --symbol and edit --symbol
+accept.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		var outlines []*parser.Outline
+		for _, path := range args {
+			o, err := parser.OutlineFile(path)
+			if err != nil {
+				fmt.Println("Error:", err)
+				continue
+			}
+			outlines = append(outlines, o)
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(outlines, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		for i, o := range outlines {
+			if i > 0 {
+				fmt.Println()
+			}
+			printOutline(o)
+		}
+	},
+}
+
+func printOutline(o *parser.Outline) {
+	fmt.Printf("%s (%s)\n", o.Path, o.Language)
+	if len(o.Imports) > 0 {
+		fmt.Println("imports:", strings.Join(o.Imports, ", "))
+	}
+	// Declarations written inside their parent are indented under it;
+	// others, such as Go methods, keep their qualified name.
+	type nesting struct{ depth, end int }
+	parents := map[string]nesting{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, d := range o.Decls {
+		lines := fmt.Sprint(d.StartLine)
+		if d.EndLine > d.StartLine {
+			lines += fmt.Sprintf("-%d", d.EndLine)
+		}
+		name, depth := d.Name, 0
+		if p, ok := parents[d.Parent]; ok && d.Parent != "" && d.StartLine <= p.end {
+			name, depth = strings.TrimPrefix(d.Name, d.Parent+"."), p.depth+1
+		}
+		parents[d.Name] = nesting{depth, d.EndLine}
+		fmt.Fprintf(w, "  %s\t%s\t%s%s\n", lines, d.Kind, strings.Repeat("  ", depth), name)
+	}
+	w.Flush()
+}
+
+func init() {
+	outlineCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(outlineCmd)
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/daemon"
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/astrolescent"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubcopilot"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubmodels"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/ollama"
+	"github.com/codeforge-ide/codeforgeai.go/mcp/astro"
+	"github.com/codeforge-ide/codeforgeai.go/secrets"
+	"github.com/spf13/cobra"
+)
+
+// applyPrivacy enables local-only mode from the --local-only flag, the
+// global config or the project config before any command runs.
+func applyPrivacy(cfg *config.Config) {
+	if localOnly {
+		httpclient.SetLocalOnly(true)
+		return
+	}
+	if config.LocalOnly(cfg) {
+		httpclient.SetLocalOnly(true)
+	}
+}
+
+// networkDestination is one place the current config could send data to.
+type networkDestination struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Loopback bool   `json:"loopback"`
+	Allowed  bool   `json:"allowed"`
+	When     string `json:"when"`
+}
+
+// networkDestinations lists every destination reachable with cfg.
+func networkDestinations(cfg *config.Config) []networkDestination {
+	enabled := func(entry config.IntegrationEntry, name string) string {
+		if cfg.Integrations.Default == name {
+			return "default provider"
+		}
+		if entry.Enabled {
+			return "integration enabled"
+		}
+		return "integration disabled"
+	}
+	dests := []networkDestination{
+		{Name: "Ollama API", URL: ollama.NewOllamaModel("", "", 0).Endpoint, When: enabled(cfg.Integrations.Ollama, "ollama")},
+		{Name: "GitHub Models inference", URL: githubmodels.NewClient("", "", "").Endpoint, When: enabled(cfg.Integrations.GithubModels, "githubmodels")},
+		{Name: "GitHub Models catalog", URL: githubmodels.CatalogURL, When: enabled(cfg.Integrations.GithubModels, "githubmodels")},
+		{Name: "GitHub Copilot (through the Copilot language server)", URL: githubcopilot.APIURL, When: enabled(cfg.Integrations.GithubCopilot, "githubcopilot")},
+		{Name: "GitHub API", URL: githubmodels.GithubAPIUserURL, When: "github-models whoami/test"},
+		{Name: "Astrolescent MCP server", URL: astro.AstrolescentMCPURL, When: "astro and analyze --mcp commands"},
+		{Name: "Astrolescent API", URL: astrolescent.NewClient().BaseURL(), When: "astro commands"},
+		{Name: "Secrets agent", URL: "unix://" + secrets.AgentSocketPath(), When: "credential lookups"},
+		{Name: "Daemon", URL: "unix://" + daemon.SocketPath(), When: "any command, while the daemon runs"},
+	}
+	if url := index.EmbeddingsURL(cfg); url != "" {
+		dests = append(dests, networkDestination{
+			Name: "Embeddings (index provider " + cfg.Index.Provider + ")",
+			URL:  url,
+			When: "index, search, and prompts in indexed projects",
+		})
+	}
+	for i := range dests {
+		dests[i].Loopback = httpclient.IsLoopbackURL(dests[i].URL)
+		dests[i].Allowed = dests[i].Loopback || !httpclient.LocalOnly()
+	}
+	return dests
+}
+
+var privacyCmd = &cobra.Command{
+	Use:   "privacy",
+	Short: "Inspect and configure the local-only privacy mode",
+}
+
+var privacyStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether local-only privacy mode is active and why",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.EnsureConfigPrompts("")
+		pc, path, err := config.LoadProjectConfig()
+		if err != nil {
+			fmt.Println("Error reading project config:", err)
+		}
+		fmt.Printf("Global config privacy:  %s\n", privacyLabel(cfg.Privacy))
+		if path != "" {
+			fmt.Printf("Project config privacy: %s (%s)\n", privacyLabel(pc.Privacy), path)
+		} else {
+			fmt.Println("Project config privacy: (no " + config.ProjectConfigFile + " found)")
+		}
+		fmt.Printf("--local-only flag:      %v\n", localOnly)
+		if httpclient.LocalOnly() {
+			fmt.Println("Local-only mode is ACTIVE: only loopback destinations are allowed.")
+		} else {
+			fmt.Println("Local-only mode is off.")
+		}
+	},
+}
+
+var privacySetCmd = &cobra.Command{
+	Use:   "set [local_only|off]",
+	Short: "Set the privacy mode globally or for the current project",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		project, _ := cmd.Flags().GetBool("project")
+		mode := args[0]
+		switch mode {
+		case config.PrivacyLocalOnly:
+		case "off":
+			mode = ""
+		default:
+			fmt.Println("Error: privacy mode must be 'local_only' or 'off'")
+			return
+		}
+		if project {
+			pc, path, _ := config.LoadProjectConfig()
+			pc.Privacy = mode
+			if err := config.SaveProjectConfig(path, pc); err != nil {
+				fmt.Println("Error saving project config:", err)
+				return
+			}
+			fmt.Printf("Project privacy set to %s.\n", privacyLabel(mode))
+			return
+		}
+		cfg, _ := config.EnsureConfigPrompts("")
+		cfg.Privacy = mode
+		if err := config.SaveConfig("", cfg); err != nil {
+			fmt.Println("Error saving config:", err)
+			return
+		}
+		fmt.Printf("Global privacy set to %s.\n", privacyLabel(mode))
+	},
+}
+
+var privacyAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List every network destination the current config could reach",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		cfg, _ := config.EnsureConfigPrompts("")
+		dests := networkDestinations(&cfg)
+		if asJSON {
+			b, _ := json.MarshalIndent(dests, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESTINATION\tSCOPE\tSTATUS\tUSED BY")
+		for _, d := range dests {
+			scope, status := "remote", "allowed"
+			if d.Loopback {
+				scope = "loopback"
+			}
+			if !d.Allowed {
+				status = "BLOCKED"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Name, d.URL, scope, status, d.When)
+		}
+		w.Flush()
+		if httpclient.LocalOnly() {
+			fmt.Println("\nLocal-only mode is active; remote destinations are refused.")
+		}
+	},
+}
+
+func privacyLabel(mode string) string {
+	if mode == "" {
+		return "off"
+	}
+	return mode
+}
+
+func init() {
+	privacySetCmd.Flags().Bool("project", false, "Write to "+config.ProjectConfigFile+" instead of the global config")
+	privacyAuditCmd.Flags().Bool("json", false, "Print destinations as JSON")
+	privacyCmd.AddCommand(privacyStatusCmd)
+	privacyCmd.AddCommand(privacySetCmd)
+	privacyCmd.AddCommand(privacyAuditCmd)
+	rootCmd.AddCommand(privacyCmd)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+)
+
+func TestNetworkDestinationsEmbeddings(t *testing.T) {
+	tests := []struct {
+		provider, endpoint string
+		want               string
+		loopback           bool
+	}{
+		{"openai", "", "https://models.inference.ai.azure.com/embeddings", false},
+		{"openai", "https://api.openai.com/v1/", "https://api.openai.com/v1/embeddings", false},
+		{"ollama", "http://gpu-box:11434", "http://gpu-box:11434/api/embed", false},
+		{"ollama", "http://127.0.0.1:11434/api/generate", "http://127.0.0.1:11434/api/embed", true},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		cfg.Index.Provider, cfg.Index.Endpoint = tt.provider, tt.endpoint
+		var found *networkDestination
+		for _, d := range networkDestinations(cfg) {
+			if d.URL == tt.want {
+				found = &d
+			}
+		}
+		if found == nil {
+			t.Errorf("%s at %q: no destination %s", tt.provider, tt.endpoint, tt.want)
+		} else if found.Loopback != tt.loopback {
+			t.Errorf("%s: loopback = %v, want %v", tt.want, found.Loopback, tt.loopback)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/redact"
+	"github.com/spf13/cobra"
+)
+
+var redactCmd = &cobra.Command{
+	Use:   "redact [file]",
+	Short: "Preview what would be masked before content is sent to a model",
+	Long: `Run the redaction pipeline over a file (or stdin) without calling any model.
+Prints the masked text followed by a report of what was masked.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reportOnly, _ := cmd.Flags().GetBool("report")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var data []byte
+		var err error
+		if len(args) == 1 {
+			data, err = os.ReadFile(args[0])
+		} else {
+			data, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Println("Error reading input:", err)
+			return
+		}
+
+		cfg, _ := config.EnsureConfigPrompts("")
+		r, err := redact.New(cfg.Redaction.CustomPatterns)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		masked := r.Redact(string(data))
+		findings := r.Report()
+
+		if asJSON {
+			b, _ := json.MarshalIndent(findings, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		if !reportOnly {
+			fmt.Println(masked)
+			fmt.Println("---")
+		}
+		if len(findings) == 0 {
+			fmt.Println("Nothing to redact.")
+			return
+		}
+		fmt.Println(redact.Summary(findings))
+		for _, f := range findings {
+			for _, ph := range f.Placeholders {
+				fmt.Printf("  %-16s %s\n", f.Rule, ph)
+			}
+		}
+	},
+}
+
+func init() {
+	redactCmd.Flags().Bool("report", false, "Only print the report, not the masked text")
+	redactCmd.Flags().Bool("json", false, "Print the report as JSON")
+	rootCmd.AddCommand(redactCmd)
+}
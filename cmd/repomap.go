@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
+	"github.com/spf13/cobra"
+)
+
+var repomapCmd = &cobra.Command{
+	Use:   "repomap",
+	Short: "Print a ranked map of the project's files and their key declarations",
+	Long: `Prints the repository map: the project's files (respecting .gitignore and
+the 'analyze' results) with their functions, classes and methods, the files
+the rest of the project refers to most first, trimmed to a token budget.
+Outlines are cached under ~/.codeforgeai/repomap and only changed files are
+parsed again.
+
+The same map is added to prompt, chat and edit prompts so the model knows
+the layout of the codebase; the "repo_map" tokens setting sizes it there,
+and 0 or --no-context leaves it out.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tokens, _ := cmd.Flags().GetInt("tokens")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		cfg, _ := config.EnsureConfigPrompts("")
+		if tokens <= 0 {
+			tokens = cfg.RepoMap.Tokens
+		}
+		if tokens <= 0 {
+			// Turned off for prompts, but still wanted here.
+			tokens = config.DefaultConfig().RepoMap.Tokens
+		}
+		root := config.ProjectRoot()
+		c, err := repomap.Update(&cfg, root)
+		if err != nil {
+			fmt.Println("Error building repo map:", err)
+			return
+		}
+		entries := c.Rank()
+		if asJSON {
+			b, _ := json.MarshalIndent(entries, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		fmt.Print(repomap.Render(entries, tokens))
+	},
+}
+
+func init() {
+	repomapCmd.Flags().Int("tokens", 0, "Token budget of the map (default: the \"repo_map\" tokens setting)")
+	repomapCmd.Flags().Bool("json", false, "Output every file with its symbols and reference counts as JSON")
+	rootCmd.AddCommand(repomapCmd)
+}
@@ -2,16 +2,28 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/codeforge-ide/codeforgeai.go/cache"
 	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/daemon"
 	"github.com/codeforge-ide/codeforgeai.go/directory"
 	"github.com/codeforge-ide/codeforgeai.go/engine"
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/index"
 	"github.com/codeforge-ide/codeforgeai.go/integrations/astrolescent"
 	"github.com/codeforge-ide/codeforgeai.go/integrations/githubmodels"
+	"github.com/codeforge-ide/codeforgeai.go/logging"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
 	"github.com/codeforge-ide/codeforgeai.go/secrets"
 	"github.com/spf13/cobra"
 )
@@ -23,12 +35,34 @@ var (
 	userPrompt  []string
 	filePath    string
 	loop        bool
+	localOnly   bool
+	noCache     bool
+	noContext   bool
+	githubFlag  string
 )
 
+const noGithubTokenMsg = "A GitHub token is required: pass --github-token, set GITHUB_TOKEN, run 'codeforgeai secrets unlock', or log in with 'gh auth login'."
+
 var rootCmd = &cobra.Command{
 	Use:   "codeforgeai",
 	Short: "CodeforgeAI AI agent",
 	Long:  "CodeforgeAI AI agent - Go CLI",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.LoadConfig("")
+		logging.Setup(verbose, veryVerbose, debug || cfg.Debug)
+		applyPrivacy(&cfg)
+		cache.SetDisabled(noCache)
+		index.SetContextDisabled(noContext)
+		repomap.SetContextDisabled(noContext)
+		secrets.SetExplicitGithubToken(githubFlag)
+		for provider, perMinute := range cfg.RateLimits {
+			httpclient.SetRateLimit(provider, perMinute)
+		}
+		// The daemon answers with its own environment and global config, so
+		// runs that change either are handled in-process.
+		daemon.SetDisabled(noDaemon || noCache || githubFlag != "" || httpclient.LocalOnly() || config.FindProjectConfig() != "")
+		connectDaemon(cmd)
+	},
 }
 
 func Execute() {
@@ -43,6 +77,11 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "set loglevel to INFO")
 	rootCmd.PersistentFlags().BoolVarP(&veryVerbose, "very-verbose", "V", false, "set loglevel to DEBUG")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug mode (overrides other verbosity flags)")
+	rootCmd.PersistentFlags().BoolVar(&localOnly, "local-only", false, "Refuse any network destination that is not loopback")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Bypass the response cache for this run")
+	rootCmd.PersistentFlags().BoolVar(&noContext, "no-context", false, "Do not add project context (the repo map and code from the index) to prompts")
+	rootCmd.PersistentFlags().StringVar(&githubFlag, "github-token", "", "GitHub token for this run (visible to other local users; prefer GITHUB_TOKEN or the secrets vault)")
+	rootCmd.PersistentFlags().BoolVar(&noDaemon, "no-daemon", false, "Do not use the running daemon for this run")
 
 	// analyze
 	analyzeCmd := &cobra.Command{
@@ -51,7 +90,10 @@ func init() {
 		Run: func(cmd *cobra.Command, args []string) {
 			cfg, _ := config.EnsureConfigPrompts("")
 			eng := engine.NewEngine(&cfg)
-			eng.RunAnalysis()
+			if err := eng.RunAnalysis(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
 		},
 	}
 	analyzeCmd.Flags().BoolVar(&loop, "loop", false, "Enable adaptive feedback loop")
@@ -65,7 +107,11 @@ func init() {
 		Run: func(cmd *cobra.Command, args []string) {
 			cfg, _ := config.EnsureConfigPrompts("")
 			eng := engine.NewEngine(&cfg)
-			resp := eng.ProcessPrompt(strings.Join(args, " "))
+			resp, err := eng.ProcessPrompt(strings.Join(args, " "))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
 			fmt.Println(resp)
 		},
 	}
@@ -112,7 +158,11 @@ func init() {
 				return
 			}
 
-			resp := eng.ProcessCommitMessage(diff)
+			resp, err := eng.ProcessCommitMessage(diff)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
 			fmt.Println(resp)
 		},
 	}
@@ -129,33 +179,46 @@ func init() {
 	}
 	copilotCmd.AddCommand(&cobra.Command{
 		Use:   "login",
-		Short: "Authenticate with GitHub Copilot",
+		Short: "Authenticate with GitHub Copilot (device flow)",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("GitHub Copilot login (not implemented in Go yet).")
+			if err := copilotLogin(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		},
 	})
 	copilotCmd.AddCommand(&cobra.Command{
 		Use:   "logout",
 		Short: "Logout from GitHub Copilot",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("GitHub Copilot logout (not implemented in Go yet).")
+			if err := copilotLogout(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		},
 	})
 	copilotCmd.AddCommand(&cobra.Command{
 		Use:   "status",
 		Short: "Check GitHub Copilot status",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("GitHub Copilot status (not implemented in Go yet).")
+			if err := copilotStatus(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		},
 	})
 	copilotCmd.AddCommand(&cobra.Command{
 		Use:   "lsp",
 		Short: "install copilot language server globally",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Installing Copilot language server (not implemented in Go yet).")
+			if err := copilotInstall(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
 		},
 	})
 	githubCmd.AddCommand(copilotCmd)
+	rootCmd.AddCommand(githubCmd)
 
 	githubModelsCmd := &cobra.Command{
 		Use:   "github-models",
@@ -168,9 +231,9 @@ func init() {
 		Short: "Send a simple prompt to GitHub Models",
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			token := os.Getenv("GITHUB_TOKEN")
+			token := githubToken()
 			if token == "" {
-				fmt.Println("GITHUB_TOKEN environment variable is required.")
+				fmt.Println(noGithubTokenMsg)
 				return
 			}
 			client := githubmodels.NewClient(token, "", "")
@@ -186,30 +249,19 @@ func init() {
 
 	// github-models multi-turn
 	githubModelsMultiTurnCmd := &cobra.Command{
-		Use:   "multi-turn",
-		Short: "Send a multi-turn conversation to GitHub Models",
+		Use:   "multi-turn [transcript|-]",
+		Short: "Continue a transcript conversation with GitHub Models",
+		Long: `Like the top-level multi-turn command, but always sends the conversation
+to GitHub Models. See 'codeforgeai multi-turn --help' for the transcript
+formats.`,
+		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			token := os.Getenv("GITHUB_TOKEN")
-			if token == "" {
-				fmt.Println("GITHUB_TOKEN environment variable is required.")
-				return
-			}
-			// For demo, hardcode a conversation; in real use, parse from args or file
-			history := []githubmodels.Message{
-				{Role: "system", Content: "You are a helpful assistant."},
-				{Role: "user", Content: "What is the capital of France?"},
-				{Role: "assistant", Content: "The capital of France is Paris."},
-				{Role: "user", Content: "What about Spain?"},
-			}
-			client := githubmodels.NewClient(token, "", "")
-			resp, err := client.MultiTurn(history)
-			if err != nil {
-				fmt.Println("Error:", err)
-				return
-			}
-			fmt.Println(resp)
+			model, _ := cmd.Flags().GetString("model")
+			runMultiTurn(cmd, args[0], "githubmodels", model)
 		},
 	}
+	githubModelsMultiTurnCmd.Flags().String("model", "", "Model to use (default: transcript, then general_model_github)")
+	addTranscriptFlags(githubModelsMultiTurnCmd)
 	githubModelsCmd.AddCommand(githubModelsMultiTurnCmd)
 
 	// github-models stream
@@ -218,9 +270,9 @@ func init() {
 		Short: "Stream a prompt response from GitHub Models",
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			token := os.Getenv("GITHUB_TOKEN")
+			token := githubToken()
 			if token == "" {
-				fmt.Println("GITHUB_TOKEN environment variable is required.")
+				fmt.Println(noGithubTokenMsg)
 				return
 			}
 			client := githubmodels.NewClient(token, "", "")
@@ -240,9 +292,9 @@ func init() {
 		Short: "Send an image prompt to GitHub Models",
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			token := os.Getenv("GITHUB_TOKEN")
+			token := githubToken()
 			if token == "" {
-				fmt.Println("GITHUB_TOKEN environment variable is required.")
+				fmt.Println(noGithubTokenMsg)
 				return
 			}
 			imagePath := args[1]
@@ -281,34 +333,172 @@ func init() {
 	// github-models token-load
 	githubModelsTokenLoadCmd := &cobra.Command{
 		Use:   "token-load",
-		Short: "Load your GitHub Models API token into the environment for this session",
+		Short: "Unlock your GitHub Models API token for later commands via the secrets agent",
 		Run: func(cmd *cobra.Command, args []string) {
-			token, err := secrets.InteractiveLoadGithubToken()
-			if err != nil {
+			if secrets.AgentRunning() {
+				fmt.Println("Secrets agent is already running; GitHub token is available to later commands.")
+				return
+			}
+			if err := startSecretsAgent(secrets.DefaultAgentTimeout); err != nil {
 				fmt.Println("Error loading token:", err)
-			} else {
-				fmt.Println("GitHub token loaded into environment for this session.")
-				// Optionally print token for debug (not recommended for real use)
-				_ = token
+				return
 			}
+			fmt.Printf("GitHub token unlocked for later commands (locks after %s of inactivity).\n", secrets.DefaultAgentTimeout)
 		},
 	}
 	githubModelsCmd.AddCommand(githubModelsTokenLoadCmd)
 
+	// github-models whoami
+	githubModelsWhoamiCmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show which GitHub token is in use, its owner and its scopes",
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := githubTokenInfo(); err != nil {
+				fmt.Println("Error:", err)
+			}
+		},
+	}
+	githubModelsCmd.AddCommand(githubModelsWhoamiCmd)
+
+	// github-models test
+	githubModelsTestCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Verify the GitHub token and make a minimal inference call",
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := githubTokenInfo(); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			cfg, _ := config.EnsureConfigPrompts("")
+			model, _ := cmd.Flags().GetString("model")
+			if model == "" {
+				model = cfg.GeneralModelGithub
+			}
+			client := githubmodels.NewClient(githubToken(), model, "")
+			start := time.Now()
+			resp, err := client.Chat([]githubmodels.Message{{Role: "user", Content: "Reply with the single word OK."}}, false)
+			if err != nil {
+				fmt.Printf("Inference:   FAILED with %s: %v\n", model, err)
+				return
+			}
+			u := client.LastUsage()
+			fmt.Printf("Inference:   ok (%s replied %q in %s, %d tokens)\n", model, strings.TrimSpace(resp),
+				time.Since(start).Round(time.Millisecond), u.Total())
+		},
+	}
+	githubModelsTestCmd.Flags().String("model", "", "Model to test (default: general_model_github)")
+	githubModelsCmd.AddCommand(githubModelsTestCmd)
+
+	// github-models list
+	githubModelsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List GitHub Models catalog entries, optionally filtered by tag or capability",
+		Run: func(cmd *cobra.Command, args []string) {
+			tags, _ := cmd.Flags().GetStringSlice("tag")
+			caps, _ := cmd.Flags().GetStringSlice("capability")
+			publisher, _ := cmd.Flags().GetString("publisher")
+			refresh, _ := cmd.Flags().GetBool("refresh")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			cfg, _ := config.EnsureConfigPrompts("")
+			catalog, fetchedAt, err := githubmodels.LoadCatalog(githubToken(), githubmodels.CatalogRefresh(&cfg), refresh)
+			if err != nil {
+				fmt.Println("Error fetching catalog:", err)
+				return
+			}
+			var matches []githubmodels.ModelCatalogEntry
+			for _, m := range catalog {
+				if publisher != "" && !strings.EqualFold(m.Publisher, publisher) {
+					continue
+				}
+				ok := true
+				for _, f := range append(append([]string{}, tags...), caps...) {
+					if !m.Has(f) {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					matches = append(matches, m)
+				}
+			}
+			if asJSON {
+				b, _ := json.MarshalIndent(matches, "", "  ")
+				fmt.Println(string(b))
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "MODEL\tPUBLISHER\tCONTEXT\tTOOLS\tVISION\tTIER\tTAGS")
+			for _, m := range matches {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", m.ID, m.Publisher, m.ContextLength(),
+					yesNo(m.ToolCalling()), yesNo(m.Vision()), m.RateLimitTier, strings.Join(m.Tags, ","))
+			}
+			w.Flush()
+			fmt.Printf("\n%d of %d models (catalog fetched %s)\n", len(matches), len(catalog), fetchedAt.Local().Format("2006-01-02 15:04"))
+			for _, name := range []string{cfg.GeneralModelGithub, cfg.CodeModelGithub} {
+				if err := githubmodels.ValidateModel(catalog, name); err != nil {
+					fmt.Println("Warning: configured", err)
+				}
+			}
+		},
+	}
+	githubModelsListCmd.Flags().StringSlice("tag", nil, "Only models with this tag (repeatable)")
+	githubModelsListCmd.Flags().StringSlice("capability", nil, "Only models with this capability, e.g. tool-calling, vision, streaming (repeatable)")
+	githubModelsListCmd.Flags().String("publisher", "", "Only models from this publisher")
+	githubModelsListCmd.Flags().Bool("refresh", false, "Fetch the catalog even if the cached copy is fresh")
+	githubModelsListCmd.Flags().Bool("json", false, "Print matching models as JSON")
+	githubModelsCmd.AddCommand(githubModelsListCmd)
+
 	rootCmd.AddCommand(githubModelsCmd)
 
 	// explain
 	explainCmd := &cobra.Command{
-		Use:   "explain [file_path]",
+		Use:   "explain [file_path[:start-end|#Symbol]]",
 		Short: "Explain the code in the given file",
-		Args:  cobra.ExactArgs(1),
+		Long: `Explains a file, a range of its lines ("file.go:120-180") or a single
+declaration ("file.go#Func", or --symbol) such as "Func", "Type.Method" or
+"pkg.Func" in Go, TypeScript, JavaScript, Python, Rust or Solidity. A Go
+symbol is explained together with the signatures and doc comments of the
+functions it calls, others with the outline of their file; without a file,
+--symbol looks in the whole project.
+
+--git-blame adds who last changed the lines and their commit messages.
+--depth picks a summary, a walkthrough or a line-by-line explanation, and
+--format plain text, Markdown or JSON with each section anchored to its
+lines.`,
+		Args: cobra.RangeArgs(0, 1),
 		Run: func(cmd *cobra.Command, args []string) {
+			opts := engine.ExplainOptions{}
+			opts.Symbol, _ = cmd.Flags().GetString("symbol")
+			opts.Blame, _ = cmd.Flags().GetBool("git-blame")
+			opts.Depth, _ = cmd.Flags().GetString("depth")
+			opts.Format, _ = cmd.Flags().GetString("format")
+			if opts.Symbol == "" && len(args) == 0 {
+				fmt.Println("Error: a file path or --symbol is required")
+				return
+			}
+			file := ""
+			if len(args) == 1 {
+				var err error
+				if file, err = parseExplainTarget(args[0], &opts); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
 			cfg, _ := config.EnsureConfigPrompts("")
 			eng := engine.NewEngine(&cfg)
-			resp := eng.ExplainCode(args[0])
+			resp, err := eng.ExplainCode(context.Background(), file, opts)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
 			fmt.Println(resp)
 		},
 	}
+	explainCmd.Flags().String("symbol", "", "Explain only this function, method, class or type")
+	explainCmd.Flags().Bool("git-blame", false, "Include who last changed the lines and their commit messages")
+	explainCmd.Flags().String("depth", engine.DepthWalkthrough, "Level of detail: summary, walkthrough or line-by-line")
+	explainCmd.Flags().String("format", engine.FormatMarkdown, "Output format: plain, markdown or json")
 	rootCmd.AddCommand(explainCmd)
 
 	// extract
@@ -360,6 +550,25 @@ func init() {
 			}
 
 			userPrompt := strings.Join(userPrompts, " ")
+			if symbol, _ := cmd.Flags().GetString("symbol"); symbol != "" {
+				if len(args) > 1 {
+					fmt.Println("Error: --symbol takes at most one file")
+					return
+				}
+				file := ""
+				if len(args) == 1 {
+					file = args[0]
+				}
+				cfg, _ := config.EnsureConfigPrompts("")
+				editFile, err := engine.NewEngine(&cfg).EditSymbol(symbol, file, userPrompt)
+				if err != nil {
+					fmt.Println("Error editing symbol:", err)
+				} else {
+					fmt.Printf("Edit complete. Check %s for the result.\n", editFile)
+				}
+				return
+			}
+
 			paths := args
 			if len(paths) == 0 {
 				paths = []string{"."} // Default to current directory
@@ -376,6 +585,7 @@ func init() {
 		},
 	}
 	editCmd.Flags().StringSlice("user_prompt", nil, "User prompt for editing")
+	editCmd.Flags().String("symbol", "", "Edit only this function, method, class or type (optionally within the given file)")
 	editCmd.Flags().Bool("allow-ignore", false, "Allow explicitly passed directories to be processed even if .gitignore ignores them")
 	rootCmd.AddCommand(editCmd)
 
@@ -383,15 +593,53 @@ func init() {
 	suggestionCmd := &cobra.Command{
 		Use:   "suggestion",
 		Short: "Short suggestions from code model at lightning speed",
+		Long: `Suggests code for a file, a line of it or a snippet.
+
+With --fim it completes the code at the --line/--col cursor of --file
+instead, sending the code before and after the cursor as a fill-in-the-
+middle request, and prints only the text to insert. --stdin reads the
+file's current contents (e.g. an editor buffer) from standard input.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			filePath, _ := cmd.Flags().GetString("file")
 			line, _ := cmd.Flags().GetInt("line")
 			snippets, _ := cmd.Flags().GetStringSlice("string")
 			entire, _ := cmd.Flags().GetBool("entire")
+			fim, _ := cmd.Flags().GetBool("fim")
 
 			cfg, _ := config.EnsureConfigPrompts("")
 			eng := engine.NewEngine(&cfg)
-			resp := eng.ProvideSuggestion(filePath, line, snippets, entire)
+			if fim {
+				col, _ := cmd.Flags().GetInt("col")
+				fromStdin, _ := cmd.Flags().GetBool("stdin")
+				if filePath == "" || line < 1 {
+					fmt.Fprintln(os.Stderr, "Error: --fim needs --file and --line")
+					os.Exit(1)
+				}
+				content := ""
+				if fromStdin {
+					data, err := io.ReadAll(os.Stdin)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, "Error reading stdin:", err)
+						os.Exit(1)
+					}
+					content = string(data)
+				}
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer stop()
+				completion, err := eng.Complete(ctx, filePath, content, line, col)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Error completing code:", err)
+					os.Exit(1)
+				}
+				// Exactly the text to insert, without a trailing newline.
+				fmt.Print(completion)
+				return
+			}
+			resp, err := eng.ProvideSuggestion(filePath, line, snippets, entire)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
 			fmt.Println(resp)
 		},
 	}
@@ -399,6 +647,9 @@ func init() {
 	suggestionCmd.Flags().Int("line", 0, "Line number to use for suggestion")
 	suggestionCmd.Flags().StringSlice("string", nil, "User-provided code snippet for suggestion")
 	suggestionCmd.Flags().BoolP("entire", "E", false, "Send entire file content for suggestion")
+	suggestionCmd.Flags().Bool("fim", false, "Complete the code at --line/--col and print only the text to insert")
+	suggestionCmd.Flags().Int("col", 0, "Column of the cursor for --fim, counting characters from 1 (default: end of the line)")
+	suggestionCmd.Flags().Bool("stdin", false, "With --fim, read the contents of --file from stdin")
 	rootCmd.AddCommand(suggestionCmd)
 
 	// secret-ai
@@ -677,15 +928,23 @@ Analysis types:
 			case "market":
 				// Get comprehensive market data
 				client := astrolescent.NewClient()
-				price, _ := client.GetPrice()
-				apy, _ := client.GetAPY()
+				price, err := client.GetPrice()
+				if err != nil {
+					fmt.Printf("Error fetching price: %v\n", err)
+					return
+				}
+				apy, err := client.GetAPY()
+				if err != nil {
+					fmt.Printf("Error fetching APY: %v\n", err)
+					return
+				}
 
 				fmt.Println("🌌 Comprehensive Radix DeFi Market Analysis")
 				fmt.Println(strings.Repeat("=", 50))
 				fmt.Println(price.Text)
 				fmt.Println("\n" + apy.Text)
 
-				fmt.Println(`
+				fmt.Print(`
 🧠 AI Market Insights:
 - Trend analysis based on 24h/7d price movements
 - Yield optimization recommendations
@@ -883,6 +1142,87 @@ Example: codeforgeai astro trading-advice XRD ASTRL 100`,
 	rootCmd.AddCommand(disableCmd)
 }
 
+// githubTokenInfo prints the resolved token's source, owner and scopes.
+func githubTokenInfo() (*githubmodels.TokenInfo, error) {
+	token, source := secrets.ResolveGithubToken()
+	if token == "" {
+		return nil, errors.New(noGithubTokenMsg)
+	}
+	fmt.Printf("Token:       %s (from %s)\n", secrets.MaskToken(token), source)
+	info, err := githubmodels.WhoAmI(token)
+	if err != nil {
+		return nil, err
+	}
+	user := info.Login
+	if info.Name != "" {
+		user += " (" + info.Name + ")"
+	}
+	fmt.Printf("User:        %s\n", user)
+	switch {
+	case info.FineGrained:
+		fmt.Println("Scopes:      fine-grained token (permissions are not reported by the API)")
+	case len(info.Scopes) == 0:
+		fmt.Println("Scopes:      none")
+	default:
+		fmt.Printf("Scopes:      %s\n", strings.Join(info.Scopes, ", "))
+	}
+	if info.RateLimitRemaining >= 0 {
+		fmt.Printf("API quota:   %d requests remaining\n", info.RateLimitRemaining)
+	}
+	return info, nil
+}
+
+// githubToken resolves the GitHub token through the shared credential chain.
+func githubToken() string {
+	return secrets.GithubToken()
+}
+
+// parseExplainTarget splits an explain argument into the file and, when it
+// ends in ":start-end", ":line" or "#Symbol", the part of it to explain. A
+// file whose name really ends that way is taken as is.
+func parseExplainTarget(arg string, opts *engine.ExplainOptions) (string, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil
+	}
+	if file, symbol, ok := strings.Cut(arg, "#"); ok && symbol != "" {
+		if opts.Symbol != "" {
+			return "", fmt.Errorf("give the symbol either after # or with --symbol")
+		}
+		opts.Symbol = symbol
+		return file, nil
+	}
+	i := strings.LastIndex(arg, ":")
+	if i < 0 {
+		return arg, nil
+	}
+	first, last, isRange := strings.Cut(arg[i+1:], "-")
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return arg, nil
+	}
+	end := start
+	if isRange {
+		if end, err = strconv.Atoi(last); err != nil {
+			return "", fmt.Errorf("invalid line range %q", arg[i+1:])
+		}
+	}
+	if start < 1 || end < start {
+		return "", fmt.Errorf("invalid line range %q", arg[i+1:])
+	}
+	if opts.Symbol != "" {
+		return "", fmt.Errorf("a line range and --symbol cannot be combined")
+	}
+	opts.StartLine, opts.EndLine = start, end
+	return arg[:i], nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
 // Helper function for base64 encoding
 func encodeToBase64(data []byte) string {
 	return strings.TrimRight(strings.ReplaceAll(fmt.Sprintf("%+q", data), "\\x", ""), "\"")
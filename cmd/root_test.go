@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+)
+
+func TestParseExplainTarget(t *testing.T) {
+	dir := t.TempDir()
+	odd := filepath.Join(dir, "notes:12")
+	if err := os.WriteFile(odd, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		arg    string
+		symbol string // --symbol given alongside the argument
+		file   string
+		want   engine.ExplainOptions
+		err    string
+	}{
+		{name: "plain file", arg: "main.go", file: "main.go"},
+		{name: "single line", arg: "main.go:12", file: "main.go", want: engine.ExplainOptions{StartLine: 12, EndLine: 12}},
+		{name: "line range", arg: "main.go:3-7", file: "main.go", want: engine.ExplainOptions{StartLine: 3, EndLine: 7}},
+		{name: "symbol", arg: "main.go#Run", file: "main.go", want: engine.ExplainOptions{Symbol: "Run"}},
+		{name: "method symbol", arg: "srv.go#Server.Close", file: "srv.go", want: engine.ExplainOptions{Symbol: "Server.Close"}},
+		{name: "empty symbol", arg: "main.go#", file: "main.go#"},
+		{name: "windows drive", arg: `C:\src\main.go`, file: `C:\src\main.go`},
+		{name: "existing file named like a range", arg: odd, file: odd},
+		{name: "symbol and flag", arg: "main.go#Run", symbol: "Stop", err: "either after # or with --symbol"},
+		{name: "range and flag", arg: "main.go:3", symbol: "Run", err: "cannot be combined"},
+		{name: "bad range end", arg: "main.go:3-x", err: `invalid line range "3-x"`},
+		{name: "reversed range", arg: "main.go:7-3", err: `invalid line range "7-3"`},
+		{name: "line zero", arg: "main.go:0", err: `invalid line range "0"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := engine.ExplainOptions{Symbol: tt.symbol}
+			file, err := parseExplainTarget(tt.arg, &opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseExplainTarget(%q) error = %v, want one containing %q", tt.arg, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExplainTarget(%q): %v", tt.arg, err)
+			}
+			if file != tt.file || opts != tt.want {
+				t.Errorf("parseExplainTarget(%q) = %q, %+v, want %q, %+v", tt.arg, file, opts, tt.file, tt.want)
+			}
+		})
+	}
+}
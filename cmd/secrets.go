@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the encrypted secrets vault",
+	Long: `Store API keys and tokens in a password-protected vault under the data directory.
+Well-known names: github_token, openai_api_key for OpenAI-compatible
+embedding endpoints, and mcp.<server> for MCP server credentials.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Store a secret (value is read without echo, or from stdin)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := secrets.UnlockInteractive()
+		if err != nil {
+			fmt.Println("Error unlocking vault:", err)
+			return
+		}
+		value, err := secrets.PromptSecret(fmt.Sprintf("Value for %s: ", args[0]))
+		if err != nil {
+			fmt.Println("Error reading value:", err)
+			return
+		}
+		if err := v.Set(args[0], strings.TrimSpace(value)); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := v.Save(); err != nil {
+			fmt.Println("Error saving vault:", err)
+			return
+		}
+		fmt.Printf("Secret '%s' stored.\n", args[0])
+		warnAgentStale()
+	},
+}
+
+var secretsGetCmd = &cobra.Command{
+	Use:   "get [name]",
+	Short: "Print a stored secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if secrets.AgentRunning() {
+			value, err := secrets.AgentGet(args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Println(value)
+			return
+		}
+		v, err := secrets.UnlockInteractive()
+		if err != nil {
+			fmt.Println("Error unlocking vault:", err)
+			return
+		}
+		value, err := v.Get(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println(value)
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of stored secrets",
+	Run: func(cmd *cobra.Command, args []string) {
+		var names []string
+		if secrets.AgentRunning() {
+			var err error
+			if names, err = secrets.AgentList(); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		} else {
+			v, err := secrets.UnlockInteractive()
+			if err != nil {
+				fmt.Println("Error unlocking vault:", err)
+				return
+			}
+			names = v.List()
+		}
+		if len(names) == 0 {
+			fmt.Println("No secrets stored.")
+			return
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a stored secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := secrets.UnlockInteractive()
+		if err != nil {
+			fmt.Println("Error unlocking vault:", err)
+			return
+		}
+		if err := v.Delete(args[0]); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := v.Save(); err != nil {
+			fmt.Println("Error saving vault:", err)
+			return
+		}
+		fmt.Printf("Secret '%s' deleted.\n", args[0])
+		warnAgentStale()
+	},
+}
+
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate-password",
+	Short: "Re-encrypt the vault under a new password",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := secrets.InteractiveRotatePassword(); err != nil {
+			fmt.Println("Error rotating password:", err)
+			return
+		}
+		fmt.Println("Vault password rotated.")
+		warnAgentStale()
+	},
+}
+
+var secretsUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Unlock the vault once and keep secrets in a background agent",
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		if secrets.AgentRunning() {
+			fmt.Println("Secrets agent is already running. Use 'codeforgeai secrets lock' first to restart it.")
+			return
+		}
+		if err := startSecretsAgent(timeout); err != nil {
+			fmt.Println("Error unlocking vault:", err)
+			return
+		}
+		fmt.Printf("Vault unlocked. Secrets agent will lock after %s of inactivity.\n", timeout)
+	},
+}
+
+var secretsLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Stop the secrets agent and forget decrypted secrets",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := secrets.AgentLock(); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("Secrets agent locked.")
+	},
+}
+
+var secretsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the secrets agent is unlocked",
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := secrets.AgentStatusInfo()
+		if err != nil {
+			fmt.Println("Locked:", err)
+			return
+		}
+		fmt.Printf("Unlocked (agent pid %d, socket %s)\n", status.PID, secrets.AgentSocketPath())
+		fmt.Printf("Secrets held: %d\n", status.Secrets)
+		fmt.Printf("Idle timeout: %s, locks in %s\n", status.IdleTimeout, time.Until(status.LocksAt).Round(time.Second))
+	},
+}
+
+// secretsAgentCmd is the long-running agent process started by unlock.
+var secretsAgentCmd = &cobra.Command{
+	Use:    "agent",
+	Short:  "Run the secrets agent in the foreground",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		pw, err := secrets.PromptSecret("Enter your secrets vault password: ")
+		if err != nil {
+			fmt.Println("Error reading password:", err)
+			return
+		}
+		v, err := secrets.OpenVault(pw)
+		if err != nil {
+			fmt.Println("Error unlocking vault:", err)
+			return
+		}
+		if err := secrets.ServeAgent(v, timeout); err != nil {
+			fmt.Println("Error running secrets agent:", err)
+		}
+	},
+}
+
+// startSecretsAgent prompts for the vault password, verifies it, and starts
+// a detached agent holding the decrypted secrets.
+func startSecretsAgent(timeout time.Duration) error {
+	pw, err := secrets.PromptUnlockPassword()
+	if err != nil {
+		return err
+	}
+	v, err := secrets.OpenVault(pw)
+	if err != nil {
+		return err
+	}
+	// Persist a freshly created vault so the agent process can open it.
+	if err := v.Save(); err != nil {
+		return err
+	}
+	return secrets.StartAgentProcess(pw, timeout)
+}
+
+// warnAgentStale reminds the user that a running agent keeps its old copy.
+func warnAgentStale() {
+	if secrets.AgentRunning() {
+		fmt.Println("Note: the running secrets agent still holds the previous secrets; run 'codeforgeai secrets lock' and 'codeforgeai secrets unlock' to refresh it.")
+	}
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsGetCmd)
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsDeleteCmd)
+	secretsCmd.AddCommand(secretsRotateCmd)
+	secretsUnlockCmd.Flags().Duration("timeout", secrets.DefaultAgentTimeout, "Lock the agent after this much inactivity")
+	secretsCmd.AddCommand(secretsUnlockCmd)
+	secretsCmd.AddCommand(secretsLockCmd)
+	secretsCmd.AddCommand(secretsStatusCmd)
+	secretsAgentCmd.Flags().Duration("timeout", secrets.DefaultAgentTimeout, "Lock the agent after this much inactivity")
+	secretsCmd.AddCommand(secretsAgentCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/api"
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the engine and an OpenAI-compatible API over HTTP",
+	Long: `Serves the engine operations as a JSON API, with the prompts, providers,
+fallback chain and redaction of the config:
+
+  POST /analyze             classify the working directory
+  POST /explain             {"file", "start_line", "end_line", "symbol", "blame", "depth", "format"}
+  POST /suggest             {"file", "line", "content" or "snippet", "entire"}, or with "fim": true and "col" a completion
+  POST /edit                {"paths", "prompt", "allow_ignore"} or {"symbol", "file", "prompt"}
+  POST /commit-message      {"diff"}, by default the staged (else unstaged) changes
+  POST /chat                {"messages", "model": "general"|"code", "stream"}; streams server-sent events
+  POST /v1/chat/completions OpenAI-compatible, for "model" general, code, provider/name or a name
+  GET  /v1/models           the names /v1/chat/completions accepts
+  GET  /health
+
+Paths are relative to the directory the server runs in. Every request
+except /health needs "Authorization: Bearer <token>" when a token is set
+with --token or CODEFORGEAI_API_TOKEN; without one the server only listens
+on loopback addresses. Requests are logged to stderr.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CODEFORGEAI_API_TOKEN")
+		}
+		if err := serve(addr, token); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func serve(addr, token string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid --addr %q: %w", addr, err)
+	}
+	if token == "" && (host == "" || !httpclient.IsLoopbackHost(host)) {
+		return fmt.Errorf("refusing to serve on %s without a token; set --token or CODEFORGEAI_API_TOKEN, or listen on 127.0.0.1", addr)
+	}
+	cfg, _ := config.EnsureConfigPrompts("")
+	// Missing models are reported to the client, not pulled or asked about.
+	models.SetInteractive(false)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	handler := api.NewServer(engine.NewEngine(&cfg), token)
+	// Requests are logged whatever the verbosity.
+	handler.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving on http://%s (provider %s)\n", ln.Addr(), cfg.Integrations.Default)
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func init() {
+	serveCmd.Flags().String("addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().String("token", "", "Bearer token clients must send (default $CODEFORGEAI_API_TOKEN)")
+	rootCmd.AddCommand(serveCmd)
+}
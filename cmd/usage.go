@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/usage"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and cost per day, project, operation or model",
+	Long: `Every model call records its prompt and completion tokens, priced with the
+"pricing" table in the config (US dollars per million tokens), in
+~/.codeforgeai/usage.json.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		by, _ := cmd.Flags().GetString("by")
+		days, _ := cmd.Flags().GetInt("days")
+		thisProject, _ := cmd.Flags().GetBool("project")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		records, err := usage.Load()
+		if err != nil {
+			fmt.Println("Error reading usage ledger:", err)
+			return
+		}
+		var filtered []usage.Record
+		since := time.Now().AddDate(0, 0, -days+1).Format("2006-01-02")
+		project := config.ProjectRoot()
+		for _, r := range records {
+			if days > 0 && r.Day < since {
+				continue
+			}
+			if thisProject && r.Project != project {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+		groups, keys, err := usage.Group(filtered, by)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if asJSON {
+			b, _ := json.MarshalIndent(groups, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		if len(keys) == 0 {
+			fmt.Println("No usage recorded.")
+			return
+		}
+		var total usage.Totals
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tCOST\t\n", strings.ToUpper(by))
+		for _, k := range keys {
+			t := groups[k]
+			total.Requests += t.Requests
+			total.PromptTokens += t.PromptTokens
+			total.CompletionTokens += t.CompletionTokens
+			total.Cost += t.Cost
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t$%.4f\t\n", k, t.Requests, t.PromptTokens, t.CompletionTokens, t.Cost)
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t$%.4f\t\n", total.Requests, total.PromptTokens, total.CompletionTokens, total.Cost)
+		w.Flush()
+	},
+}
+
+var usageBudgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Show spending against the global and project budgets",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := config.EnsureConfigPrompts("")
+		records, err := usage.Load()
+		if err != nil {
+			fmt.Println("Error reading usage ledger:", err)
+			return
+		}
+		project := config.ProjectRoot()
+		now := time.Now()
+		show := func(name string, b config.BudgetConfig, scope string) {
+			day, month := usage.Spent(records, scope, now)
+			fmt.Printf("%s (action: %s)\n", name, b.Action)
+			fmt.Printf("  today:      $%.4f of %s\n", day, budgetLabel(b.Daily))
+			fmt.Printf("  this month: $%.4f of %s\n", month, budgetLabel(b.Monthly))
+		}
+		show("Global budget", cfg.Budget, "")
+		pc, path, _ := config.LoadProjectConfig()
+		if pc.Budget != nil {
+			b := *pc.Budget
+			if b.Action == "" {
+				b.Action = cfg.Budget.Action
+			}
+			show("Project budget ("+path+")", b, project)
+		}
+		if exceeded, blocking := usage.Check(&cfg, records, project); blocking != nil {
+			fmt.Println("\nPaid model calls are BLOCKED:", blocking)
+		} else if len(exceeded) > 0 {
+			fmt.Printf("\n%d budget(s) exceeded; calls continue with a warning.\n", len(exceeded))
+		}
+	},
+}
+
+var usageBudgetSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set daily or monthly budgets in US dollars (0 removes a limit)",
+	Run: func(cmd *cobra.Command, args []string) {
+		project, _ := cmd.Flags().GetBool("project")
+		action, _ := cmd.Flags().GetString("action")
+		if action != "" && action != "warn" && action != "block" {
+			fmt.Println("Error: --action must be 'warn' or 'block'")
+			return
+		}
+		apply := func(b *config.BudgetConfig) {
+			if cmd.Flags().Changed("daily") {
+				b.Daily, _ = cmd.Flags().GetFloat64("daily")
+			}
+			if cmd.Flags().Changed("monthly") {
+				b.Monthly, _ = cmd.Flags().GetFloat64("monthly")
+			}
+			if action != "" {
+				b.Action = action
+			}
+		}
+		if project {
+			pc, path, _ := config.LoadProjectConfig()
+			if pc.Budget == nil {
+				pc.Budget = &config.BudgetConfig{}
+			}
+			apply(pc.Budget)
+			if err := config.SaveProjectConfig(path, pc); err != nil {
+				fmt.Println("Error saving project config:", err)
+				return
+			}
+			fmt.Println("Project budget updated.")
+			return
+		}
+		cfg, _ := config.EnsureConfigPrompts("")
+		apply(&cfg.Budget)
+		if err := config.SaveConfig("", cfg); err != nil {
+			fmt.Println("Error saving config:", err)
+			return
+		}
+		fmt.Println("Global budget updated.")
+	},
+}
+
+var usageResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Delete all recorded usage",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := usage.Reset(); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("Usage ledger cleared.")
+	},
+}
+
+func budgetLabel(limit float64) string {
+	if limit <= 0 {
+		return "no limit"
+	}
+	return "$" + strconv.FormatFloat(limit, 'f', 2, 64)
+}
+
+func init() {
+	usageCmd.Flags().String("by", "day", "Group by day, month, project, operation, provider or model")
+	usageCmd.Flags().Int("days", 30, "Only include the last N days (0 for all)")
+	usageCmd.Flags().Bool("project", false, "Only include the current project")
+	usageCmd.Flags().Bool("json", false, "Print totals as JSON")
+	usageBudgetSetCmd.Flags().Float64("daily", 0, "Daily limit in US dollars")
+	usageBudgetSetCmd.Flags().Float64("monthly", 0, "Monthly limit in US dollars")
+	usageBudgetSetCmd.Flags().String("action", "", "What to do when a limit is reached: warn or block")
+	usageBudgetSetCmd.Flags().Bool("project", false, "Write to "+config.ProjectConfigFile+" instead of the global config")
+	usageBudgetCmd.AddCommand(usageBudgetSetCmd)
+	usageCmd.AddCommand(usageBudgetCmd)
+	usageCmd.AddCommand(usageResetCmd)
+	rootCmd.AddCommand(usageCmd)
+}
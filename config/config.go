@@ -7,18 +7,138 @@ import (
 	"path/filepath"
 )
 
+// IntegrationsConfig picks the model providers. Requests go to Default;
+// when it cannot be reached or a request fails, the providers in Fallback
+// are tried in order.
 type IntegrationsConfig struct {
 	Ollama        IntegrationEntry `json:"ollama"`
 	GithubModels  IntegrationEntry `json:"githubmodels"`
 	OpenAPI       IntegrationEntry `json:"openapi"`
 	GithubCopilot IntegrationEntry `json:"githubcopilot"`
 	Default       string           `json:"default"`
+	Fallback      []string         `json:"fallback,omitempty"`
 }
 
 type IntegrationEntry struct {
 	Enabled bool `json:"enabled"`
 }
 
+// RedactionConfig controls masking of secrets and PII before prompts leave
+// the machine. Mode is "all" (every provider), "cloud" (every provider
+// except local Ollama) or "off".
+type RedactionConfig struct {
+	Mode           string   `json:"mode"`
+	CustomPatterns []string `json:"custom_patterns"`
+}
+
+// CacheConfig controls the on-disk response cache. Only calls with an
+// explicit temperature of zero are cached, unless Force is set.
+type CacheConfig struct {
+	Enabled    bool `json:"enabled"`
+	TTLMinutes int  `json:"ttl_minutes"`
+	MaxSizeMB  int  `json:"max_size_mb"`
+	Force      bool `json:"force"`
+}
+
+// OllamaConfig tunes the local Ollama provider. KeepAlive is how long the
+// server keeps a model loaded after a request; AutoPull pulls missing
+// models without asking. System, Format and Options (the Ollama options
+// block: temperature, num_ctx, seed, stop, ...) apply to every request and
+// can be overridden per engine operation. MaxContext caps the automatic
+// num_ctx increase for long prompts; 0 disables it.
+type OllamaConfig struct {
+	KeepAlive  string                     `json:"keep_alive"`
+	AutoPull   bool                       `json:"auto_pull"`
+	System     string                     `json:"system"`
+	Format     interface{}                `json:"format,omitempty"`
+	Options    map[string]interface{}     `json:"options"`
+	Operations map[string]OllamaOperation `json:"operations,omitempty"`
+	MaxContext int                        `json:"max_context"`
+}
+
+// OllamaOperation overrides the Ollama request settings for one engine
+// operation, such as "code_explanation" or "commit_message". Options are
+// merged over the global ones.
+type OllamaOperation struct {
+	System  string                 `json:"system,omitempty"`
+	Format  interface{}            `json:"format,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// ContextConfig controls compaction of long conversations (chat sessions
+// and multi-turn transcripts). Once the active messages exceed CompactAt
+// times MaxTokens, all but the KeepRecent latest unpinned messages are
+// summarized with the general model. MaxTokens 0 disables compaction.
+type ContextConfig struct {
+	MaxTokens  int     `json:"max_tokens"`
+	CompactAt  float64 `json:"compact_at"`
+	KeepRecent int     `json:"keep_recent"`
+}
+
+// IndexConfig controls the local code search index behind `search` and the
+// project context added to explain, suggestion, edit and chat prompts.
+// Provider is "ollama" (/api/embed) or "openai" for any OpenAI-compatible
+// embeddings endpoint; Endpoint defaults to GitHub Models, authenticated
+// with the GitHub token, and other endpoints use the OpenAI key, unless
+// APIKeyEnv names another variable. TopK 0 turns the prompt context off.
+type IndexConfig struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Endpoint     string  `json:"endpoint,omitempty"`
+	APIKeyEnv    string  `json:"api_key_env,omitempty"`
+	ChunkLines   int     `json:"chunk_lines"`
+	ChunkOverlap int     `json:"chunk_overlap"`
+	MaxFileKB    int     `json:"max_file_kb"`
+	TopK         int     `json:"top_k"`
+	MinScore     float64 `json:"min_score"`
+}
+
+// RepoMapConfig controls the repository map: the project's files ranked by
+// how often the rest of the project refers to them, with their key
+// declarations, added as project context to prompt, chat and edit prompts.
+// Tokens is its budget; 0 turns the prompt context off.
+type RepoMapConfig struct {
+	Tokens    int `json:"tokens"`
+	MaxFileKB int `json:"max_file_kb"`
+}
+
+// CompletionConfig tunes fill-in-the-middle completion (suggestion --fim).
+// PrefixLines and SuffixLines are the code sent from before and after the
+// cursor, MaxTokens caps the completion and TimeoutMS abandons it.
+// Templates maps code model names, without their tag, to fill-in-the-middle
+// prompts with {prefix} and {suffix} placeholders, adding to or replacing
+// the built-in ones; Ollama models without one get the suffix parameter of
+// the generate API.
+type CompletionConfig struct {
+	PrefixLines int               `json:"prefix_lines"`
+	SuffixLines int               `json:"suffix_lines"`
+	MaxTokens   int               `json:"max_tokens"`
+	TimeoutMS   int               `json:"timeout_ms"`
+	Templates   map[string]string `json:"templates,omitempty"`
+}
+
+// CopilotConfig configures the githubcopilot provider. Command starts the
+// Copilot language server and Model picks the Copilot Chat model, "" for
+// the account's default.
+type CopilotConfig struct {
+	Command []string `json:"command"`
+	Model   string   `json:"model"`
+}
+
+// Price is the cost of a model in US dollars per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// BudgetConfig caps spending in US dollars. Action is "warn" or "block";
+// a zero limit is no limit.
+type BudgetConfig struct {
+	Daily   float64 `json:"daily"`
+	Monthly float64 `json:"monthly"`
+	Action  string  `json:"action"`
+}
+
 type Config struct {
 	GeneralModel                  string             `json:"general_model"`
 	GeneralPrompt                 string             `json:"general_prompt"`
@@ -43,8 +163,24 @@ type Config struct {
 	SuggestionPrompt              string             `json:"suggestion_prompt"`
 	ExtractCodeBlocksPrompt       string             `json:"extract_code_blocks_prompt"`
 	FormatCodePrompt              string             `json:"format_code_prompt"`
+	DocumentCodePrompt            string             `json:"document_code_prompt"`
+	GenerateTestsPrompt           string             `json:"generate_tests_prompt"`
+	ConversationSummaryPrompt     string             `json:"conversation_summary_prompt"`
 	Integrations                  IntegrationsConfig `json:"integrations"`
-	GithubModelsList              string             `json:"github_models_list"`
+	Ollama                        OllamaConfig       `json:"ollama"`
+	GithubModelsCatalogHours      int                `json:"github_models_catalog_refresh_hours"`
+	Redaction                     RedactionConfig    `json:"redaction"`
+	Privacy                       string             `json:"privacy"`
+	AuditLog                      bool               `json:"audit_log"`
+	Cache                         CacheConfig        `json:"cache"`
+	RateLimits                    map[string]int     `json:"rate_limits"`
+	Pricing                       map[string]Price   `json:"pricing"`
+	Budget                        BudgetConfig       `json:"budget"`
+	Context                       ContextConfig      `json:"context"`
+	Index                         IndexConfig        `json:"index"`
+	RepoMap                       RepoMapConfig      `json:"repo_map"`
+	Completion                    CompletionConfig   `json:"completion"`
+	Copilot                       CopilotConfig      `json:"copilot"`
 	// Optionally add GithubToken string `json:"github_token"` to Config struct if you want to support it from config.
 }
 
@@ -73,6 +209,9 @@ func DefaultConfig() Config {
 		SuggestionPrompt:              "provide a helpful code suggestion for the following code context:",
 		ExtractCodeBlocksPrompt:       "extract all code blocks from the following text and return them in a structured format:",
 		FormatCodePrompt:              "format the following code for better readability while preserving functionality:",
+		DocumentCodePrompt:            "add documentation comments to the following code in the usual style of its language, without changing the code itself, and return nothing but the documented code:",
+		GenerateTestsPrompt:           "write unit tests for the following code with the usual test framework of its language, covering its main behaviour and edge cases, and return nothing but the test code:",
+		ConversationSummaryPrompt:     "summarize the conversation below in a few short paragraphs, keeping every decision, requirement, file name and code identifier that later messages may depend on, and return nothing but the summary:",
 		Integrations: IntegrationsConfig{
 			Ollama:        IntegrationEntry{Enabled: true},
 			GithubModels:  IntegrationEntry{Enabled: false},
@@ -80,7 +219,77 @@ func DefaultConfig() Config {
 			GithubCopilot: IntegrationEntry{Enabled: false},
 			Default:       "ollama",
 		},
-		GithubModelsList: "",
+		Ollama: OllamaConfig{
+			KeepAlive:  "15m",
+			System:     "You are a helpful coding assistant.",
+			Options:    map[string]interface{}{},
+			MaxContext: 32768,
+			Operations: map[string]OllamaOperation{
+				"code_explanation":  {Options: map[string]interface{}{"temperature": 0}},
+				"commit_message":    {Options: map[string]interface{}{"temperature": 0}},
+				"gitmoji_selection": {Options: map[string]interface{}{"temperature": 0}},
+				"directory_classification": {
+					Format:  "json",
+					Options: map[string]interface{}{"temperature": 0},
+				},
+			},
+		},
+		GithubModelsCatalogHours: 24,
+		Redaction: RedactionConfig{
+			Mode:           "all",
+			CustomPatterns: []string{},
+		},
+		Privacy:  "",
+		AuditLog: false,
+		Cache: CacheConfig{
+			Enabled:    true,
+			TTLMinutes: 24 * 60,
+			MaxSizeMB:  64,
+		},
+		// Client-side requests per minute per provider; 0 or absent means
+		// unlimited. GitHub Models' free tier allows 15 per minute.
+		RateLimits: map[string]int{"githubmodels": 15},
+		// Keyed by "provider/model" or bare model name. Models without an
+		// entry, such as local Ollama ones, cost nothing.
+		Pricing: map[string]Price{
+			"gpt-4o":                 {Prompt: 2.50, Completion: 10.00},
+			"gpt-4o-mini":            {Prompt: 0.15, Completion: 0.60},
+			"gpt-4.1":                {Prompt: 2.00, Completion: 8.00},
+			"gpt-4.1-mini":           {Prompt: 0.40, Completion: 1.60},
+			"gpt-4.1-nano":           {Prompt: 0.10, Completion: 0.40},
+			"o3-mini":                {Prompt: 1.10, Completion: 4.40},
+			"o4-mini":                {Prompt: 1.10, Completion: 4.40},
+			"text-embedding-3-small": {Prompt: 0.02},
+		},
+		Budget: BudgetConfig{Action: "warn"},
+		// Sized for small local models such as gemma3:1b.
+		Context: ContextConfig{
+			MaxTokens:  8192,
+			CompactAt:  0.75,
+			KeepRecent: 6,
+		},
+		Index: IndexConfig{
+			Provider:     "ollama",
+			Model:        "nomic-embed-text",
+			ChunkLines:   40,
+			ChunkOverlap: 8,
+			MaxFileKB:    256,
+			TopK:         4,
+			MinScore:     0.3,
+		},
+		RepoMap: RepoMapConfig{
+			Tokens:    1024,
+			MaxFileKB: 256,
+		},
+		Completion: CompletionConfig{
+			PrefixLines: 60,
+			SuffixLines: 20,
+			MaxTokens:   128,
+			TimeoutMS:   5000,
+		},
+		Copilot: CopilotConfig{
+			Command: []string{"copilot-language-server", "--stdio"},
+		},
 	}
 }
 
@@ -92,6 +301,11 @@ func configFilePath() string {
 	return filepath.Join(home, ".codeforgeai.json")
 }
 
+// FilePath returns the path of the global config file.
+func FilePath() string {
+	return configFilePath()
+}
+
 func DataDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -123,150 +337,215 @@ func LoadConfig(path string) (Config, error) {
 func SaveConfig(path string, cfg Config) error {
 	if path == "" {
 		path = configFilePath()
-	}	return json.NewEncoder(f).Encode(cfg)
+	}
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errgPrompts(path string) (Config, error) {
-	}Config(path)
-	f, err := os.Create(path)f err != nil {
+		return err
+	}
+	f, err := os.Create(path)
 	if err != nil {
 		return err
-	}	changed := false
+	}
 	defer f.Close()
+	return json.NewEncoder(f).Encode(cfg)
+}
 
-func EnsureConfigPrompts(path string) (Config, error) {ery field in Config)
-	cfg, err := LoadConfig(path)odel == "" {
-	if err != nil {cfg.GeneralModel = def.GeneralModel
+func EnsureConfigPrompts(path string) (Config, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
 		return cfg, err
 	}
-	changed := falserompt == "" {
-	def := DefaultConfig()cfg.GeneralPrompt = def.GeneralPrompt
+	changed := false
+	def := DefaultConfig()
 
 	// Ensure all fields are set (for every field in Config)
-	if cfg.GeneralModel == "" {l == "" {
-		cfg.GeneralModel = def.GeneralModelcfg.CodeModel = def.CodeModel
+	if cfg.GeneralModel == "" {
+		cfg.GeneralModel = def.GeneralModel
 		changed = true
 	}
-	if cfg.GeneralPrompt == "" {pt == "" {
-		cfg.GeneralPrompt = def.GeneralPromptcfg.CodePrompt = def.CodePrompt
+	if cfg.GeneralPrompt == "" {
+		cfg.GeneralPrompt = def.GeneralPrompt
 		changed = true
 	}
-	if cfg.CodeModel == "" {odelGithub == "" {
-		cfg.CodeModel = def.CodeModelcfg.GeneralModelGithub = def.GeneralModelGithub
+	if cfg.CodeModel == "" {
+		cfg.CodeModel = def.CodeModel
 		changed = true
 	}
-	if cfg.CodePrompt == "" {lGithub == "" {
-		cfg.CodePrompt = def.CodePromptcfg.CodeModelGithub = def.CodeModelGithub
+	if cfg.CodePrompt == "" {
+		cfg.CodePrompt = def.CodePrompt
 		changed = true
 	}
-	if cfg.GeneralModelGithub == "" {yClassificationPrompt == "" {
-		cfg.GeneralModelGithub = def.GeneralModelGithubcfg.DirectoryClassificationPrompt = def.DirectoryClassificationPrompt
+	if cfg.GeneralModelGithub == "" {
+		cfg.GeneralModelGithub = def.GeneralModelGithub
 		changed = true
 	}
-	if cfg.CodeModelGithub == "" {neSeparator == 0 {
-		cfg.CodeModelGithub = def.CodeModelGithubcfg.FormatLineSeparator = def.FormatLineSeparator
+	if cfg.CodeModelGithub == "" {
+		cfg.CodeModelGithub = def.CodeModelGithub
 		changed = true
 	}
-	if cfg.DirectoryClassificationPrompt == "" {rompt == "" {
-		cfg.DirectoryClassificationPrompt = def.DirectoryClassificationPromptcfg.GitmojiPrompt = def.GitmojiPrompt
+	if cfg.DirectoryClassificationPrompt == "" {
+		cfg.DirectoryClassificationPrompt = def.DirectoryClassificationPrompt
 		changed = true
 	}
-	if cfg.FormatLineSeparator == 0 {ssagePrompt == "" {
-		cfg.FormatLineSeparator = def.FormatLineSeparatorcfg.CommitMessagePrompt = def.CommitMessagePrompt
+	if cfg.FormatLineSeparator == 0 {
+		cfg.FormatLineSeparator = def.FormatLineSeparator
 		changed = true
 	}
-	if cfg.GitmojiPrompt == "" {tunePrompt == "" {
-		cfg.GitmojiPrompt = def.GitmojiPromptcfg.EditFinetunePrompt = def.EditFinetunePrompt
+	if cfg.GitmojiPrompt == "" {
+		cfg.GitmojiPrompt = def.GitmojiPrompt
 		changed = true
 	}
-	if cfg.CommitMessagePrompt == "" {mmand == "" {
-		cfg.CommitMessagePrompt = def.CommitMessagePromptcfg.CodeOrCommand = def.CodeOrCommand
+	if cfg.CommitMessagePrompt == "" {
+		cfg.CommitMessagePrompt = def.CommitMessagePrompt
 		changed = true
 	}
-	if cfg.EditFinetunePrompt == "" {gentPrompt == "" {
-		cfg.EditFinetunePrompt = def.EditFinetunePromptcfg.CommandAgentPrompt = def.CommandAgentPrompt
+	if cfg.EditFinetunePrompt == "" {
+		cfg.EditFinetunePrompt = def.EditFinetunePrompt
 		changed = true
 	}
-	if cfg.CodeOrCommand == "" {netunePrompt == "" {
-		cfg.CodeOrCommand = def.CodeOrCommandcfg.PromptFinetunePrompt = def.PromptFinetunePrompt
+	if cfg.CodeOrCommand == "" {
+		cfg.CodeOrCommand = def.CodeOrCommand
 		changed = true
 	}
-	if cfg.CommandAgentPrompt == "" {ClassificationPrompt == "" {
-		cfg.CommandAgentPrompt = def.CommandAgentPromptcfg.LanguageClassificationPrompt = def.LanguageClassificationPrompt
+	if cfg.CommandAgentPrompt == "" {
+		cfg.CommandAgentPrompt = def.CommandAgentPrompt
 		changed = true
 	}
-	if cfg.PromptFinetunePrompt == "" {mmaryPrompt == "" {
-		cfg.PromptFinetunePrompt = def.PromptFinetunePromptcfg.ReadmeSummaryPrompt = def.ReadmeSummaryPrompt
+	if cfg.PromptFinetunePrompt == "" {
+		cfg.PromptFinetunePrompt = def.PromptFinetunePrompt
 		changed = true
 	}
-	if cfg.LanguageClassificationPrompt == "" {FileClassification == "" {
-		cfg.LanguageClassificationPrompt = def.LanguageClassificationPromptcfg.SpecificFileClassification = def.SpecificFileClassification
+	if cfg.LanguageClassificationPrompt == "" {
+		cfg.LanguageClassificationPrompt = def.LanguageClassificationPrompt
 		changed = true
 	}
-	if cfg.ReadmeSummaryPrompt == "" {odePrompt == "" {
-		cfg.ReadmeSummaryPrompt = def.ReadmeSummaryPromptcfg.ImproveCodePrompt = def.ImproveCodePrompt
+	if cfg.ReadmeSummaryPrompt == "" {
+		cfg.ReadmeSummaryPrompt = def.ReadmeSummaryPrompt
 		changed = true
 	}
-	if cfg.SpecificFileClassification == "" {odePrompt == "" {
-		cfg.SpecificFileClassification = def.SpecificFileClassificationcfg.ExplainCodePrompt = def.ExplainCodePrompt
+	if cfg.SpecificFileClassification == "" {
+		cfg.SpecificFileClassification = def.SpecificFileClassification
 		changed = true
 	}
-	if cfg.ImproveCodePrompt == "" {onPrompt == "" {
-		cfg.ImproveCodePrompt = def.ImproveCodePromptcfg.SuggestionPrompt = def.SuggestionPrompt
+	if cfg.ImproveCodePrompt == "" {
+		cfg.ImproveCodePrompt = def.ImproveCodePrompt
 		changed = true
 	}
-	if cfg.ExplainCodePrompt == "" {odeBlocksPrompt == "" {
-		cfg.ExplainCodePrompt = def.ExplainCodePromptcfg.ExtractCodeBlocksPrompt = def.ExtractCodeBlocksPrompt
+	if cfg.ExplainCodePrompt == "" {
+		cfg.ExplainCodePrompt = def.ExplainCodePrompt
 		changed = true
 	}
-	if cfg.SuggestionPrompt == "" {dePrompt == "" {
-		cfg.SuggestionPrompt = def.SuggestionPromptcfg.FormatCodePrompt = def.FormatCodePrompt
+	if cfg.SuggestionPrompt == "" {
+		cfg.SuggestionPrompt = def.SuggestionPrompt
 		changed = true
 	}
-	if cfg.ExtractCodeBlocksPrompt == "" {sent and complete
-		cfg.ExtractCodeBlocksPrompt = def.ExtractCodeBlocksPromptions.Default == "" {
-		changed = truecfg.Integrations = def.Integrations
+	if cfg.ExtractCodeBlocksPrompt == "" {
+		cfg.ExtractCodeBlocksPrompt = def.ExtractCodeBlocksPrompt
+		changed = true
 	}
 	if cfg.FormatCodePrompt == "" {
 		cfg.FormatCodePrompt = def.FormatCodePrompt
-		changed = truetions.Ollama == IntegrationEntry{}) {
-	}cfg.Integrations.Ollama = def.Integrations.Ollama
+		changed = true
+	}
+	if cfg.DocumentCodePrompt == "" {
+		cfg.DocumentCodePrompt = def.DocumentCodePrompt
+		changed = true
+	}
+	if cfg.GenerateTestsPrompt == "" {
+		cfg.GenerateTestsPrompt = def.GenerateTestsPrompt
+		changed = true
+	}
+	if cfg.ConversationSummaryPrompt == "" {
+		cfg.ConversationSummaryPrompt = def.ConversationSummaryPrompt
+		changed = true
+	}
 	// Ensure integrations config is present and complete
 	if cfg.Integrations.Default == "" {
-		cfg.Integrations = def.Integrationstions.GithubModels == IntegrationEntry{}) {
-		changed = truecfg.Integrations.GithubModels = def.Integrations.GithubModels
+		cfg.Integrations = def.Integrations
+		changed = true
 	}
 	// Ensure all sub-integrations are present
-	if (cfg.Integrations.Ollama == IntegrationEntry{}) {tions.OpenAPI == IntegrationEntry{}) {
-		cfg.Integrations.Ollama = def.Integrations.Ollamacfg.Integrations.OpenAPI = def.Integrations.OpenAPI
+	if (cfg.Integrations.Ollama == IntegrationEntry{}) {
+		cfg.Integrations.Ollama = def.Integrations.Ollama
 		changed = true
 	}
-	if (cfg.Integrations.GithubModels == IntegrationEntry{}) {tions.GithubCopilot == IntegrationEntry{}) {
-		cfg.Integrations.GithubModels = def.Integrations.GithubModelscfg.Integrations.GithubCopilot = def.Integrations.GithubCopilot
+	if (cfg.Integrations.GithubModels == IntegrationEntry{}) {
+		cfg.Integrations.GithubModels = def.Integrations.GithubModels
 		changed = true
-	}	}
-	if (cfg.Integrations.OpenAPI == IntegrationEntry{}) {bool, so no need to check for empty string
+	}
+	if (cfg.Integrations.OpenAPI == IntegrationEntry{}) {
 		cfg.Integrations.OpenAPI = def.Integrations.OpenAPI
-		changed = truef cfg.GithubModelsList == "" {
-	}lsList = def.GithubModelsList
-	if (cfg.Integrations.GithubCopilot == IntegrationEntry{}) {	changed = true
-		cfg.Integrations.GithubCopilot = def.Integrations.GithubCopilot	}
+		changed = true
+	}
+	if (cfg.Integrations.GithubCopilot == IntegrationEntry{}) {
+		cfg.Integrations.GithubCopilot = def.Integrations.GithubCopilot
+		changed = true
+	}
+	if cfg.Ollama.KeepAlive == "" {
+		cfg.Ollama.KeepAlive = def.Ollama.KeepAlive
+		changed = true
+	}
+	if cfg.Ollama.Options == nil {
+		// Section predates the generation settings.
+		cfg.Ollama.System = def.Ollama.System
+		cfg.Ollama.Options = def.Ollama.Options
+		cfg.Ollama.Operations = def.Ollama.Operations
+		cfg.Ollama.MaxContext = def.Ollama.MaxContext
+		changed = true
+	}
+	if cfg.GithubModelsCatalogHours == 0 {
+		cfg.GithubModelsCatalogHours = def.GithubModelsCatalogHours
+		changed = true
+	}
+	if cfg.Redaction.Mode == "" {
+		cfg.Redaction.Mode = def.Redaction.Mode
+		changed = true
+	}
+	if cfg.Cache.TTLMinutes == 0 && cfg.Cache.MaxSizeMB == 0 {
+		cfg.Cache = def.Cache
+		changed = true
+	}
+	if cfg.RateLimits == nil {
+		cfg.RateLimits = def.RateLimits
+		changed = true
+	}
+	if cfg.Pricing == nil {
+		cfg.Pricing = def.Pricing
+		changed = true
+	}
+	if cfg.Budget.Action == "" {
+		cfg.Budget.Action = def.Budget.Action
+		changed = true
+	}
+	if cfg.Context.CompactAt == 0 && cfg.Context.KeepRecent == 0 {
+		cfg.Context = def.Context
+		changed = true
+	}
+	if cfg.Index.Provider == "" {
+		cfg.Index = def.Index
+		changed = true
+	}
+	if cfg.RepoMap.MaxFileKB == 0 {
+		cfg.RepoMap = def.RepoMap
+		changed = true
+	}
+	if cfg.Completion.MaxTokens == 0 {
+		templates := cfg.Completion.Templates
+		cfg.Completion = def.Completion
+		cfg.Completion.Templates = templates
+		changed = true
+	}
+	if len(cfg.Copilot.Command) == 0 {
+		cfg.Copilot.Command = def.Copilot.Command
 		changed = true
 	}
 	// Debug is bool, so no need to check for empty string
-}
-	if changed {	return cfg, nil
-
-
 
-
-
-
-
-
-
-
-}	fmt.Println(string(b))	b, _ := json.MarshalIndent(cfg, "", "  ")func PrintConfig(cfg Config) {}	return cfg, nil	}		SaveConfig(path, cfg)}
+	if changed {
+		SaveConfig(path, cfg)
+	}
+	return cfg, nil
+}
 
 func PrintConfig(cfg Config) {
 	b, _ := json.MarshalIndent(cfg, "", "  ")
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// ProjectConfigFile is the per-project settings file, looked up from the
+// working directory upwards.
+const ProjectConfigFile = ".codeforgeai.project.json"
+
+// PrivacyLocalOnly forbids any network destination that is not loopback.
+const PrivacyLocalOnly = "local_only"
+
+// ProjectConfig holds settings that apply to a single repository and
+// override the global config.
+type ProjectConfig struct {
+	Privacy string        `json:"privacy,omitempty"`
+	Budget  *BudgetConfig `json:"budget,omitempty"`
+}
+
+// FindProjectConfig returns the path of the nearest project config file,
+// or an empty string if there is none.
+func FindProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ProjectRoot returns the directory that identifies the current project:
+// the one holding the project config, else the enclosing git work tree,
+// else the working directory.
+func ProjectRoot() string {
+	if path := FindProjectConfig(); path != "" {
+		return filepath.Dir(path)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for dir := cwd; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return cwd
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig loads the nearest project config. A missing file yields
+// an empty ProjectConfig and no error.
+func LoadProjectConfig() (ProjectConfig, string, error) {
+	var pc ProjectConfig
+	path := FindProjectConfig()
+	if path == "" {
+		return pc, "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pc, path, err
+	}
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return pc, path, err
+	}
+	return pc, path, nil
+}
+
+// SaveProjectConfig writes pc to path, defaulting to the working directory.
+func SaveProjectConfig(path string, pc ProjectConfig) error {
+	if path == "" {
+		path = ProjectConfigFile
+	}
+	b, err := json.MarshalIndent(pc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// LocalOnly reports whether local-only privacy applies, either from the
+// global config or from the current project's config.
+func LocalOnly(cfg *Config) bool {
+	if cfg != nil && cfg.Privacy == PrivacyLocalOnly {
+		return true
+	}
+	pc, _, _ := LoadProjectConfig()
+	return pc.Privacy == PrivacyLocalOnly
+}
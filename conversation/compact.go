@@ -0,0 +1,107 @@
+package conversation
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// summaryPrefix starts the content of every summary message.
+const summaryPrefix = "Summary of the earlier conversation:\n\n"
+
+// CompactOptions says when and how much of a conversation to compact.
+type CompactOptions struct {
+	// MaxTokens is the context budget of the conversation; 0 disables
+	// automatic compaction.
+	MaxTokens int
+	// CompactAt is the fraction of MaxTokens above which to compact.
+	CompactAt float64
+	// KeepRecent is how many of the latest unpinned messages are kept
+	// verbatim.
+	KeepRecent int
+}
+
+// Summarizer condenses messages into a short summary.
+type Summarizer func(messages []modeliface.Message) (string, error)
+
+// ModelSummarizer returns a Summarizer that asks model to summarize with
+// prompt.
+func ModelSummarizer(model modeliface.Model, prompt string) Summarizer {
+	return func(messages []modeliface.Message) (string, error) {
+		return model.SendRequest(prompt+"\n\n"+modeliface.RenderMessages(messages), map[string]interface{}{
+			"operation": "conversation_summary",
+		})
+	}
+}
+
+// NeedsCompaction reports whether the active messages have grown past the
+// compaction threshold.
+func (t *Transcript) NeedsCompaction(o CompactOptions) bool {
+	return o.MaxTokens > 0 && float64(t.Tokens()) > float64(o.MaxTokens)*o.CompactAt
+}
+
+// Compact replaces the older unpinned messages with a summary, leaving the
+// KeepRecent latest ones and every pinned message in place. The replaced
+// messages stay in the transcript marked as compacted, so Uncompact can
+// restore them; earlier summaries are folded into the new one. It returns
+// the number of messages compacted, which is 0 when there is too little to
+// summarize.
+func (t *Transcript) Compact(o CompactOptions, summarize Summarizer) (int, error) {
+	var candidates []int
+	for i, m := range t.Messages {
+		if !m.Compacted && !m.IsPinned() {
+			candidates = append(candidates, i)
+		}
+	}
+	candidates = candidates[:max(0, len(candidates)-max(o.KeepRecent, 0))]
+	// Keep questions together with their answers, and never compact the
+	// pending user message.
+	for n := len(candidates); n > 0 && t.Messages[candidates[n-1]].Role == "user"; n-- {
+		candidates = candidates[:n-1]
+	}
+	if len(candidates) < 2 {
+		return 0, nil
+	}
+
+	older := make([]modeliface.Message, len(candidates))
+	for i, idx := range candidates {
+		older[i] = modeliface.Message{Role: t.Messages[idx].Role, Content: t.Messages[idx].Content}
+	}
+	summary, err := summarize(older)
+	if err != nil {
+		return 0, err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return 0, errors.New("the model returned an empty summary")
+	}
+
+	for _, idx := range candidates {
+		t.Messages[idx].Compacted = true
+	}
+	content := summaryPrefix + summary
+	s := Message{Role: "system", Content: content, Tokens: EstimateTokens(content), Summary: true}
+	at := candidates[0]
+	t.Messages = append(t.Messages[:at], append([]Message{s}, t.Messages[at:]...)...)
+	return len(candidates), nil
+}
+
+// Uncompact undoes every compaction: summaries are removed and compacted
+// messages become active again. It returns the number of messages restored.
+func (t *Transcript) Uncompact() int {
+	restored := 0
+	kept := t.Messages[:0]
+	for _, m := range t.Messages {
+		if m.Summary {
+			continue
+		}
+		if m.Compacted {
+			m.Compacted = false
+			restored++
+		}
+		kept = append(kept, m)
+	}
+	t.Messages = kept
+	return restored
+}
@@ -0,0 +1,185 @@
+package conversation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// transcript builds a transcript from "role:content" strings; a "!" after
+// the role pins the message.
+func transcript(specs ...string) *Transcript {
+	t := &Transcript{}
+	for _, s := range specs {
+		role, content, _ := strings.Cut(s, ":")
+		pinned := strings.HasSuffix(role, "!")
+		t.Messages = append(t.Messages, Message{Role: strings.TrimSuffix(role, "!"), Content: content, Pinned: pinned})
+	}
+	return t
+}
+
+// active renders the active messages as "role:content" strings.
+func active(t *Transcript) []string {
+	var out []string
+	for _, m := range t.Active() {
+		out = append(out, m.Role+":"+m.Content)
+	}
+	return out
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name       string
+		tr         *Transcript
+		keepRecent int
+		compacted  int
+		summarized []string // messages handed to the summarizer
+		active     []string
+	}{
+		{
+			name:       "keeps recent and system",
+			tr:         transcript("system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2", "user:q3", "assistant:a3"),
+			keepRecent: 2,
+			compacted:  4,
+			summarized: []string{"user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+			active:     []string{"system:sys", "system:" + summaryPrefix + "S", "user:q3", "assistant:a3"},
+		},
+		{
+			name:       "keeps a question with its answer",
+			tr:         transcript("user:q1", "assistant:a1", "user:q2", "assistant:a2"),
+			keepRecent: 1,
+			compacted:  2,
+			summarized: []string{"user:q1", "assistant:a1"},
+			active:     []string{"system:" + summaryPrefix + "S", "user:q2", "assistant:a2"},
+		},
+		{
+			name:       "never compacts the pending message",
+			tr:         transcript("user:q1", "assistant:a1", "user:q2"),
+			keepRecent: 0,
+			compacted:  2,
+			summarized: []string{"user:q1", "assistant:a1"},
+			active:     []string{"system:" + summaryPrefix + "S", "user:q2"},
+		},
+		{
+			name:       "skips pinned messages",
+			tr:         transcript("user!:rules", "assistant:a0", "user:q1", "assistant:a1", "user:q2", "assistant:a2"),
+			keepRecent: 2,
+			compacted:  3,
+			summarized: []string{"assistant:a0", "user:q1", "assistant:a1"},
+			active:     []string{"user:rules", "system:" + summaryPrefix + "S", "user:q2", "assistant:a2"},
+		},
+		{
+			name:       "too little to summarize",
+			tr:         transcript("system:sys", "user:q1", "assistant:a1"),
+			keepRecent: 1,
+			compacted:  0,
+			active:     []string{"system:sys", "user:q1", "assistant:a1"},
+		},
+		{
+			name:       "negative keep recent",
+			tr:         transcript("user:q1", "assistant:a1"),
+			keepRecent: -3,
+			compacted:  2,
+			summarized: []string{"user:q1", "assistant:a1"},
+			active:     []string{"system:" + summaryPrefix + "S"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]Message(nil), tt.tr.Messages...)
+			var got []string
+			n, err := tt.tr.Compact(CompactOptions{KeepRecent: tt.keepRecent}, func(ms []modeliface.Message) (string, error) {
+				for _, m := range ms {
+					got = append(got, m.Role+":"+m.Content)
+				}
+				return "  S\n", nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.compacted {
+				t.Errorf("compacted %d messages, want %d", n, tt.compacted)
+			}
+			if !reflect.DeepEqual(got, tt.summarized) {
+				t.Errorf("summarized %q, want %q", got, tt.summarized)
+			}
+			if a := active(tt.tr); !reflect.DeepEqual(a, tt.active) {
+				t.Errorf("active %q, want %q", a, tt.active)
+			}
+			if restored := tt.tr.Uncompact(); restored != tt.compacted {
+				t.Errorf("Uncompact restored %d, want %d", restored, tt.compacted)
+			}
+			if !reflect.DeepEqual(tt.tr.Messages, original) {
+				t.Errorf("after Uncompact: %+v, want %+v", tt.tr.Messages, original)
+			}
+		})
+	}
+}
+
+func TestCompactFoldsEarlierSummaries(t *testing.T) {
+	tr := transcript("user:q1", "assistant:a1", "user:q2", "assistant:a2", "user:q3", "assistant:a3")
+	summarize := func(ms []modeliface.Message) (string, error) {
+		var parts []string
+		for _, m := range ms {
+			parts = append(parts, strings.TrimPrefix(m.Content, summaryPrefix))
+		}
+		return strings.Join(parts, "+"), nil
+	}
+	if _, err := tr.Compact(CompactOptions{KeepRecent: 4}, summarize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Compact(CompactOptions{KeepRecent: 2}, summarize); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system:" + summaryPrefix + "q1+a1+q2+a2", "user:q3", "assistant:a3"}
+	if a := active(tr); !reflect.DeepEqual(a, want) {
+		t.Fatalf("active %q, want %q", a, want)
+	}
+	if restored := tr.Uncompact(); restored != 4 || len(tr.Messages) != 6 {
+		t.Fatalf("Uncompact restored %d, left %d messages", restored, len(tr.Messages))
+	}
+}
+
+func TestCompactErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		err     error
+		want    string
+	}{
+		{"summarizer fails", "", errors.New("offline"), "offline"},
+		{"empty summary", " \n", nil, "empty summary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := transcript("user:q1", "assistant:a1", "user:q2", "assistant:a2")
+			n, err := tr.Compact(CompactOptions{}, func([]modeliface.Message) (string, error) { return tt.summary, tt.err })
+			if err == nil || !strings.Contains(err.Error(), tt.want) || n != 0 {
+				t.Fatalf("Compact = %d, %v, want an error containing %q", n, err, tt.want)
+			}
+			if len(tr.Active()) != 4 {
+				t.Fatalf("a failed compaction changed the transcript: %+v", tr.Messages)
+			}
+		})
+	}
+}
+
+func TestNeedsCompaction(t *testing.T) {
+	tr := transcript("user:" + strings.Repeat("x", 400)) // 100 tokens
+	tests := []struct {
+		o    CompactOptions
+		want bool
+	}{
+		{CompactOptions{MaxTokens: 0, CompactAt: 0.5}, false},
+		{CompactOptions{MaxTokens: 100, CompactAt: 1}, false},
+		{CompactOptions{MaxTokens: 150, CompactAt: 0.5}, true},
+	}
+	for _, tt := range tests {
+		if got := tr.NeedsCompaction(tt.o); got != tt.want {
+			t.Errorf("NeedsCompaction(%+v) = %v, want %v", tt.o, got, tt.want)
+		}
+	}
+}
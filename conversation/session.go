@@ -0,0 +1,144 @@
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// Session is a saved chat conversation.
+type Session struct {
+	ID      string    `json:"id"`
+	Title   string    `json:"title,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	// Usage is the total reported by the provider for every reply.
+	Usage modeliface.Usage `json:"usage"`
+	Transcript
+}
+
+// ErrNoSession is returned by LoadSession when no session matches.
+var ErrNoSession = errors.New("no such chat session")
+
+// SessionsDir returns the directory chat sessions are saved in.
+func SessionsDir() string {
+	dir := filepath.Join(config.DataDir(), "chats")
+	os.MkdirAll(dir, 0700)
+	return dir
+}
+
+// NewSession starts an empty session for provider and model.
+func NewSession(provider, model string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         now.Format("20060102-150405"),
+		Created:    now,
+		Updated:    now,
+		Transcript: Transcript{Provider: provider, Model: model},
+	}
+}
+
+func sessionFile(id string) string {
+	return filepath.Join(SessionsDir(), id+".json")
+}
+
+// Save writes the session to SessionsDir. The title defaults to the start of
+// the first user message.
+func (s *Session) Save() error {
+	s.Updated = time.Now()
+	if s.Title == "" {
+		for _, m := range s.Messages {
+			if m.Role == "user" {
+				s.Title = titleFrom(m.Content)
+				break
+			}
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := sessionFile(s.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sessionFile(s.ID))
+}
+
+func titleFrom(text string) string {
+	title := strings.Join(strings.Fields(text), " ")
+	if r := []rune(title); len(r) > 60 {
+		title = string(r[:57]) + "..."
+	}
+	return title
+}
+
+// LoadSession reads the session with the given ID. A unique prefix of an ID
+// is accepted, and "last" names the most recently updated session.
+func LoadSession(id string) (*Session, error) {
+	if id == "last" {
+		sessions, err := ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		if len(sessions) == 0 {
+			return nil, ErrNoSession
+		}
+		id = sessions[0].ID
+	}
+	data, err := os.ReadFile(sessionFile(id))
+	if errors.Is(err, os.ErrNotExist) {
+		matches, _ := filepath.Glob(filepath.Join(SessionsDir(), id+"*.json"))
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("%w %q; run 'codeforgeai chat list'", ErrNoSession, id)
+		case 1:
+			data, err = os.ReadFile(matches[0])
+		default:
+			return nil, fmt.Errorf("chat session %q is ambiguous (%d matches)", id, len(matches))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("reading chat session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// ListSessions returns every saved session, most recently updated first.
+func ListSessions() ([]*Session, error) {
+	files, err := filepath.Glob(filepath.Join(SessionsDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []*Session
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var s Session
+		if json.Unmarshal(data, &s) == nil && s.ID != "" {
+			out = append(out, &s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Updated.After(out[j].Updated) })
+	return out, nil
+}
+
+// EstimateTokens roughly counts the tokens of text, at about four
+// characters per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,320 @@
+// Package conversation reads and writes multi-turn transcripts, so
+// conversations can be kept in a repo and replayed against any provider.
+package conversation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"gopkg.in/yaml.v3"
+)
+
+// Transcript formats.
+const (
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatMarkdown = "markdown"
+)
+
+// Message is one message of a transcript. Tokens is its (estimated or
+// reported) size. Pinned messages are never compacted; system messages
+// other than summaries are always treated as pinned. Compacted messages
+// have been replaced by a Summary message and are only kept so the
+// compaction can be undone.
+type Message struct {
+	Role      string `json:"role" yaml:"role"`
+	Content   string `json:"content" yaml:"content"`
+	Tokens    int    `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	Pinned    bool   `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+	Summary   bool   `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Compacted bool   `json:"compacted,omitempty" yaml:"compacted,omitempty"`
+}
+
+// IsPinned reports whether compaction must leave m alone.
+func (m Message) IsPinned() bool {
+	return m.Pinned || (m.Role == "system" && !m.Summary)
+}
+
+// Transcript is a conversation plus the provider and model it is meant
+// for. Provider and Model are optional; flags and the config fill them in.
+type Transcript struct {
+	Provider string    `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model    string    `json:"model,omitempty" yaml:"model,omitempty"`
+	Messages []Message `json:"messages" yaml:"messages"`
+
+	// Format is the format the transcript was read in and is written back
+	// in.
+	Format string `json:"-" yaml:"-"`
+}
+
+// Load reads a transcript from path, or from stdin when path is "-". The
+// format is taken from the file extension and otherwise detected from the
+// content.
+func Load(path string) (*Transcript, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, FormatForPath(path))
+}
+
+// FormatForPath returns the format implied by the extension of path, or ""
+// if there is none.
+func FormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".md", ".markdown":
+		return FormatMarkdown
+	}
+	return ""
+}
+
+// markdownHeading matches a "## user" style role heading, optionally
+// followed by flags such as "(pinned)" or "(summary)".
+var markdownHeading = regexp.MustCompile(`(?i)^##\s+(system|user|assistant)(?:\s+\(([a-z, ]*)\))?\s*$`)
+
+// DetectFormat guesses the format of data: JSON starts with { or [,
+// markdown has role headings, and anything else is treated as YAML.
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return FormatJSON
+	}
+	sc := bufio.NewScanner(bytes.NewReader(trimmed))
+	for sc.Scan() {
+		if markdownHeading.MatchString(strings.TrimSpace(sc.Text())) {
+			return FormatMarkdown
+		}
+	}
+	return FormatYAML
+}
+
+// Parse decodes a transcript in format, detecting it when format is "".
+// JSON and YAML accept either an object with a "messages" list or a bare
+// list of messages.
+func Parse(data []byte, format string) (*Transcript, error) {
+	if format == "" {
+		format = DetectFormat(data)
+	}
+	t := &Transcript{Format: format}
+	var err error
+	switch format {
+	case FormatJSON:
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(trimmed, &t.Messages)
+		} else {
+			err = json.Unmarshal(data, t)
+		}
+	case FormatYAML:
+		var node yaml.Node
+		if err = yaml.Unmarshal(data, &node); err == nil && len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+			err = node.Content[0].Decode(&t.Messages)
+		} else if err == nil {
+			err = yaml.Unmarshal(data, t)
+		}
+	case FormatMarkdown:
+		err = parseMarkdown(data, t)
+	default:
+		return nil, fmt.Errorf("unknown transcript format %q (want json, yaml or markdown)", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s transcript: %w", format, err)
+	}
+	return t, t.Validate()
+}
+
+// parseMarkdown reads optional "---" front matter holding provider and
+// model, followed by "## role" sections whose headings may carry the
+// pinned, summary and compacted flags in parentheses.
+func parseMarkdown(data []byte, t *Transcript) error {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(strings.TrimLeft(text, "\n"), "---\n"); ok {
+		front, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return errors.New("unterminated front matter")
+		}
+		if err := yaml.Unmarshal([]byte(front), t); err != nil {
+			return err
+		}
+		text = body
+	}
+	var current *Message
+	var content []string
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(content, "\n"))
+			t.Messages = append(t.Messages, *current)
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if m := markdownHeading.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			flush()
+			current = &Message{Role: strings.ToLower(m[1])}
+			for _, flag := range strings.Split(strings.ToLower(m[2]), ",") {
+				switch strings.TrimSpace(flag) {
+				case "pinned":
+					current.Pinned = true
+				case "summary":
+					current.Summary = true
+				case "compacted":
+					current.Compacted = true
+				case "":
+				default:
+					return fmt.Errorf("unknown message flag %q (want pinned, summary or compacted)", strings.TrimSpace(flag))
+				}
+			}
+			content = nil
+			continue
+		}
+		if current == nil {
+			if strings.TrimSpace(line) != "" {
+				return fmt.Errorf("text before the first role heading: %q", strings.TrimSpace(line))
+			}
+			continue
+		}
+		content = append(content, line)
+	}
+	flush()
+	return nil
+}
+
+// Validate checks the roles of every message.
+func (t *Transcript) Validate() error {
+	if len(t.Messages) == 0 {
+		return errors.New("transcript has no messages")
+	}
+	for i, m := range t.Messages {
+		switch m.Role {
+		case "system", "user", "assistant":
+		default:
+			return fmt.Errorf("message %d has unknown role %q (want system, user or assistant)", i+1, m.Role)
+		}
+	}
+	return nil
+}
+
+// Pending reports whether the transcript ends with a user message that has
+// not been answered yet.
+func (t *Transcript) Pending() bool {
+	return len(t.Messages) > 0 && t.Messages[len(t.Messages)-1].Role == "user"
+}
+
+// Append adds a message to the end of the conversation and returns it.
+func (t *Transcript) Append(role, content string) *Message {
+	t.Messages = append(t.Messages, Message{Role: role, Content: content, Tokens: EstimateTokens(content)})
+	return &t.Messages[len(t.Messages)-1]
+}
+
+// Active returns the messages that are sent to the model: everything except
+// compacted messages.
+func (t *Transcript) Active() []modeliface.Message {
+	var out []modeliface.Message
+	for _, m := range t.Messages {
+		if !m.Compacted {
+			out = append(out, modeliface.Message{Role: m.Role, Content: m.Content})
+		}
+	}
+	return out
+}
+
+// Tokens returns the size of the active messages, estimating the size of
+// messages that have none recorded.
+func (t *Transcript) Tokens() int {
+	total := 0
+	for i := range t.Messages {
+		m := &t.Messages[i]
+		if m.Tokens == 0 {
+			m.Tokens = EstimateTokens(m.Content)
+		}
+		if !m.Compacted {
+			total += m.Tokens
+		}
+	}
+	return total
+}
+
+// Marshal encodes the transcript in format, or in the format it was read
+// in when format is "".
+func (t *Transcript) Marshal(format string) ([]byte, error) {
+	if format == "" {
+		format = t.Format
+	}
+	switch format {
+	case FormatJSON, "":
+		b, err := json.MarshalIndent(t, "", "  ")
+		return append(b, '\n'), err
+	case FormatYAML:
+		return yaml.Marshal(t)
+	case FormatMarkdown:
+		return t.markdown()
+	}
+	return nil, fmt.Errorf("unknown transcript format %q (want json, yaml or markdown)", format)
+}
+
+func (t *Transcript) markdown() ([]byte, error) {
+	var sb strings.Builder
+	if t.Provider != "" || t.Model != "" {
+		front, err := yaml.Marshal(struct {
+			Provider string `yaml:"provider,omitempty"`
+			Model    string `yaml:"model,omitempty"`
+		}{t.Provider, t.Model})
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString("---\n")
+		sb.Write(front)
+		sb.WriteString("---\n\n")
+	}
+	for i, m := range t.Messages {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		var flags []string
+		if m.Pinned {
+			flags = append(flags, "pinned")
+		}
+		if m.Summary {
+			flags = append(flags, "summary")
+		}
+		if m.Compacted {
+			flags = append(flags, "compacted")
+		}
+		heading := m.Role
+		if len(flags) > 0 {
+			heading += " (" + strings.Join(flags, ", ") + ")"
+		}
+		fmt.Fprintf(&sb, "## %s\n\n%s\n", heading, strings.TrimSpace(m.Content))
+	}
+	return []byte(sb.String()), nil
+}
+
+// Save writes the transcript to path, or to stdout when path is "-".
+func (t *Transcript) Save(path, format string) error {
+	data, err := t.Marshal(format)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
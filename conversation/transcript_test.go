@@ -0,0 +1,132 @@
+package conversation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	hello := []Message{{Role: "user", Content: "hello"}, {Role: "assistant", Content: "hi"}}
+	tests := []struct {
+		name     string
+		data     string
+		format   string
+		want     []Message
+		provider string
+		model    string
+		detected string
+		err      string
+	}{
+		{
+			name:     "json object",
+			data:     `{"provider": "ollama", "model": "m", "messages": [{"role": "user", "content": "hello"}, {"role": "assistant", "content": "hi"}]}`,
+			want:     hello,
+			provider: "ollama", model: "m", detected: FormatJSON,
+		},
+		{
+			name:     "json list",
+			data:     ` [{"role": "user", "content": "hello"}, {"role": "assistant", "content": "hi"}]`,
+			want:     hello,
+			detected: FormatJSON,
+		},
+		{
+			name:     "yaml object",
+			data:     "provider: githubmodels\nmessages:\n  - role: user\n    content: hello\n  - role: assistant\n    content: hi\n",
+			want:     hello,
+			provider: "githubmodels", detected: FormatYAML,
+		},
+		{
+			name:     "yaml list",
+			data:     "- role: user\n  content: hello\n- role: assistant\n  content: hi\n",
+			want:     hello,
+			detected: FormatYAML,
+		},
+		{
+			name:     "markdown with front matter and flags",
+			data:     "---\nmodel: m\n---\n\n## System (pinned)\n\nBe brief.\n\n## user\n\nline one\n\nline two\n\n## assistant (summary, compacted)\nok\n",
+			want:     []Message{{Role: "system", Content: "Be brief.", Pinned: true}, {Role: "user", Content: "line one\n\nline two"}, {Role: "assistant", Content: "ok", Summary: true, Compacted: true}},
+			model:    "m",
+			detected: FormatMarkdown,
+		},
+		{
+			name:     "markdown with windows line endings",
+			data:     "## user\r\nhello\r\n## assistant\r\nhi\r\n",
+			want:     hello,
+			detected: FormatMarkdown,
+		},
+		{name: "explicit format wins", data: "## user\nhello\n", format: FormatYAML, err: "parsing yaml transcript"},
+		{name: "unknown format", data: "{}", format: "toml", err: "unknown transcript format"},
+		{name: "no messages", data: `{"messages": []}`, err: "no messages"},
+		{name: "unknown role", data: `[{"role": "tool", "content": "x"}]`, err: `unknown role "tool"`},
+		{name: "unknown flag", data: "## user (starred)\nhello\n", err: `unknown message flag "starred"`},
+		{name: "text before heading", data: "hello\n## user\nhi\n", err: "text before the first role heading"},
+		{name: "unterminated front matter", data: "---\nmodel: m\n## user\nhi\n", err: "unterminated front matter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := Parse([]byte(tt.data), tt.format)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Parse error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(tr.Messages, tt.want) {
+				t.Errorf("messages = %+v, want %+v", tr.Messages, tt.want)
+			}
+			if tr.Provider != tt.provider || tr.Model != tt.model {
+				t.Errorf("provider, model = %q, %q, want %q, %q", tr.Provider, tr.Model, tt.provider, tt.model)
+			}
+			if tr.Format != tt.detected {
+				t.Errorf("format = %q, want %q", tr.Format, tt.detected)
+			}
+		})
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := &Transcript{
+		Provider: "ollama",
+		Model:    "m",
+		Messages: []Message{
+			{Role: "system", Content: "Be brief.", Pinned: true},
+			{Role: "user", Content: "first\n\nsecond"},
+			{Role: "assistant", Content: "summary", Summary: true},
+			{Role: "assistant", Content: "old", Compacted: true},
+		},
+	}
+	for _, format := range []string{FormatJSON, FormatYAML, FormatMarkdown} {
+		t.Run(format, func(t *testing.T) {
+			data, err := in.Marshal(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := Parse(data, "")
+			if err != nil {
+				t.Fatalf("Parse(%s): %v\n%s", format, err, data)
+			}
+			if out.Format != format {
+				t.Errorf("detected %q, want %q", out.Format, format)
+			}
+			if out.Provider != in.Provider || out.Model != in.Model || !reflect.DeepEqual(out.Messages, in.Messages) {
+				t.Errorf("round trip through %s gave %+v", format, out)
+			}
+		})
+	}
+}
+
+func TestFormatForPath(t *testing.T) {
+	tests := map[string]string{
+		"chat.json": FormatJSON, "chat.YML": FormatYAML, "chat.yaml": FormatYAML,
+		"notes.md": FormatMarkdown, "notes.markdown": FormatMarkdown, "-": "", "chat.txt": "",
+	}
+	for path, want := range tests {
+		if got := FormatForPath(path); got != want {
+			t.Errorf("FormatForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/directory"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/jsonrpc"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
+)
+
+// ErrNotRunning is returned when no daemon is listening.
+var ErrNotRunning = errors.New("daemon is not running")
+
+// errClosed is returned by calls on a connection the daemon closed.
+var errClosed = errors.New("daemon connection closed")
+
+// Client is a connection to the daemon.
+type Client struct {
+	nc   net.Conn
+	conn *jsonrpc.Conn
+	done chan struct{}
+
+	mu        sync.Mutex
+	nextToken int
+	streams   map[string]func(string)
+}
+
+var (
+	disabled   bool
+	connect    sync.Once
+	connection *Client
+)
+
+// SetDisabled makes Connect return nil, so everything runs in-process.
+func SetDisabled(off bool) {
+	disabled = off
+}
+
+// Dial connects to the running daemon.
+func Dial() (*Client, error) {
+	nc, err := net.DialTimeout("unix", SocketPath(), time.Second)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	c := &Client{
+		nc:      nc,
+		conn:    jsonrpc.NewConn(nc, nc),
+		done:    make(chan struct{}),
+		streams: map[string]func(string){},
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Connect returns the connection of this process to the daemon, or nil
+// when none is running, it is disabled or CODEFORGEAI_NO_DAEMON is set.
+func Connect() *Client {
+	connect.Do(func() {
+		if disabled || os.Getenv("CODEFORGEAI_NO_DAEMON") != "" {
+			return
+		}
+		c, err := Dial()
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := c.call(ctx, "status", nil, nil); err != nil {
+			slog.Debug("daemon not answering, running in-process", "error", err)
+			c.Close()
+			return
+		}
+		connection = c
+	})
+	return connection
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.nc.Close()
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		m, err := c.conn.Read()
+		if err != nil {
+			var re *jsonrpc.Error
+			if errors.As(err, &re) {
+				continue
+			}
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.Debug("daemon connection failed", "error", err)
+			}
+			return
+		}
+		switch {
+		case m.IsResponse():
+			c.conn.Deliver(m)
+		case m.Method == "model/chunk":
+			var p chunkParams
+			if json.Unmarshal(m.Params, &p) != nil {
+				continue
+			}
+			c.mu.Lock()
+			stream := c.streams[p.Token]
+			c.mu.Unlock()
+			if stream != nil {
+				stream(p.Text)
+			}
+		}
+	}
+}
+
+// call makes a request, failing instead of waiting forever if the daemon
+// goes away.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := c.conn.Call(ctx, method, params, result)
+	if err != nil && errors.Is(err, context.Canceled) {
+		select {
+		case <-c.done:
+			return errClosed
+		default:
+		}
+	}
+	return err
+}
+
+// Status returns the status of the daemon.
+func (c *Client) Status() (*Status, error) {
+	var st Status
+	if err := c.call(context.Background(), "status", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Shutdown asks the daemon to exit.
+func (c *Client) Shutdown() error {
+	return c.call(context.Background(), "shutdown", nil, nil)
+}
+
+// Running reports whether a daemon is answering on the socket.
+func Running() bool {
+	c, err := Dial()
+	if err != nil {
+		return false
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return c.call(ctx, "status", nil, nil) == nil
+}
+
+// Install makes the directory tree, repo map, index search and models of
+// this process come from the daemon. Each falls back to in-process
+// execution when the daemon fails.
+func (c *Client) Install() {
+	directory.SetRemote(c.tree)
+	repomap.SetRemote(c.repoMap)
+	index.SetRemote(c.search)
+	models.SetRemote(c.model)
+}
+
+// currentProject returns the absolute path of root if it is the current
+// project, which the daemon watches; other directories are handled
+// in-process.
+func currentProject(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if abs != config.ProjectRoot() {
+		return "", errors.New("not the current project")
+	}
+	return abs, nil
+}
+
+func (c *Client) tree(root string) (*directory.Node, error) {
+	abs, err := currentProject(root)
+	if err != nil {
+		return nil, err
+	}
+	var tree *directory.Node
+	if err := c.call(context.Background(), "tree", rootParams{Root: abs}, &tree); err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return nil, errors.New("daemon has no tree for " + abs)
+	}
+	// The root is named as given, like a tree built here.
+	tree.Name = filepath.Base(root)
+	return tree, nil
+}
+
+func (c *Client) repoMap(root string, tokens int) (string, error) {
+	abs, err := currentProject(root)
+	if err != nil {
+		return "", err
+	}
+	var m string
+	err = c.call(context.Background(), "repomap", repoMapParams{Root: abs, Tokens: tokens}, &m)
+	return m, err
+}
+
+func (c *Client) search(root, query string, k int, minScore float64, exclude string) ([]index.Result, error) {
+	abs, err := currentProject(root)
+	if err != nil {
+		return nil, err
+	}
+	var results []index.Result
+	err = c.call(context.Background(), "index/search", searchParams{
+		Root: abs, Query: query, K: k, MinScore: minScore, Exclude: exclude,
+	}, &results)
+	return results, err
+}
+
+// model returns the daemon's model of modelType, once the daemon has it
+// set up.
+func (c *Client) model(modelType string) (models.Model, error) {
+	if err := c.call(context.Background(), "model/warm", modelParams{Type: modelType}, nil); err != nil {
+		return nil, err
+	}
+	return &remoteModel{client: c, modelType: modelType}, nil
+}
+
+// remoteModel is a model of the daemon. It goes through the daemon's
+// pipeline, so caching, redaction, usage and audit logging happen there.
+type remoteModel struct {
+	client    *Client
+	modelType string
+
+	mu    sync.Mutex
+	usage modeliface.Usage
+}
+
+// send makes a model call, streaming the reply to onToken when set.
+func (m *remoteModel) send(ctx context.Context, p modelParams, onToken func(string)) (string, error) {
+	p.Type, p.Root = m.modelType, config.ProjectRoot()
+	c := m.client
+	if onToken != nil {
+		c.mu.Lock()
+		c.nextToken++
+		p.Token = strconv.Itoa(c.nextToken)
+		c.streams[p.Token] = onToken
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.streams, p.Token)
+			c.mu.Unlock()
+		}()
+	}
+	var result modelResult
+	err := c.call(ctx, "model/send", p, &result)
+	if err != nil {
+		if p.Token != "" && ctx.Err() != nil {
+			c.conn.Notify("cancel", map[string]string{"token": p.Token})
+		}
+		return "", err
+	}
+	for _, msg := range result.Notices {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	m.mu.Lock()
+	m.usage = result.Usage
+	m.mu.Unlock()
+	return result.Reply, nil
+}
+
+func (m *remoteModel) SendRequest(prompt string, cfg interface{}) (string, error) {
+	return m.send(context.Background(), modelParams{Prompt: prompt, Options: cfg}, nil)
+}
+
+// SendRequestContext is SendRequest cancelled in the daemon when ctx is.
+func (m *remoteModel) SendRequestContext(ctx context.Context, prompt string, cfg interface{}) (string, error) {
+	// As for Fill, cancelling needs a token.
+	return m.send(ctx, modelParams{Prompt: prompt, Options: cfg}, func(string) {})
+}
+
+func (m *remoteModel) Chat(messages []modeliface.Message, cfg interface{}) (string, error) {
+	return m.ChatStream(messages, cfg, nil)
+}
+
+func (m *remoteModel) ChatStream(messages []modeliface.Message, cfg interface{}, onToken func(string)) (string, error) {
+	return m.send(context.Background(), modelParams{Messages: messages, Options: cfg}, onToken)
+}
+
+func (m *remoteModel) Fill(ctx context.Context, prefix, suffix string, cfg interface{}, onToken func(string)) (string, error) {
+	// A token is needed to cancel the request, so chunks are always asked for.
+	if onToken == nil {
+		onToken = func(string) {}
+	}
+	return m.send(ctx, modelParams{Fill: true, Prompt: prefix, Suffix: suffix, Options: cfg}, onToken)
+}
+
+func (m *remoteModel) LastUsage() modeliface.Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
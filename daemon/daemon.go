@@ -0,0 +1,637 @@
+// Package daemon keeps the config, project state and model connections of
+// the CLI warm in a background process. The CLI talks to it over a Unix
+// socket with JSON-RPC, and does the work itself when no daemon is
+// running.
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/directory"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/jsonrpc"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
+)
+
+// DefaultPoll is how often project files are checked for changes. A
+// project is refreshed once its files have stopped changing for one
+// interval, so a checkout or a formatter run costs a single refresh.
+const DefaultPoll = 2 * time.Second
+
+// projectIdle is how long a project is kept without requests.
+const projectIdle = time.Hour
+
+// ErrRunning is returned when another daemon holds the socket.
+var ErrRunning = errors.New("daemon is already running")
+
+// SocketPath returns the Unix socket the daemon listens on.
+func SocketPath() string {
+	return filepath.Join(config.DataDir(), "daemon.sock")
+}
+
+// Status describes a running daemon.
+type Status struct {
+	PID      int             `json:"pid"`
+	Started  time.Time       `json:"started"`
+	Requests int64           `json:"requests"`
+	Models   []string        `json:"models"`
+	Projects []ProjectStatus `json:"projects"`
+}
+
+// ProjectStatus describes a project the daemon watches.
+type ProjectStatus struct {
+	Root    string    `json:"root"`
+	Files   int       `json:"files"`
+	Indexed bool      `json:"indexed"`
+	Changed time.Time `json:"changed"`
+}
+
+// Requests and notifications of the protocol.
+type (
+	rootParams struct {
+		Root string `json:"root"`
+	}
+	repoMapParams struct {
+		Root   string `json:"root"`
+		Tokens int    `json:"tokens"`
+	}
+	searchParams struct {
+		Root     string  `json:"root"`
+		Query    string  `json:"query"`
+		K        int     `json:"k"`
+		MinScore float64 `json:"min_score"`
+		Exclude  string  `json:"exclude"`
+	}
+	modelParams struct {
+		Type string `json:"type"`
+		// Root is the caller's project, charged for the call.
+		Root string `json:"root,omitempty"`
+		// Prompt is the prompt, or the prefix of a fill.
+		Prompt   string               `json:"prompt,omitempty"`
+		Messages []modeliface.Message `json:"messages,omitempty"`
+		Fill     bool                 `json:"fill,omitempty"`
+		Suffix   string               `json:"suffix,omitempty"`
+		Options  interface{}          `json:"options,omitempty"`
+		// Token, if set, asks for the reply as "model/chunk"
+		// notifications and lets "cancel" abandon the request.
+		Token string `json:"token,omitempty"`
+	}
+	modelResult struct {
+		Reply string           `json:"reply"`
+		Usage modeliface.Usage `json:"usage"`
+		// Notices are for the user, e.g. what was redacted and
+		// exceeded budgets; the daemon's stderr is seen by nobody.
+		Notices []string `json:"notices,omitempty"`
+	}
+	chunkParams struct {
+		Token string `json:"token"`
+		Text  string `json:"text"`
+	}
+)
+
+// Server answers CLI processes on the daemon socket.
+type Server struct {
+	poll     time.Duration
+	reindex  bool
+	started  time.Time
+	requests atomic.Int64
+	ln       net.Listener
+
+	mu       sync.Mutex
+	cfg      config.Config
+	cfgSum   string
+	models   map[string]*warmModel
+	projects map[string]*project
+	cancels  map[string]context.CancelFunc
+}
+
+// warmModel is a model set up once, with its provider checked. mu is held
+// only while it is set up; calls run concurrently.
+type warmModel struct {
+	mu    sync.Mutex
+	model models.Model // written under both mu and Server.mu
+}
+
+// NewServer returns a server checking project files every poll. With
+// reindex set, the index of a project is updated, re-embedding changed
+// files, as soon as they change; otherwise that waits for a search.
+func NewServer(poll time.Duration, reindex bool) *Server {
+	if poll <= 0 {
+		poll = DefaultPoll
+	}
+	return &Server{
+		poll:     poll,
+		reindex:  reindex,
+		started:  time.Now(),
+		models:   map[string]*warmModel{},
+		projects: map[string]*project{},
+		cancels:  map[string]context.CancelFunc{},
+	}
+}
+
+// Serve listens on the socket until Close or a "shutdown" request. It
+// first warms up the models and the project of the working directory.
+func (s *Server) Serve() error {
+	sock := SocketPath()
+	if Running() {
+		return ErrRunning
+	}
+	os.Remove(sock) // stale socket from a previous daemon
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+	if err := os.Chmod(sock, 0600); err != nil {
+		ln.Close()
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	go s.warm()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(nc)
+	}
+}
+
+// Close stops accepting connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Close()
+}
+
+func (s *Server) warm() {
+	for _, t := range []string{"general", "code"} {
+		if _, err := s.model(t); err != nil {
+			slog.Warn("could not warm up model", "type", t, "error", err)
+		}
+	}
+	root := config.ProjectRoot()
+	if home, _ := os.UserHomeDir(); root != "" && root != home {
+		s.project(root)
+	}
+}
+
+// serveConn answers the requests of one CLI process, each in its own
+// goroutine. Model calls are cancelled when the process goes away.
+func (s *Server) serveConn(nc net.Conn) {
+	defer nc.Close()
+	conn := jsonrpc.NewConn(nc, nc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for {
+		m, err := conn.Read()
+		if err != nil {
+			var re *jsonrpc.Error
+			if errors.As(err, &re) {
+				continue
+			}
+			return
+		}
+		switch {
+		case m.IsRequest():
+			s.requests.Add(1)
+			go func() {
+				result, err := s.handle(ctx, conn, m)
+				if err := conn.Reply(m.ID, result, err); err != nil {
+					slog.Debug("could not reply", "method", m.Method, "error", err)
+				}
+				if m.Method == "shutdown" {
+					s.Close()
+				}
+			}()
+		case m.Method == "cancel":
+			var p struct {
+				Token string `json:"token"`
+			}
+			if json.Unmarshal(m.Params, &p) == nil {
+				s.mu.Lock()
+				if stop := s.cancels[p.Token]; stop != nil {
+					stop()
+				}
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn *jsonrpc.Conn, m *jsonrpc.Message) (interface{}, error) {
+	decode := func(v interface{}) error {
+		if err := json.Unmarshal(m.Params, v); err != nil {
+			return &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+	switch m.Method {
+	case "status":
+		return s.status(), nil
+	case "shutdown":
+		return nil, nil
+	case "tree":
+		var p rootParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.project(p.Root).snapshot().tree, nil
+	case "repomap":
+		var p repoMapParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.project(p.Root).repoMap(p.Tokens), nil
+	case "index/search":
+		var p searchParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		cfg := s.config()
+		ix, err := s.project(p.Root).searchIndex(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return index.Query(&cfg, ix, p.Query, p.K, p.MinScore, p.Exclude)
+	case "model/warm":
+		var p modelParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		_, err := s.model(p.Type)
+		return nil, err
+	case "model/send":
+		var p modelParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.send(ctx, conn, p)
+	}
+	return nil, &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: "unknown method: " + m.Method}
+}
+
+// config returns the parsed config, reloading it when the file changed;
+// models set up with the old one are dropped. Commands save the file on
+// every run, so it is compared by content.
+func (s *Server) config() config.Config {
+	sum := fileSum(config.FilePath())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sum != "" && sum == s.cfgSum {
+		return s.cfg
+	}
+	cfg, err := config.EnsureConfigPrompts("")
+	if err != nil {
+		slog.Warn("could not reload config", "error", err)
+		return s.cfg
+	}
+	if s.cfgSum != "" {
+		slog.Info("config changed, reloaded")
+	}
+	// Loading saves backfilled fields: sum the file afterwards.
+	s.cfgSum = fileSum(config.FilePath())
+	s.cfg = cfg
+	s.models = map[string]*warmModel{}
+	return cfg
+}
+
+// fileSum returns a hash of the file at path, or "" if it cannot be read.
+func fileSum(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// model returns the model of modelType, setting it up on first use, with
+// its lock held.
+func (s *Server) model(modelType string) (models.Model, error) {
+	if modelType != "general" && modelType != "code" {
+		return nil, fmt.Errorf("unknown model type %q", modelType)
+	}
+	cfg := s.config()
+	s.mu.Lock()
+	wm := s.models[modelType]
+	if wm == nil {
+		wm = &warmModel{}
+		s.models[modelType] = wm
+	}
+	s.mu.Unlock()
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	if wm.model == nil {
+		model, err := models.GetModelFromConfig(&cfg, modelType)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		wm.model = model
+		s.mu.Unlock()
+		slog.Info("model ready", "type", modelType, "provider", cfg.Integrations.Default)
+	}
+	return wm.model, nil
+}
+
+// send makes a model call for a CLI process.
+func (s *Server) send(ctx context.Context, conn *jsonrpc.Conn, p modelParams) (*modelResult, error) {
+	model, err := s.model(p.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var stream func(string)
+	if p.Token != "" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		s.mu.Lock()
+		s.cancels[p.Token] = cancel
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, p.Token)
+			s.mu.Unlock()
+			cancel()
+		}()
+		stream = func(text string) {
+			conn.Notify("model/chunk", chunkParams{Token: p.Token, Text: text})
+		}
+	}
+
+	result := &modelResult{}
+	c := &models.Call{
+		Prompt:   p.Prompt,
+		Messages: p.Messages,
+		Stream:   stream,
+		Fill:     p.Fill,
+		Suffix:   p.Suffix,
+		Ctx:      ctx,
+		Project:  p.Root,
+		Notify:   func(msg string) { result.Notices = append(result.Notices, msg) },
+	}
+	c.Options, _ = p.Options.(map[string]interface{})
+	result.Reply, err = models.Run(model, c)
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = c.Usage
+	return result, nil
+}
+
+func (s *Server) status() *Status {
+	st := &Status{PID: os.Getpid(), Started: s.started, Requests: s.requests.Load()}
+	s.mu.Lock()
+	for t, wm := range s.models {
+		if wm.model != nil {
+			st.Models = append(st.Models, t)
+		}
+	}
+	projects := make([]*project, 0, len(s.projects))
+	for _, p := range s.projects {
+		projects = append(projects, p)
+	}
+	s.mu.Unlock()
+	sort.Strings(st.Models)
+	for _, p := range projects {
+		snap := p.snapshot()
+		st.Projects = append(st.Projects, ProjectStatus{Root: p.root, Files: snap.files, Indexed: snap.index != nil, Changed: snap.changed})
+	}
+	sort.Slice(st.Projects, func(i, j int) bool { return st.Projects[i].Root < st.Projects[j].Root })
+	return st
+}
+
+// project returns the state of the project at root, loading it and
+// starting to watch it on first use.
+func (s *Server) project(root string) *project {
+	s.mu.Lock()
+	p := s.projects[root]
+	if p == nil {
+		p = &project{root: root, maps: map[int]string{}}
+		s.projects[root] = p
+	}
+	p.used.Store(time.Now().UnixNano())
+	s.mu.Unlock()
+	p.once.Do(func() {
+		if files, stamp, ok := p.scan(); ok {
+			p.refresh(s.config(), files, stamp, s.reindex)
+		}
+		go s.watch(p)
+	})
+	return p
+}
+
+// watch polls the files of p and refreshes it once they have changed and
+// then stayed the same for a poll, until it has gone unused for
+// projectIdle.
+func (s *Server) watch(p *project) {
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+	var pending string // the changed fingerprint seen at the last poll
+	for range ticker.C {
+		if time.Since(time.Unix(0, p.used.Load())) > projectIdle {
+			s.mu.Lock()
+			delete(s.projects, p.root)
+			s.mu.Unlock()
+			slog.Info("stopped watching idle project", "root", p.root)
+			return
+		}
+		files, stamp, ok := p.scan()
+		switch {
+		case !ok:
+		case stamp == p.snapshot().stamp:
+			pending = ""
+		case stamp != pending:
+			pending = stamp // still changing; wait for it to settle
+		default:
+			pending = ""
+			slog.Info("project changed, refreshing", "root", p.root)
+			p.refresh(s.config(), files, stamp, s.reindex)
+		}
+	}
+}
+
+// project is what the daemon keeps of one project: its tree, repo map
+// cache and index, rebuilt when a file changes.
+type project struct {
+	root string
+	once sync.Once
+	used atomic.Int64
+
+	mu      sync.Mutex
+	stamp   string
+	files   int
+	changed time.Time
+	tree    *directory.Node
+	cache   *repomap.Cache
+	maps    map[int]string
+	index   *index.Index
+	// indexStamp and indexMod are the file fingerprint and index file
+	// modification time the index was last brought up to date with.
+	indexStamp string
+	indexMod   time.Time
+
+	indexMu sync.Mutex // held while the index is updated
+}
+
+// projectState is a consistent view of a project.
+type projectState struct {
+	stamp   string
+	files   int
+	changed time.Time
+	tree    *directory.Node
+	index   *index.Index
+}
+
+func (p *project) snapshot() projectState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return projectState{stamp: p.stamp, files: p.files, changed: p.changed, tree: p.tree, index: p.index}
+}
+
+// repoMap renders the map within tokens, reusing earlier renderings until
+// a file changes.
+func (p *project) repoMap(tokens int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.maps[tokens]; ok {
+		return m
+	}
+	if p.cache == nil {
+		return ""
+	}
+	m := repomap.Render(p.cache.Rank(), tokens)
+	p.maps[tokens] = m
+	return m
+}
+
+// scan fingerprints the files of p, logging when that fails.
+func (p *project) scan() (int, string, bool) {
+	files, stamp, err := fingerprint(p.root)
+	if err != nil {
+		slog.Warn("could not scan project", "root", p.root, "error", err)
+		return 0, "", false
+	}
+	return files, stamp, true
+}
+
+// refresh rebuilds the project state for files with fingerprint stamp.
+// The index is loaded as saved, and only updated with reindex set.
+func (p *project) refresh(cfg config.Config, files int, stamp string, reindex bool) {
+	tree, err := directory.BuildTree(p.root)
+	if err != nil {
+		slog.Warn("could not build directory tree", "root", p.root, "error", err)
+	}
+	cache, err := repomap.Update(&cfg, p.root)
+	if err != nil {
+		slog.Warn("could not update repo map", "root", p.root, "error", err)
+	}
+
+	p.mu.Lock()
+	p.stamp, p.files, p.changed = stamp, files, time.Now()
+	p.tree, p.cache = tree, cache
+	p.maps = map[int]string{}
+	load := p.index == nil && index.Exists(p.root)
+	p.mu.Unlock()
+
+	switch {
+	case reindex:
+		if _, err := p.searchIndex(cfg); err != nil && !errors.Is(err, index.ErrNoIndex) {
+			slog.Warn("could not update index", "root", p.root, "error", err)
+		}
+	case load:
+		ix, err := index.Load(p.root)
+		if err != nil {
+			slog.Warn("could not load index", "root", p.root, "error", err)
+			return
+		}
+		p.mu.Lock()
+		p.index = ix
+		p.mu.Unlock()
+	}
+}
+
+// searchIndex returns the index of p, first updating it if files or the
+// saved index changed since it was last brought up to date. That embeds
+// the changed files, as a search without the daemon would.
+func (p *project) searchIndex(cfg config.Config) (*index.Index, error) {
+	p.indexMu.Lock()
+	defer p.indexMu.Unlock()
+	mod := indexModTime(p.root)
+	p.mu.Lock()
+	ix, stamp := p.index, p.stamp
+	fresh := ix != nil && p.indexStamp == stamp && p.indexMod.Equal(mod)
+	p.mu.Unlock()
+	if fresh {
+		return ix, nil
+	}
+	if !index.Exists(p.root) {
+		return nil, index.ErrNoIndex
+	}
+	ix, _, err := index.Update(&cfg, p.root, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.index, p.indexStamp, p.indexMod = ix, stamp, indexModTime(p.root)
+	p.mu.Unlock()
+	return ix, nil
+}
+
+// indexModTime returns when the saved index of root last changed, zero
+// when there is none.
+func indexModTime(root string) time.Time {
+	if info, err := os.Stat(index.Path(root)); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// fingerprint sums the names, sizes and modification times of the files
+// of the project at root, so any change shows. The saved index is left
+// out: searchIndex checks it itself.
+func fingerprint(root string) (int, string, error) {
+	paths, err := directory.SelectFiles(root)
+	if err != nil {
+		return 0, "", err
+	}
+	h := sha256.New()
+	stamp := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	for _, rel := range paths {
+		stamp(filepath.Join(root, rel))
+	}
+	for _, name := range []string{".gitignore", ".codeforge.json"} {
+		stamp(filepath.Join(root, name))
+	}
+	return len(paths), hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchDebounce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(200*time.Millisecond, false)
+	p := s.project(root)
+	first := p.snapshot()
+	if first.files != 1 || first.tree == nil {
+		t.Fatalf("project state after loading = %+v", first)
+	}
+
+	// Writes faster than the poll keep the project from being refreshed.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 60; i++ {
+		f.WriteString("// edit\n")
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := p.snapshot(); got.stamp != first.stamp {
+		t.Fatal("project refreshed while its files were still changing")
+	}
+
+	// Once they settle, it is refreshed.
+	deadline := time.Now().Add(5 * time.Second)
+	for p.snapshot().stamp == first.stamp {
+		if time.Now().After(deadline) {
+			t.Fatal("project not refreshed after its files stopped changing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -54,8 +54,23 @@ func ShouldIgnore(relPath string, patterns []string) bool {
 	return false
 }
 
+// remoteTree, if set, returns the tree of root from a process that keeps it
+// up to date, or an error to build it here.
+var remoteTree func(root string) (*Node, error)
+
+// SetRemote makes BuildTree ask tree first. It is set once at startup,
+// when the daemon is running.
+func SetRemote(tree func(root string) (*Node, error)) {
+	remoteTree = tree
+}
+
 // BuildTree walks the directory and builds a tree, applying .gitignore.
 func BuildTree(root string) (*Node, error) {
+	if remoteTree != nil {
+		if tree, err := remoteTree(root); err == nil {
+			return tree, nil
+		}
+	}
 	patterns, _ := ParseGitignore(root)
 	return buildTreeRec(root, root, patterns)
 }
@@ -143,6 +158,50 @@ func GetUsefulFiles(node *Node) []string {
 	return files
 }
 
+// GetFiles extracts every file path from a tree.
+func GetFiles(node *Node) []string {
+	var files []string
+	if node == nil {
+		return files
+	}
+	if node.Type == "file" {
+		files = append(files, node.Path)
+	}
+	for _, child := range node.Children {
+		files = append(files, GetFiles(child)...)
+	}
+	return files
+}
+
+// SelectFiles returns the files under root worth reading, relative to root:
+// the useful files of a saved analysis (.codeforge.json) when it has any,
+// otherwise every file BuildTree keeps. The .git directory is always
+// skipped.
+func SelectFiles(root string) ([]string, error) {
+	var files []string
+	if data, err := os.ReadFile(filepath.Join(root, ".codeforge.json")); err == nil {
+		var analysed Node
+		if json.Unmarshal(data, &analysed) == nil {
+			files = GetUsefulFiles(&analysed)
+		}
+	}
+	if len(files) == 0 {
+		tree, err := BuildTree(root)
+		if err != nil {
+			return nil, err
+		}
+		files = GetFiles(tree)
+	}
+	var out []string
+	for _, f := range files {
+		if f == ".git" || strings.HasPrefix(f, ".git"+string(filepath.Separator)) {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
 // StripDirectory prints the tree after removing gitignored files.
 func StripDirectory() {
 	root, _ := os.Getwd()
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+)
+
+// Code rewrites offered to editors, each backed by a config prompt.
+const (
+	RewriteImprove  = "improve"  // improve_code_prompt
+	RewriteDocument = "document" // document_code_prompt
+)
+
+// RewriteCode rewrites code taken from filePath with the prompt of rewrite
+// and returns the new code, indented like the original so it can replace
+// it.
+func (e *Engine) RewriteCode(rewrite, filePath, code string) (string, error) {
+	cfg, err := loadFreshConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading config: %w", err)
+	}
+	var instruction string
+	switch rewrite {
+	case RewriteImprove:
+		instruction = cfg.ImproveCodePrompt
+	case RewriteDocument:
+		instruction = cfg.DocumentCodePrompt
+	default:
+		return "", fmt.Errorf("unknown rewrite %q", rewrite)
+	}
+
+	model, err := models.GetModelFromConfig(&cfg, "code")
+	if err != nil {
+		return "", err
+	}
+	prompt := instruction + "\n\nFile: " + filePath + "\n\n" + code
+	if related := index.Context(&cfg, code, ""); related != "" {
+		prompt += "\n\n" + related
+	}
+	resp, err := model.SendRequest(prompt, map[string]interface{}{
+		"operation": "code_" + rewrite,
+		"file_path": filePath,
+	})
+	if err != nil {
+		return "", err
+	}
+	indent := code[:len(code)-len(strings.TrimLeft(code, " \t"))]
+	return reindent(extractCodeBlock(resp), indent), nil
+}
+
+// GenerateTests writes tests for code taken from filePath. They go in
+// testPath, whose current contents are existing ("" for a new file), so the
+// reply leaves out what that file already has, such as its package clause
+// and imports.
+func (e *Engine) GenerateTests(filePath, code, testPath, existing string) (string, error) {
+	cfg, err := loadFreshConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading config: %w", err)
+	}
+	model, err := models.GetModelFromConfig(&cfg, "code")
+	if err != nil {
+		return "", err
+	}
+	prompt := cfg.GenerateTestsPrompt + "\n\nFile: " + filePath + "\n\n" + code
+	switch {
+	case testPath == filePath:
+		prompt += "\n\nThe tests will be added at the end of " + filePath + "."
+	case existing == "":
+		prompt += "\n\nThe tests will be saved as the new file " + testPath + "."
+	default:
+		prompt += "\n\nThe tests will be added at the end of " + testPath +
+			", which starts like this; do not repeat what it already declares:\n\n" + firstLines(existing, 30)
+	}
+	resp, err := model.SendRequest(prompt, map[string]interface{}{
+		"operation": "generate_tests",
+		"file_path": filePath,
+	})
+	if err != nil {
+		return "", err
+	}
+	return extractCodeBlock(resp), nil
+}
+
+// TestFileFor returns where tests for the source file at path usually go:
+// foo_test.go, test_foo.py or foo.test.ts, and path itself for languages
+// that keep tests next to the code, such as Rust.
+func TestFileFor(path string) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	switch strings.ToLower(ext) {
+	case ".go":
+		if strings.HasSuffix(name, "_test") {
+			return path
+		}
+		return filepath.Join(dir, name+"_test"+ext)
+	case ".py":
+		if strings.HasPrefix(name, "test_") {
+			return path
+		}
+		return filepath.Join(dir, "test_"+base)
+	case ".ts", ".tsx", ".js", ".jsx", ".mjs":
+		if strings.HasSuffix(name, ".test") || strings.HasSuffix(name, ".spec") {
+			return path
+		}
+		return filepath.Join(dir, name+".test"+ext)
+	}
+	return path
+}
+
+func firstLines(text string, n int) string {
+	lines := strings.SplitN(text, "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/directory"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/models"
+)
+
+// Complete returns the code to insert at a cursor, and nothing else, for
+// editors. line and col are 1-based, col counting characters, with 0 for
+// the end of the line. content is the text of the file, for buffers with
+// unsaved changes, or "" to read it from disk.
+//
+// It is built for latency: only the lines around the cursor are sent,
+// without project context, and the completion stops at a blank line, after
+// the configured number of tokens, at the timeout or when ctx is
+// cancelled.
+func (e *Engine) Complete(ctx context.Context, filePath, content string, line, col int) (string, error) {
+	cfg, err := loadFreshConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading config: %w", err)
+	}
+	if content == "" {
+		if content, err = directory.ReadFileContent(filePath); err != nil {
+			return "", err
+		}
+	}
+	prefix, suffix, err := splitAtCursor(content, line, col, cfg.Completion.PrefixLines, cfg.Completion.SuffixLines)
+	if err != nil {
+		return "", err
+	}
+
+	model, err := models.GetModelFromConfig(&cfg, "code")
+	if err != nil {
+		return "", err
+	}
+	fm, ok := model.(modeliface.FillModel)
+	if !ok {
+		return "", errors.New("the code model does not support completion")
+	}
+	if cfg.Completion.TimeoutMS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.Completion.TimeoutMS)*time.Millisecond)
+		defer cancel()
+	}
+	resp, err := fm.Fill(ctx, prefix, suffix, map[string]interface{}{
+		"operation": "code_completion",
+		"file_path": filePath,
+		"line":      line,
+		"options": map[string]interface{}{
+			"num_predict": cfg.Completion.MaxTokens,
+			"temperature": 0,
+			"stop":        []string{"\n\n"},
+		},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	// Models answering the instruction fallback may fence their code.
+	if strings.HasPrefix(strings.TrimSpace(resp), "```") {
+		resp = extractCodeBlock(resp)
+	}
+	return untilBlankLine(resp), nil
+}
+
+// splitAtCursor returns the code before and after the cursor, limited to
+// prefixLines and suffixLines whole lines around the cursor's line.
+func splitAtCursor(content string, line, col, prefixLines, suffixLines int) (string, string, error) {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return "", "", fmt.Errorf("line %d is outside the file (1-%d)", line, len(lines))
+	}
+	current := []rune(lines[line-1])
+	if col == 0 {
+		col = len(current) + 1
+	}
+	if col < 1 || col > len(current)+1 {
+		return "", "", fmt.Errorf("column %d is outside line %d (1-%d)", col, line, len(current)+1)
+	}
+	before := lines[max(0, line-1-prefixLines) : line-1]
+	after := lines[line:min(len(lines), line+suffixLines)]
+	prefix := strings.Join(append(append([]string(nil), before...), string(current[:col-1])), "\n")
+	suffix := strings.Join(append([]string{string(current[col-1:])}, after...), "\n")
+	return prefix, suffix, nil
+}
+
+// untilBlankLine cuts a completion at its first blank line, including
+// lines of only whitespace, which the stop sequence does not catch.
+func untilBlankLine(completion string) string {
+	lines := strings.Split(completion, "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" && i < len(lines)-1 {
+			return strings.Join(lines[:i], "\n")
+		}
+	}
+	return completion
+}
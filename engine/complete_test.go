@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitAtCursor(t *testing.T) {
+	content := "one\ntwo\nthree\nfour\nfive"
+	tests := []struct {
+		name                string
+		content             string
+		line, col           int
+		prefixLines, suffix int
+		wantPrefix          string
+		wantSuffix          string
+		err                 string
+	}{
+		{name: "middle of a line", content: content, line: 3, col: 3, prefixLines: 10, suffix: 10,
+			wantPrefix: "one\ntwo\nth", wantSuffix: "ree\nfour\nfive"},
+		{name: "limited context", content: content, line: 3, col: 3, prefixLines: 1, suffix: 1,
+			wantPrefix: "two\nth", wantSuffix: "ree\nfour"},
+		{name: "no context", content: content, line: 3, col: 1, prefixLines: 0, suffix: 0,
+			wantPrefix: "", wantSuffix: "three"},
+		{name: "column zero means end of line", content: content, line: 2, col: 0, prefixLines: 1, suffix: 1,
+			wantPrefix: "one\ntwo", wantSuffix: "\nthree"},
+		{name: "just past the end", content: content, line: 5, col: 5, prefixLines: 1, suffix: 1,
+			wantPrefix: "four\nfive", wantSuffix: ""},
+		{name: "columns count runes", content: "x := \"héllo\"", line: 1, col: 9, prefixLines: 0, suffix: 0,
+			wantPrefix: "x := \"hé", wantSuffix: "llo\""},
+		{name: "empty file", content: "", line: 1, col: 1, wantPrefix: "", wantSuffix: ""},
+		{name: "line zero", content: content, line: 0, col: 1, err: "line 0 is outside the file (1-5)"},
+		{name: "line past the end", content: content, line: 6, col: 1, err: "line 6 is outside the file (1-5)"},
+		{name: "column past the end", content: content, line: 1, col: 5, err: "column 5 is outside line 1 (1-4)"},
+		{name: "negative column", content: content, line: 1, col: -1, err: "column -1 is outside line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, suffix, err := splitAtCursor(tt.content, tt.line, tt.col, tt.prefixLines, tt.suffix)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("splitAtCursor error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prefix != tt.wantPrefix || suffix != tt.wantSuffix {
+				t.Errorf("splitAtCursor = %q, %q, want %q, %q", prefix, suffix, tt.wantPrefix, tt.wantSuffix)
+			}
+		})
+	}
+}
+
+func TestUntilBlankLine(t *testing.T) {
+	tests := map[string]string{
+		"a\nb":          "a\nb",
+		"a\n\nb":        "a",
+		"a\n  \t\nb":    "a",
+		"a\nb\n":        "a\nb\n",
+		"\nfirst\n\nx":  "\nfirst",
+		"return x\n\n}": "return x",
+	}
+	for in, want := range tests {
+		if got := untilBlankLine(in); got != want {
+			t.Errorf("untilBlankLine(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
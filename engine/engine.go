@@ -1,7 +1,11 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -9,7 +13,11 @@ import (
 
 	"github.com/codeforge-ide/codeforgeai.go/config"
 	"github.com/codeforge-ide/codeforgeai.go/directory"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
 	"github.com/codeforge-ide/codeforgeai.go/models"
+	"github.com/codeforge-ide/codeforgeai.go/parser"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
 )
 
 // Engine orchestrates operations using fresh config and models.
@@ -22,44 +30,61 @@ func NewEngine(cfg *config.Config) *Engine {
 
 // loadFreshConfig loads the latest config from disk.
 func loadFreshConfig() (config.Config, error) {
-	return config.EnsureConfigPrompts("")
+	cfg, err := config.EnsureConfigPrompts("")
+	slog.Debug("loaded config", "provider", cfg.Integrations.Default, "error", err)
+	return cfg, err
 }
 
+// ErrModel marks the errors of engine operations whose configured model
+// cannot be created, e.g. because it has not been pulled.
+var ErrModel = errors.New("error creating model")
+
 // getGeneralModel instantiates the general model based on config.
-func getGeneralModel(cfg *config.Config) models.Model {
+func getGeneralModel(cfg *config.Config) (models.Model, error) {
 	model, err := models.GetModelFromConfig(cfg, "general")
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%w: %w", ErrModel, err)
 	}
-	return model
+	return model, nil
 }
 
 // getCodeModel instantiates the code model based on config.
-func getCodeModel(cfg *config.Config) models.Model {
+func getCodeModel(cfg *config.Config) (models.Model, error) {
 	model, err := models.GetModelFromConfig(cfg, "code")
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%w: %w", ErrModel, err)
+	}
+	return model, nil
+}
+
+// sendRequest is model.SendRequest, abandoned when ctx is cancelled if
+// the model supports that.
+func sendRequest(ctx context.Context, model models.Model, prompt string, meta map[string]interface{}) (string, error) {
+	if xm, ok := model.(modeliface.ContextModel); ok {
+		return xm.SendRequestContext(ctx, prompt, meta)
 	}
-	return model
+	return model.SendRequest(prompt, meta)
 }
 
 // RunAnalysis analyzes the current directory and classifies files.
-func (e *Engine) RunAnalysis() {
+func (e *Engine) RunAnalysis() error {
 	cfg, err := loadFreshConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading config:", err)
-		return
+		return fmt.Errorf("error loading config: %w", err)
 	}
 
 	root, _ := os.Getwd()
 	tree, err := directory.BuildTree(root)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error building directory tree:", err)
-		return
+		return fmt.Errorf("error building directory tree: %w", err)
 	}
+	slog.Info("built directory tree", "root", root)
 
 	// Use general model to classify the directory structure
-	model := getGeneralModel(&cfg)
+	model, err := getGeneralModel(&cfg)
+	if err != nil {
+		return err
+	}
 	treeJSON, _ := directory.SerializeTree(tree)
 
 	prompt := cfg.DirectoryClassificationPrompt + "\n" + treeJSON
@@ -67,37 +92,46 @@ func (e *Engine) RunAnalysis() {
 		"operation": "directory_classification",
 	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error classifying directory:", err)
-		return
+		return fmt.Errorf("error classifying directory: %w", err)
 	}
 
 	// Save classified result to .codeforge.json
 	err = directory.SaveAnalysisResult(root, resp)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error saving analysis:", err)
-		return
+		return fmt.Errorf("error saving analysis: %w", err)
 	}
 
 	fmt.Println("Directory analysis complete. Results saved to .codeforge.json")
+
+	// The languages come from file extensions, no model needed.
+	var langs []string
+	for _, l := range parser.DetectLanguages(directory.GetFiles(tree)) {
+		langs = append(langs, fmt.Sprintf("%s %.0f%% (%d file(s))", l.Language, l.Percent, l.Files))
+	}
+	if len(langs) > 0 {
+		fmt.Println("Languages:", strings.Join(langs, ", "))
+	}
+	return nil
 }
 
 // ProcessPrompt finetunes and processes a user prompt.
-func (e *Engine) ProcessPrompt(prompt string) string {
+func (e *Engine) ProcessPrompt(prompt string) (string, error) {
 	cfg, err := loadFreshConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading config:", err)
-		return ""
+		return "", fmt.Errorf("error loading config: %w", err)
 	}
 
 	// Step 1: Finetune the prompt
-	generalModel := getGeneralModel(&cfg)
+	generalModel, err := getGeneralModel(&cfg)
+	if err != nil {
+		return "", err
+	}
 	finetunePrompt := cfg.PromptFinetunePrompt + "\n" + prompt
 	fineTunedPrompt, err := generalModel.SendRequest(finetunePrompt, map[string]interface{}{
 		"operation": "prompt_finetune",
 	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error finetuning prompt:", err)
-		return ""
+		return "", fmt.Errorf("error finetuning prompt: %w", err)
 	}
 
 	// Step 2: Determine if response should be code or command
@@ -112,13 +146,20 @@ func (e *Engine) ProcessPrompt(prompt string) string {
 
 	// Step 3: Process with appropriate model and prompt
 	var finalPrompt, response string
+	projectMap := repomap.Context(&cfg, config.ProjectRoot())
+	if projectMap != "" {
+		fineTunedPrompt += "\n\n" + projectMap
+	}
 	if strings.Contains(strings.ToLower(responseType), "command") {
 		finalPrompt = cfg.CommandAgentPrompt + "\n" + fineTunedPrompt
 		response, err = generalModel.SendRequest(finalPrompt, map[string]interface{}{
 			"operation": "command_generation",
 		})
 	} else {
-		codeModel := getCodeModel(&cfg)
+		codeModel, cerr := getCodeModel(&cfg)
+		if cerr != nil {
+			return "", cerr
+		}
 		finalPrompt = cfg.CodePrompt + "\n" + fineTunedPrompt
 		response, err = codeModel.SendRequest(finalPrompt, map[string]interface{}{
 			"operation": "code_generation",
@@ -126,51 +167,137 @@ func (e *Engine) ProcessPrompt(prompt string) string {
 	}
 
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error processing prompt:", err)
-		return ""
+		return "", fmt.Errorf("error processing prompt: %w", err)
 	}
-	return response
+	return response, nil
 }
 
-// ExplainCode explains code in a file.
-func (e *Engine) ExplainCode(filePath string) string {
+// ExplainCode explains code in a file: all of it, the lines in opts, or a
+// single declaration. Symbols come with what they depend on (see
+// parser.Resolve), and JSON answers are anchored to the file's lines.
+// Cancelling ctx abandons the model request.
+func (e *Engine) ExplainCode(ctx context.Context, filePath string, opts ExplainOptions) (string, error) {
 	cfg, err := loadFreshConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading config:", err)
-		return ""
+		return "", fmt.Errorf("error loading config: %w", err)
+	}
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+
+	// Work out which lines to explain, and what to send along with them.
+	x := Explanation{File: filePath, Depth: opts.Depth, StartLine: opts.StartLine, EndLine: opts.EndLine}
+	var extra []string
+	if opts.Symbol != "" {
+		sym, _, err := findSymbol(opts.Symbol, filePath)
+		if err != nil {
+			return "", fmt.Errorf("error finding symbol: %w", err)
+		}
+		x.File, x.Symbol, x.StartLine, x.EndLine = sym.File, sym.ID, sym.StartLine, sym.EndLine
+		if sym.Context != "" {
+			extra = append(extra, sym.Context)
+		}
 	}
 
 	// Read file content
-	content, err := directory.ReadFileContent(filePath)
+	content, err := directory.ReadFileContent(x.File)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error reading file:", err)
-		return ""
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if x.StartLine == 0 {
+		x.StartLine = 1
+	}
+	if x.EndLine == 0 || x.EndLine > len(lines) {
+		x.EndLine = len(lines)
+	}
+	if x.StartLine > x.EndLine {
+		return "", fmt.Errorf("%s has only %d lines", x.File, len(lines))
+	}
+	code := strings.Join(lines[x.StartLine-1:x.EndLine], "\n")
+	whole := x.StartLine == 1 && x.EndLine == len(lines)
+
+	if opts.Blame {
+		// The explanation is still useful without it, e.g. for a new file.
+		if commits, err := gitBlame(x.File, x.StartLine, x.EndLine); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: leaving out git blame:", err)
+		} else {
+			x.Blame = commits
+			extra = append(extra, blameContext(commits))
+		}
 	}
 
 	// Use code model to explain
-	model := getCodeModel(&cfg)
-	prompt := cfg.ExplainCodePrompt + "\n\nFile: " + filePath + "\n\n" + content
+	model, err := getCodeModel(&cfg)
+	if err != nil {
+		return "", err
+	}
+	prompt := cfg.ExplainCodePrompt + "\n\n" + depthInstructions[opts.Depth] + " " + formatInstructions[opts.Format]
+	switch {
+	case x.Symbol != "":
+		prompt += "\n\nSymbol: " + x.Symbol + " (" + x.File + ")"
+	case whole:
+		prompt += "\n\nFile: " + x.File
+	default:
+		prompt += fmt.Sprintf("\n\nFile: %s, lines %d-%d of %d", x.File, x.StartLine, x.EndLine, len(lines))
+	}
+	if opts.Depth == DepthSummary && opts.Format != FormatJSON {
+		prompt += "\n\n" + code
+	} else {
+		// Numbered lines let the answer point at them.
+		prompt += "\n\n" + numberLines(lines[x.StartLine-1:x.EndLine], x.StartLine)
+	}
+	for _, s := range extra {
+		prompt += "\n\n" + s
+	}
+	// Only the whole file makes the rest of it redundant as context.
+	exclude := ""
+	if whole {
+		exclude = x.File
+	}
+	if related := index.Context(&cfg, code, exclude); related != "" {
+		prompt += "\n\n" + related
+	}
 
-	resp, err := model.SendRequest(prompt, map[string]interface{}{
-		"operation": "code_explanation",
-		"file_path": filePath,
-	})
+	meta := map[string]interface{}{
+		"operation":  "code_explanation",
+		"file_path":  x.File,
+		"start_line": x.StartLine,
+		"end_line":   x.EndLine,
+		"depth":      opts.Depth,
+		"format":     opts.Format,
+	}
+	if x.Symbol != "" {
+		meta["symbol"] = x.Symbol
+	}
+	resp, err := sendRequest(ctx, model, prompt, meta)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error explaining code:", err)
-		return ""
+		return "", fmt.Errorf("error explaining code: %w", err)
+	}
+	if opts.Format != FormatJSON {
+		return resp, nil
 	}
-	return resp
+	parseExplanation(resp, &x)
+	b, _ := json.MarshalIndent(x, "", "  ")
+	return string(b), nil
 }
 
 // ProcessCommitMessage generates a commit message with gitmoji.
-func (e *Engine) ProcessCommitMessage(diff string) string {
+func (e *Engine) ProcessCommitMessage(diff string) (string, error) {
 	cfg, err := loadFreshConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading config:", err)
-		return ""
+		return "", fmt.Errorf("error loading config: %w", err)
 	}
 
-	codeModel := getCodeModel(&cfg)
+	// Both models are checked before either is used.
+	codeModel, err := getCodeModel(&cfg)
+	if err != nil {
+		return "", err
+	}
+	generalModel, err := getGeneralModel(&cfg)
+	if err != nil {
+		return "", err
+	}
 
 	// Step 1: Generate commit message
 	commitPrompt := cfg.CommitMessagePrompt + "\n" + diff
@@ -178,12 +305,10 @@ func (e *Engine) ProcessCommitMessage(diff string) string {
 		"operation": "commit_message",
 	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error generating commit message:", err)
-		return ""
+		return "", fmt.Errorf("error generating commit message: %w", err)
 	}
 
 	// Step 2: Generate appropriate gitmoji
-	generalModel := getGeneralModel(&cfg)
 	gitmojiPrompt := cfg.GitmojiPrompt + "\n" + commitMsg
 	gitmoji, err := generalModel.SendRequest(gitmojiPrompt, map[string]interface{}{
 		"operation": "gitmoji_selection",
@@ -193,7 +318,7 @@ func (e *Engine) ProcessCommitMessage(diff string) string {
 		gitmoji = "✨" // default gitmoji
 	}
 
-	return strings.TrimSpace(gitmoji) + " " + strings.TrimSpace(commitMsg)
+	return strings.TrimSpace(gitmoji) + " " + strings.TrimSpace(commitMsg), nil
 }
 
 // EditFiles edits files according to user prompt.
@@ -202,6 +327,10 @@ func (e *Engine) EditFiles(paths []string, userPrompt string, allowIgnore bool)
 	if err != nil {
 		return fmt.Errorf("error loading config: %w", err)
 	}
+	model, err := getCodeModel(&cfg)
+	if err != nil {
+		return err
+	}
 
 	root, _ := os.Getwd()
 	patterns, _ := directory.ParseGitignore(root)
@@ -216,7 +345,7 @@ func (e *Engine) EditFiles(paths []string, userPrompt string, allowIgnore bool)
 			}
 		}
 
-		err := e.editSinglePath(path, userPrompt, &cfg)
+		err := e.editSinglePath(path, userPrompt, &cfg, model)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error editing %s: %v\n", path, err)
 		}
@@ -224,26 +353,31 @@ func (e *Engine) EditFiles(paths []string, userPrompt string, allowIgnore bool)
 	return nil
 }
 
-func (e *Engine) editSinglePath(path, userPrompt string, cfg *config.Config) error {
+func (e *Engine) editSinglePath(path, userPrompt string, cfg *config.Config, model models.Model) error {
 	info, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
 
 	if info.IsDir() {
-		return e.editDirectory(path, userPrompt, cfg)
+		return e.editDirectory(path, userPrompt, cfg, model)
 	}
-	return e.editFile(path, userPrompt, cfg)
+	return e.editFile(path, userPrompt, cfg, model)
 }
 
-func (e *Engine) editFile(filePath, userPrompt string, cfg *config.Config) error {
+func (e *Engine) editFile(filePath, userPrompt string, cfg *config.Config, model models.Model) error {
 	content, err := directory.ReadFileContent(filePath)
 	if err != nil {
 		return err
 	}
 
-	model := getCodeModel(cfg)
 	prompt := cfg.EditFinetunePrompt + "\n\nUser Request: " + userPrompt + "\n\nFile: " + filePath + "\n\n" + content
+	if related := index.Context(cfg, userPrompt+"\n\n"+content, filePath); related != "" {
+		prompt += "\n\n" + related
+	}
+	if projectMap := repomap.Context(cfg, config.ProjectRoot()); projectMap != "" {
+		prompt += "\n\n" + projectMap
+	}
 
 	editedContent, err := model.SendRequest(prompt, map[string]interface{}{
 		"operation": "file_edit",
@@ -258,7 +392,7 @@ func (e *Engine) editFile(filePath, userPrompt string, cfg *config.Config) error
 	return os.WriteFile(editFileName, []byte(editedContent), 0644)
 }
 
-func (e *Engine) editDirectory(dirPath, userPrompt string, cfg *config.Config) error {
+func (e *Engine) editDirectory(dirPath, userPrompt string, cfg *config.Config, model models.Model) error {
 	// Build tree and get relevant files
 	tree, err := directory.BuildTree(dirPath)
 	if err != nil {
@@ -270,7 +404,7 @@ func (e *Engine) editDirectory(dirPath, userPrompt string, cfg *config.Config) e
 
 	for _, file := range files {
 		fullPath := filepath.Join(dirPath, file)
-		err := e.editFile(fullPath, userPrompt, cfg)
+		err := e.editFile(fullPath, userPrompt, cfg, model)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error editing file %s: %v\n", fullPath, err)
 		}
@@ -279,11 +413,10 @@ func (e *Engine) editDirectory(dirPath, userPrompt string, cfg *config.Config) e
 }
 
 // ProvideSuggestion provides code suggestions.
-func (e *Engine) ProvideSuggestion(filePath string, line int, snippet []string, entire bool) string {
+func (e *Engine) ProvideSuggestion(filePath string, line int, snippet []string, entire bool) (string, error) {
 	cfg, err := loadFreshConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error loading config:", err)
-		return ""
+		return "", fmt.Errorf("error loading config: %w", err)
 	}
 
 	var content string
@@ -292,8 +425,7 @@ func (e *Engine) ProvideSuggestion(filePath string, line int, snippet []string,
 	} else if filePath != "" {
 		fileContent, err := directory.ReadFileContent(filePath)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error reading file:", err)
-			return ""
+			return "", fmt.Errorf("error reading file: %w", err)
 		}
 
 		if entire {
@@ -310,11 +442,22 @@ func (e *Engine) ProvideSuggestion(filePath string, line int, snippet []string,
 	}
 
 	if content == "" {
-		return "No content provided for suggestion"
+		return "No content provided for suggestion", nil
 	}
 
-	model := getCodeModel(&cfg)
+	model, err := getCodeModel(&cfg)
+	if err != nil {
+		return "", err
+	}
 	prompt := "Provide a code suggestion for the following:\n\n" + content
+	// Only the whole file makes the rest of it redundant as context.
+	exclude := ""
+	if entire {
+		exclude = filePath
+	}
+	if related := index.Context(&cfg, content, exclude); related != "" {
+		prompt += "\n\n" + related
+	}
 
 	resp, err := model.SendRequest(prompt, map[string]interface{}{
 		"operation": "code_suggestion",
@@ -322,10 +465,9 @@ func (e *Engine) ProvideSuggestion(filePath string, line int, snippet []string,
 		"line":      line,
 	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error providing suggestion:", err)
-		return ""
+		return "", fmt.Errorf("error providing suggestion: %w", err)
 	}
-	return resp
+	return resp, nil
 }
 
 // GetGitDiff gets the current git diff.
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Explanation depths.
+const (
+	DepthSummary     = "summary"
+	DepthWalkthrough = "walkthrough"
+	DepthLineByLine  = "line-by-line"
+)
+
+// Explanation formats.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+var depthInstructions = map[string]string{
+	DepthSummary:     "Give a short summary, a few sentences at most, of what this code does and why.",
+	DepthWalkthrough: "Walk through the code section by section, explaining what each part does and how the parts fit together.",
+	DepthLineByLine:  "Explain the code line by line, grouping trivial lines together, and cite the line numbers shown.",
+}
+
+var formatInstructions = map[string]string{
+	FormatPlain:    "Answer in plain text, without Markdown.",
+	FormatMarkdown: "Answer in Markdown, using headings, lists and code spans where they help.",
+	FormatJSON: `Answer only with a JSON object of the form {"summary": "...", "sections": [{"start_line": 1, "end_line": 3, "explanation": "..."}]}, ` +
+		"where the line numbers are the ones shown in the code and the sections follow the level of detail asked for.",
+}
+
+// ExplainOptions narrows what ExplainCode explains and shapes the answer.
+type ExplainOptions struct {
+	// StartLine and EndLine limit the explanation to a range of lines;
+	// zero explains the whole file.
+	StartLine int
+	EndLine   int
+	// Symbol explains a single declaration instead, looked up in the file
+	// when one is given and in the whole project otherwise.
+	Symbol string
+	// Blame adds who last changed the lines, and why, from git blame.
+	Blame bool
+	// Depth is DepthSummary, DepthWalkthrough (the default) or
+	// DepthLineByLine.
+	Depth string
+	// Format is FormatPlain, FormatMarkdown (the default) or FormatJSON.
+	Format string
+}
+
+func (o *ExplainOptions) validate() error {
+	if o.Depth == "" {
+		o.Depth = DepthWalkthrough
+	}
+	if o.Format == "" {
+		o.Format = FormatMarkdown
+	}
+	if depthInstructions[o.Depth] == "" {
+		return fmt.Errorf("unknown depth %q, use %s, %s or %s", o.Depth, DepthSummary, DepthWalkthrough, DepthLineByLine)
+	}
+	if formatInstructions[o.Format] == "" {
+		return fmt.Errorf("unknown format %q, use %s, %s or %s", o.Format, FormatPlain, FormatMarkdown, FormatJSON)
+	}
+	if o.StartLine < 0 || o.EndLine < 0 || (o.EndLine > 0 && o.EndLine < o.StartLine) {
+		return fmt.Errorf("invalid line range %d-%d", o.StartLine, o.EndLine)
+	}
+	return nil
+}
+
+// Explanation is the JSON form of an explanation, anchored to lines of the
+// file.
+type Explanation struct {
+	File      string               `json:"file"`
+	Symbol    string               `json:"symbol,omitempty"`
+	StartLine int                  `json:"start_line"`
+	EndLine   int                  `json:"end_line"`
+	Depth     string               `json:"depth"`
+	Summary   string               `json:"summary"`
+	Sections  []ExplanationSection `json:"sections"`
+	Blame     []BlameCommit        `json:"blame,omitempty"`
+}
+
+// ExplanationSection explains a range of lines.
+type ExplanationSection struct {
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	Explanation string `json:"explanation"`
+}
+
+// parseExplanation fills x from a JSON reply. Sections outside the lines
+// explained are dropped; a reply that is not JSON becomes the summary.
+func parseExplanation(reply string, x *Explanation) {
+	text := extractCodeBlock(reply)
+	if i, j := strings.Index(text, "{"), strings.LastIndex(text, "}"); i >= 0 && j > i {
+		text = text[i : j+1]
+	}
+	var parsed struct {
+		Summary  string               `json:"summary"`
+		Sections []ExplanationSection `json:"sections"`
+	}
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		x.Summary = strings.TrimSpace(reply)
+		x.Sections = []ExplanationSection{}
+		return
+	}
+	x.Summary = parsed.Summary
+	x.Sections = []ExplanationSection{}
+	for _, s := range parsed.Sections {
+		if s.EndLine < s.StartLine {
+			s.EndLine = s.StartLine
+		}
+		if s.StartLine < x.StartLine || s.EndLine > x.EndLine {
+			continue
+		}
+		x.Sections = append(x.Sections, s)
+	}
+}
+
+// numberLines prefixes each line with its line number, counting from
+// first.
+func numberLines(lines []string, first int) string {
+	var b strings.Builder
+	width := len(strconv.Itoa(first + len(lines) - 1))
+	for i, line := range lines {
+		fmt.Fprintf(&b, "%*d  %s\n", width, first+i, line)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// BlameCommit is a commit that last changed some of the lines explained.
+type BlameCommit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	Lines   []int     `json:"lines"`
+}
+
+// gitBlame returns the commits that last changed lines start to end of
+// file, the most recent first.
+func gitBlame(file string, start, end int) ([]BlameCommit, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "-C", filepath.Dir(abs), "blame", "--porcelain",
+		"-L", fmt.Sprintf("%d,%d", start, end), "--", filepath.Base(abs))
+	output, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("git blame: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("git blame: %w", err)
+	}
+
+	commits := map[string]*BlameCommit{}
+	var current *BlameCommit
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && len(fields[0]) == 40 {
+			if commits[fields[0]] == nil {
+				commits[fields[0]] = &BlameCommit{Hash: fields[0]}
+			}
+			current = commits[fields[0]]
+			if n, err := strconv.Atoi(fields[2]); err == nil {
+				current.Lines = append(current.Lines, n)
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-time":
+			if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.Date = time.Unix(secs, 0)
+			}
+		case "summary":
+			current.Summary = value
+		}
+	}
+
+	out := make([]BlameCommit, 0, len(commits))
+	for _, c := range commits {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date.After(out[j].Date) })
+	return out, nil
+}
+
+// blameContext renders commits for a prompt.
+func blameContext(commits []BlameCommit) string {
+	var b strings.Builder
+	b.WriteString("Who last changed these lines (git blame), the most recent first:\n")
+	for _, c := range commits {
+		hash := c.Hash[:8]
+		if strings.Trim(c.Hash, "0") == "" {
+			hash = "uncommitted"
+		}
+		fmt.Fprintf(&b, "- %s %s %s: %s (lines %s)\n", hash, c.Date.Format("2006-01-02"), c.Author, c.Summary, lineRanges(c.Lines))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// lineRanges writes sorted line numbers as ranges, e.g. "3-5, 9".
+func lineRanges(lines []int) string {
+	sort.Ints(lines)
+	var parts []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(lines[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"go/format"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/index"
+	"github.com/codeforge-ide/codeforgeai.go/parser"
+	"github.com/codeforge-ide/codeforgeai.go/repomap"
+)
+
+// findSymbol looks up a declaration in the project, or in file when it is
+// not empty.
+func findSymbol(name, file string) (*parser.Target, string, error) {
+	target, err := parser.Resolve(config.ProjectRoot(), name, file)
+	if err != nil {
+		return nil, "", err
+	}
+	source, err := target.Source()
+	if err != nil {
+		return nil, "", err
+	}
+	return target, source, nil
+}
+
+// EditSymbol rewrites a single declaration according to userPrompt and
+// saves the whole file, with only that declaration replaced, next to it
+// as a .codeforgedit file. It returns the path of that file.
+func (e *Engine) EditSymbol(name, file, userPrompt string) (string, error) {
+	cfg, err := loadFreshConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading config: %w", err)
+	}
+
+	sym, source, err := findSymbol(name, file)
+	if err != nil {
+		return "", err
+	}
+
+	model, err := getCodeModel(&cfg)
+	if err != nil {
+		return "", err
+	}
+	prompt := cfg.EditFinetunePrompt + "\n\nUser Request: " + userPrompt +
+		"\n\nEdit only the declaration of " + sym.ID + " from " + sym.File +
+		". Reply with the complete new declaration, including its doc comment, and nothing else.\n\n" + source
+	if sym.Context != "" {
+		prompt += "\n\n" + sym.Context
+	}
+	if related := index.Context(&cfg, userPrompt+"\n\n"+source, ""); related != "" {
+		prompt += "\n\n" + related
+	}
+	if projectMap := repomap.Context(&cfg, config.ProjectRoot()); projectMap != "" {
+		prompt += "\n\n" + projectMap
+	}
+
+	resp, err := model.SendRequest(prompt, map[string]interface{}{
+		"operation": "file_edit",
+		"file_path": sym.File,
+		"symbol":    sym.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(sym.File)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	edited := make([]string, 0, len(lines))
+	edited = append(edited, lines[:sym.StartLine-1]...)
+	indent := source[:len(source)-len(strings.TrimLeft(source, " \t"))]
+	edited = append(edited, strings.Split(reindent(extractCodeBlock(resp), indent), "\n")...)
+	edited = append(edited, lines[sym.EndLine:]...)
+	result := []byte(strings.Join(edited, "\n"))
+	if sym.Language == "Go" {
+		if formatted, err := format.Source(result); err == nil {
+			result = formatted
+		} else {
+			slog.Warn("edited file does not parse, saving it unformatted", "file", sym.File, "error", err)
+		}
+	}
+
+	editFileName := sym.File + ".codeforgedit"
+	return editFileName, os.WriteFile(editFileName, result, 0644)
+}
+
+// extractCodeBlock returns the contents of the first fenced code block in
+// a reply, or the whole reply when it has none.
+func extractCodeBlock(reply string) string {
+	lines := strings.Split(strings.TrimSpace(reply), "\n")
+	start := -1
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		if start < 0 {
+			start = i + 1
+			continue
+		}
+		return strings.Join(lines[start:i], "\n")
+	}
+	if start >= 0 {
+		return strings.Join(lines[start:], "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reindent moves code to the given indentation, so that a member
+// rewritten without its class around it fits back in.
+func reindent(code, indent string) string {
+	lines := strings.Split(code, "\n")
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); common < 0 || n < common {
+			common = n
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = indent + line[common:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
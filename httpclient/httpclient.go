@@ -0,0 +1,141 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var localOnly atomic.Bool
+
+// SetLocalOnly turns local-only privacy mode on or off for every client
+// created by this package.
+func SetLocalOnly(on bool) {
+	localOnly.Store(on)
+}
+
+// LocalOnly reports whether local-only privacy mode is active.
+func LocalOnly() bool {
+	return localOnly.Load()
+}
+
+// BlockedError is returned when local-only mode refuses a destination.
+type BlockedError struct {
+	Call string
+	Host string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("privacy mode local_only blocked %s: %s is not a loopback destination", e.Call, e.Host)
+}
+
+// IsLoopbackHost reports whether host (without port) only resolves to
+// loopback addresses.
+func IsLoopbackHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	}
+	addrs, err := net.LookupIP(host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, ip := range addrs {
+		if !ip.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLoopbackURL reports whether rawURL points at this machine.
+func IsLoopbackURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "unix" {
+		return true
+	}
+	return IsLoopbackHost(u.Hostname())
+}
+
+// CheckURL returns a BlockedError naming call if local-only mode is active
+// and rawURL is not a loopback destination.
+func CheckURL(call, rawURL string) error {
+	if !LocalOnly() || IsLoopbackURL(rawURL) {
+		return nil
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return &BlockedError{Call: call, Host: host}
+}
+
+// New returns an HTTP client for the named call. In local-only mode its
+// transport refuses to dial anything but loopback addresses and ignores
+// proxy settings, so no request can leave the host.
+func New(call string, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if LocalOnly() {
+			return nil, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !LocalOnly() {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		// Resolve once and dial the checked address, so a second DNS
+		// answer cannot point somewhere else.
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if !ip.IP.IsLoopback() {
+				return nil, &BlockedError{Call: call, Host: addr}
+			}
+		}
+		if len(ips) == 0 {
+			return nil, &BlockedError{Call: call, Host: addr}
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+	}
+	return &http.Client{Timeout: timeout, Transport: &loggingTransport{call: call, next: transport}}
+}
+
+// loggingTransport records each request at debug level. Headers are never
+// logged since they carry bearer tokens.
+type loggingTransport struct {
+	call string
+	next http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs := []any{"call", t.call, "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "duration", time.Since(start).Round(time.Millisecond)}
+	if err != nil {
+		slog.Debug("http request failed", append(attrs, "error", err)...)
+		return nil, err
+	}
+	slog.Debug("http request", append(attrs, "status", resp.StatusCode)...)
+	return resp, nil
+}
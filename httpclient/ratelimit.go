@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter is a client-side token bucket. A nil *Limiter never waits.
+type Limiter struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter allows perMinute requests per minute with bursts of up to
+// burst requests.
+func NewLimiter(perMinute, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		d := l.reserve()
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available and otherwise returns how long
+// to wait before trying again.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) * float64(l.interval))
+}
+
+// Observe pauses the limiter until the server's rate-limit window resets
+// when a response reports that no requests remain.
+func (l *Limiter) Observe(h http.Header) {
+	if l == nil || !rateLimitExhausted(h) {
+		return
+	}
+	d, ok := serverDelay(h)
+	if !ok || d > DefaultRetryPolicy.MaxWait {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*Limiter{}
+)
+
+// SetRateLimit sets the client-side limit for provider in requests per
+// minute; zero or less removes it.
+func SetRateLimit(provider string, perMinute int) {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if perMinute <= 0 {
+		delete(limiters, provider)
+		return
+	}
+	limiters[provider] = NewLimiter(perMinute, max(1, perMinute/4))
+}
+
+// limiterFor returns the shared limiter of provider, or nil if it has none.
+func limiterFor(provider string) *Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	return limiters[provider]
+}
@@ -0,0 +1,190 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how provider requests are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	MaxAttempts int
+	// BaseDelay is the first backoff step; each retry doubles it.
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff step.
+	MaxDelay time.Duration
+	// MaxWait caps how long a server-requested delay (Retry-After or a
+	// rate-limit reset) is honoured; longer waits fail immediately.
+	MaxWait time.Duration
+}
+
+// DefaultRetryPolicy is used by NewProvider.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    8 * time.Second,
+	MaxWait:     60 * time.Second,
+}
+
+// NewProvider returns a client for a model provider: the same guarded
+// transport as New, plus a per-provider token-bucket limiter and retries
+// with exponential backoff on 429, 5xx and dropped connections.
+func NewProvider(provider, call string, timeout time.Duration) *http.Client {
+	c := New(call, timeout)
+	c.Transport = &retryTransport{
+		call:    call,
+		policy:  DefaultRetryPolicy,
+		limiter: limiterFor(provider),
+		next:    c.Transport,
+	}
+	return c
+}
+
+// retryTransport retries transient failures. Request bodies are
+// replayed through Request.GetBody, which http.NewRequest sets for the
+// in-memory readers providers use.
+type retryTransport struct {
+	call    string
+	policy  RetryPolicy
+	limiter *Limiter
+	next    http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if resp != nil {
+			t.limiter.Observe(resp.Header)
+		}
+		// A body that cannot be rewound cannot be sent twice.
+		oneShot := req.Body != nil && req.Body != http.NoBody && req.GetBody == nil
+		last := attempt >= t.policy.MaxAttempts || oneShot
+		if last || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if wait, ok := serverDelay(resp.Header); ok {
+				if wait > t.policy.MaxWait {
+					slog.Info("not retrying, server asked to wait too long", "call", t.call, "status", resp.StatusCode, "wait", wait)
+					return resp, nil
+				}
+				delay = wait
+			}
+			// Drain so the connection can be reused.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			slog.Info("retrying request", "call", t.call, "status", resp.StatusCode, "attempt", attempt, "delay", delay.Round(time.Millisecond))
+		} else {
+			slog.Info("retrying request", "call", t.call, "error", err, "attempt", attempt, "delay", delay.Round(time.Millisecond))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the full-jitter exponential delay before retry attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.BaseDelay << (attempt - 1)
+	if d <= 0 || d > t.policy.MaxDelay {
+		d = t.policy.MaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryable reports whether a response or transport error is transient.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var blocked *BlockedError
+		if errors.As(err, &blocked) {
+			return false
+		}
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.EPIPE) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, io.EOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// serverDelay extracts how long the server asked us to wait, from
+// Retry-After or, when the remaining quota is exhausted, the
+// x-ratelimit-reset headers.
+func serverDelay(h http.Header) (time.Duration, bool) {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0), true
+		}
+	}
+	if !rateLimitExhausted(h) {
+		return 0, false
+	}
+	for _, name := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens", "x-ratelimit-reset"} {
+		if d, ok := parseReset(h.Get(name)); ok {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// rateLimitExhausted reports whether any x-ratelimit-remaining* header is 0.
+func rateLimitExhausted(h http.Header) bool {
+	for name, vals := range h {
+		if strings.HasPrefix(strings.ToLower(name), "x-ratelimit-remaining") && len(vals) > 0 && strings.TrimSpace(vals[0]) == "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseReset understands the three reset formats providers use: a Go-style
+// duration ("1m30s"), a number of seconds, or a Unix timestamp.
+func parseReset(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		if n > 1e9 {
+			return max(time.Until(time.Unix(int64(n), 0)), 0), true
+		}
+		return time.Duration(n * float64(time.Second)), true
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, true
+	}
+	return 0, false
+}
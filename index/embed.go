@@ -0,0 +1,109 @@
+package index
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/ollama"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/openai"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/redact"
+	"github.com/codeforge-ide/codeforgeai.go/secrets"
+	"github.com/codeforge-ide/codeforgeai.go/usage"
+)
+
+// githubModelsInference is the OpenAI-compatible root used when the
+// "openai" provider has no endpoint configured.
+const githubModelsInference = "https://models.inference.ai.azure.com"
+
+// Embedder turns texts into vectors.
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+}
+
+type embedFunc func(texts []string) ([][]float32, modeliface.Usage, error)
+
+// embedder applies the same privacy rules and usage accounting to
+// embeddings as the model pipeline applies to prompts.
+type embedder struct {
+	cfg      *config.Config
+	root     string
+	provider string
+	model    string
+	embed    embedFunc
+}
+
+// NewEmbedder returns the embedder configured in the "index" section,
+// recording its usage for the project at root.
+func NewEmbedder(cfg *config.Config, root string) (Embedder, error) {
+	ic := cfg.Index
+	e := &embedder{cfg: cfg, root: root, provider: ic.Provider, model: ic.Model}
+	switch ic.Provider {
+	case "ollama":
+		client := ollama.NewClient(ic.Endpoint)
+		e.embed = func(texts []string) ([][]float32, modeliface.Usage, error) {
+			return client.Embed(ic.Model, texts)
+		}
+	case "openai":
+		client := &openai.EmbeddingsClient{BaseURL: ic.Endpoint, Model: ic.Model, Provider: "openai"}
+		if client.BaseURL == "" {
+			client.BaseURL = githubModelsInference
+			client.Provider = "githubmodels"
+		}
+		switch {
+		case ic.APIKeyEnv != "":
+			client.APIKey = os.Getenv(ic.APIKeyEnv)
+		case ic.Endpoint != "":
+			client.APIKey = secrets.OpenAIKey()
+		default:
+			client.APIKey = secrets.GithubToken()
+		}
+		e.provider = client.Provider
+		e.embed = client.Embed
+	default:
+		return nil, fmt.Errorf("unknown index provider %q (want ollama or openai)", ic.Provider)
+	}
+	return e, nil
+}
+
+// EmbeddingsURL returns where the embedder configured in the "index"
+// section sends chunks, or "" for an unknown provider.
+func EmbeddingsURL(cfg *config.Config) string {
+	ic := cfg.Index
+	switch ic.Provider {
+	case "ollama":
+		return ollama.NewClient(ic.Endpoint).BaseURL + "/api/embed"
+	case "openai":
+		base := ic.Endpoint
+		if base == "" {
+			base = githubModelsInference
+		}
+		return strings.TrimSuffix(base, "/") + "/embeddings"
+	}
+	return ""
+}
+
+func (e *embedder) Embed(texts []string) ([][]float32, error) {
+	if mode := e.cfg.Redaction.Mode; mode == "all" || (mode == "cloud" && e.provider != "ollama") {
+		r, err := redact.New(e.cfg.Redaction.CustomPatterns)
+		if err != nil {
+			return nil, err
+		}
+		masked := make([]string, len(texts))
+		for i, t := range texts {
+			masked[i] = r.Redact(t)
+		}
+		texts = masked
+	}
+	vectors, u, err := e.embed(texts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := usage.Add(e.cfg, e.root, "embedding", e.provider, e.model, u); err != nil {
+		slog.Warn("could not record usage", "error", err)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,429 @@
+// Package index keeps an on-disk embedding index of a project's files for
+// semantic search and for adding relevant code to prompts.
+package index
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/directory"
+	"github.com/codeforge-ide/codeforgeai.go/utils"
+)
+
+// batchSize is the number of chunks embedded per request.
+const batchSize = 32
+
+// maxQueryChars bounds the text embedded for a query, which for prompt
+// context can be a whole file.
+const maxQueryChars = 4000
+
+var contextDisabled atomic.Bool
+
+// SetContextDisabled turns off project context in prompts for this process
+// (the --no-context flag).
+func SetContextDisabled(off bool) {
+	contextDisabled.Store(off)
+}
+
+// ErrNoIndex is returned by Load when the project has not been indexed.
+var ErrNoIndex = errors.New("project has not been indexed; run 'codeforgeai index'")
+
+// Chunk is a span of lines of a file and its embedding.
+type Chunk struct {
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+}
+
+// File is the indexed state of one file.
+type File struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Chunks  []Chunk   `json:"chunks"`
+}
+
+// Index is the embedding index of one project, keyed by path relative to
+// Root.
+type Index struct {
+	Root     string           `json:"root"`
+	Provider string           `json:"provider"`
+	Model    string           `json:"model"`
+	Updated  time.Time        `json:"updated"`
+	Files    map[string]*File `json:"files"`
+}
+
+// Stats summarizes an Update.
+type Stats struct {
+	Files     int `json:"files"`
+	Added     int `json:"added"`
+	Changed   int `json:"changed"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	Embedded  int `json:"embedded_chunks"`
+}
+
+// Result is a chunk matching a search.
+type Result struct {
+	Path      string  `json:"path"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float64 `json:"score"`
+	Text      string  `json:"text"`
+}
+
+// Path returns the index file of the project at root.
+func Path(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	dir := filepath.Join(config.DataDir(), "index")
+	os.MkdirAll(dir, 0700)
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Exists reports whether the project at root has an index.
+func Exists(root string) bool {
+	_, err := os.Stat(Path(root))
+	return err == nil
+}
+
+// Load reads the index of the project at root.
+func Load(root string) (*Index, error) {
+	data, err := os.ReadFile(Path(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoIndex
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	if ix.Files == nil {
+		ix.Files = map[string]*File{}
+	}
+	return &ix, nil
+}
+
+// Save writes the index atomically.
+func (ix *Index) Save() error {
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return err
+	}
+	path := Path(ix.Root)
+	return utils.WriteFileAtomic(path, data, 0600)
+}
+
+// Clear deletes the index of the project at root.
+func Clear(root string) error {
+	err := os.Remove(Path(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Chunks returns the number of chunks in the index.
+func (ix *Index) Chunks() int {
+	n := 0
+	for _, f := range ix.Files {
+		n += len(f.Chunks)
+	}
+	return n
+}
+
+// skipFile reports whether path holds CodeForgeAI's own output, which
+// must not feed back into the index.
+func skipFile(path string) bool {
+	return strings.HasSuffix(path, ".codeforgedit") || filepath.Base(path) == ".codeforge.json"
+}
+
+// pendingChunk is a chunk waiting to be embedded.
+type pendingChunk struct {
+	path string
+	idx  int
+	text string
+}
+
+// Update brings the index of the project at root up to date, embedding
+// only files whose content changed since the last update. rebuild, or a
+// change of embedding provider or model, starts from scratch. progress, if
+// set, is called after each embedding request. On error the files embedded
+// so far are kept.
+func Update(cfg *config.Config, root string, rebuild bool, progress func(done, total int)) (*Index, Stats, error) {
+	var stats Stats
+	ic := cfg.Index
+	ix, err := Load(root)
+	if err != nil && !errors.Is(err, ErrNoIndex) {
+		slog.Warn("discarding unreadable index", "error", err)
+	}
+	dirty := false
+	if ix == nil || rebuild || ix.Provider != ic.Provider || ix.Model != ic.Model {
+		ix = &Index{Root: root, Provider: ic.Provider, Model: ic.Model, Files: map[string]*File{}}
+		dirty = true
+	}
+
+	paths, err := directory.SelectFiles(root)
+	if err != nil {
+		return nil, stats, err
+	}
+	seen := map[string]bool{}
+	fresh := map[string]*File{}
+	var pending []pendingChunk
+	for _, rel := range paths {
+		if skipFile(rel) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(root, rel))
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 || info.Size() > int64(ic.MaxFileKB)<<10 {
+			continue
+		}
+		old := ix.Files[rel]
+		if old != nil && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			seen[rel] = true
+			stats.Unchanged++
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, rel))
+		if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			continue
+		}
+		seen[rel] = true
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		dirty = true
+		if old != nil && old.Hash == hash {
+			old.ModTime = info.ModTime()
+			stats.Unchanged++
+			continue
+		}
+		if old != nil {
+			stats.Changed++
+		} else {
+			stats.Added++
+		}
+		f := &File{Hash: hash, Size: info.Size(), ModTime: info.ModTime(), Chunks: chunkLines(string(data), ic.ChunkLines, ic.ChunkOverlap)}
+		if len(f.Chunks) == 0 {
+			ix.Files[rel] = f
+			continue
+		}
+		fresh[rel] = f
+		for i, c := range f.Chunks {
+			pending = append(pending, pendingChunk{path: rel, idx: i, text: "File: " + rel + "\n\n" + c.Text})
+		}
+	}
+	for rel := range ix.Files {
+		if !seen[rel] {
+			delete(ix.Files, rel)
+			stats.Removed++
+			dirty = true
+		}
+	}
+
+	err = embedPending(cfg, ix, fresh, pending, progress)
+	stats.Files = len(ix.Files)
+	stats.Embedded = len(pending)
+	if dirty {
+		ix.Updated = time.Now()
+		if serr := ix.Save(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return ix, stats, err
+}
+
+// embedPending embeds the chunks of the fresh files in batches and adds
+// each file to the index once all of its chunks have vectors.
+func embedPending(cfg *config.Config, ix *Index, fresh map[string]*File, pending []pendingChunk, progress func(done, total int)) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	emb, err := NewEmbedder(cfg, ix.Root)
+	if err != nil {
+		return err
+	}
+	remaining := map[string]int{}
+	for _, p := range pending {
+		remaining[p.path]++
+	}
+	for start := 0; start < len(pending); start += batchSize {
+		batch := pending[start:min(start+batchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, p := range batch {
+			texts[i] = p.text
+		}
+		vectors, err := emb.Embed(texts)
+		if err != nil {
+			return fmt.Errorf("embedding with %s %s: %w", cfg.Index.Provider, cfg.Index.Model, err)
+		}
+		for i, p := range batch {
+			f := fresh[p.path]
+			f.Chunks[p.idx].Vector = vectors[i]
+			if remaining[p.path]--; remaining[p.path] == 0 {
+				ix.Files[p.path] = f
+			}
+		}
+		if progress != nil {
+			progress(start+len(batch), len(pending))
+		}
+	}
+	return nil
+}
+
+// chunkLines splits text into windows of size lines that overlap by
+// overlap lines. Blank windows are dropped.
+func chunkLines(text string, size, overlap int) []Chunk {
+	if size <= 0 {
+		size = 40
+	}
+	step := max(1, size-max(0, overlap))
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += step {
+		end := min(start+size, len(lines))
+		body := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(body) != "" {
+			chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: end, Text: body})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// Search returns the k chunks most similar to query with a cosine
+// similarity of at least minScore, skipping chunks of exclude.
+func (ix *Index) Search(query []float32, k int, minScore float64, exclude string) []Result {
+	var results []Result
+	for path, f := range ix.Files {
+		if path == exclude {
+			continue
+		}
+		for _, c := range f.Chunks {
+			score := cosine(query, c.Vector)
+			if score >= minScore {
+				results = append(results, Result{Path: path, StartLine: c.StartLine, EndLine: c.EndLine, Score: score, Text: c.Text})
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Query embeds text and searches the index with it.
+func Query(cfg *config.Config, ix *Index, text string, k int, minScore float64, exclude string) ([]Result, error) {
+	if len(text) > maxQueryChars {
+		text = text[:maxQueryChars]
+	}
+	emb, err := NewEmbedder(cfg, ix.Root)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := emb.Embed([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return ix.Search(vectors[0], k, minScore, exclude), nil
+}
+
+// remoteSearch, if set, searches the index of root in a process that keeps
+// it loaded and up to date, or returns an error to search it here.
+var remoteSearch func(root, query string, k int, minScore float64, exclude string) ([]Result, error)
+
+// SetRemote makes Context ask search first. It is set once at startup,
+// when the daemon is running.
+func SetRemote(search func(root, query string, k int, minScore float64, exclude string) ([]Result, error)) {
+	remoteSearch = search
+}
+
+// search updates the index of root and queries it.
+func search(cfg *config.Config, root, query, exclude string) ([]Result, error) {
+	if remoteSearch != nil {
+		results, err := remoteSearch(root, query, cfg.Index.TopK, cfg.Index.MinScore, exclude)
+		if err == nil {
+			return results, nil
+		}
+		slog.Debug("searching the index in-process", "error", err)
+	}
+	ix, _, err := Update(cfg, root, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Query(cfg, ix, query, cfg.Index.TopK, cfg.Index.MinScore, exclude)
+}
+
+// Context returns the chunks of the project index most relevant to query,
+// formatted for a prompt. It returns "" when the project has no index,
+// top_k is 0, --no-context is set or retrieval fails, so callers can
+// always use it. exclude is a file the prompt already contains.
+func Context(cfg *config.Config, query, exclude string) string {
+	if contextDisabled.Load() || cfg.Index.TopK <= 0 || strings.TrimSpace(query) == "" {
+		return ""
+	}
+	root := config.ProjectRoot()
+	if !Exists(root) {
+		return ""
+	}
+	if exclude != "" {
+		if abs, err := filepath.Abs(exclude); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil {
+				exclude = rel
+			}
+		}
+	}
+	results, err := search(cfg, root, query, exclude)
+	if err != nil {
+		slog.Warn("skipping project context", "error", err)
+		return ""
+	}
+	if len(results) == 0 {
+		return ""
+	}
+	slog.Info("added project context", "chunks", len(results))
+	return FormatResults(results)
+}
+
+// FormatResults renders search results as a prompt section.
+func FormatResults(results []Result) string {
+	var sb strings.Builder
+	sb.WriteString("Relevant code from elsewhere in the project:\n")
+	for _, r := range results {
+		fmt.Fprintf(&sb, "\n--- %s (lines %d-%d) ---\n%s\n", r.Path, r.StartLine, r.EndLine, r.Text)
+	}
+	return sb.String()
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
 )
 
 type Client struct {
@@ -29,13 +31,19 @@ type APYResponse struct {
 func NewClient() *Client {
 	return &Client{
 		baseURL: "https://mcp.astrolescent.com",
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:  httpclient.New("astrolescent api", 30*time.Second),
 	}
 }
 
+// BaseURL returns the Astrolescent API the client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
 func (c *Client) GetPrice() (*PriceResponse, error) {
+	if err := httpclient.CheckURL("astrolescent price", c.baseURL); err != nil {
+		return nil, err
+	}
 	// For demo purposes, simulate the response
 	return &PriceResponse{
 		Raw: map[string]interface{}{
@@ -51,6 +59,9 @@ func (c *Client) GetPrice() (*PriceResponse, error) {
 }
 
 func (c *Client) GetQuote(operation, token, amount, account string) (*QuoteResponse, error) {
+	if err := httpclient.CheckURL("astrolescent quote", c.baseURL); err != nil {
+		return nil, err
+	}
 	// For demo purposes, simulate the response
 	var text string
 	switch operation {
@@ -76,6 +87,9 @@ func (c *Client) GetQuote(operation, token, amount, account string) (*QuoteRespo
 }
 
 func (c *Client) GetAPY() (*APYResponse, error) {
+	if err := httpclient.CheckURL("astrolescent apy", c.baseURL); err != nil {
+		return nil, err
+	}
 	// For demo purposes, simulate the response
 	return &APYResponse{
 		Raw: map[string]interface{}{
@@ -0,0 +1,439 @@
+package githubcopilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/codeforge-ide/codeforgeai.go/jsonrpc"
+)
+
+// DefaultCommand starts the language server of the
+// @github/copilot-language-server npm package.
+var DefaultCommand = []string{"copilot-language-server", "--stdio"}
+
+// Package is the npm package of the language server.
+const Package = "@github/copilot-language-server"
+
+// APIURL is the service the language server sends code and prompts to.
+const APIURL = "https://api.githubcopilot.com"
+
+// Status is the sign-in state reported by the language server.
+type Status struct {
+	// Status is "OK", "AlreadySignedIn", "NotSignedIn", "NotAuthorized"
+	// (signed in without a Copilot subscription) and so on.
+	Status string `json:"status"`
+	User   string `json:"user,omitempty"`
+}
+
+// SignedIn reports whether requests can be made.
+func (s Status) SignedIn() bool {
+	return s.Status == "OK" || s.Status == "AlreadySignedIn" || s.Status == "MaybeOk"
+}
+
+// SignInPrompt starts a device flow sign-in: the user opens
+// VerificationURI and enters UserCode. Status is "AlreadySignedIn" when
+// there is nothing to do.
+type SignInPrompt struct {
+	Status          string `json:"status"`
+	UserCode        string `json:"userCode,omitempty"`
+	VerificationURI string `json:"verificationUri,omitempty"`
+	ExpiresIn       int    `json:"expiresIn,omitempty"`
+	Interval        int    `json:"interval,omitempty"`
+	User            string `json:"user,omitempty"`
+}
+
+// Position is a zero-based line and UTF-16 character offset.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Completion is an inline completion. Text replaces Range, which starts at
+// the beginning of the cursor's line; DisplayText is the part after the
+// cursor.
+type Completion struct {
+	UUID        string `json:"uuid"`
+	Text        string `json:"text"`
+	DisplayText string `json:"displayText"`
+	Range       struct {
+		Start Position `json:"start"`
+		End   Position `json:"end"`
+	} `json:"range"`
+	Position Position `json:"position"`
+}
+
+// Turn is a request of a conversation and, for earlier turns, the reply.
+type Turn struct {
+	Request  string `json:"request"`
+	Response string `json:"response,omitempty"`
+}
+
+// ServerInfo identifies the running language server.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Client drives a Copilot language server process over stdio JSON-RPC.
+type Client struct {
+	Command    []string
+	ServerInfo ServerInfo
+
+	cmd   *exec.Cmd
+	stdin io.Closer
+	conn  *jsonrpc.Conn
+	done  chan struct{}
+	err   error
+
+	mu       sync.Mutex
+	nextID   int
+	progress map[string]func(json.RawMessage)
+	versions map[string]int
+}
+
+// Start runs the language server and initializes it.
+func Start(ctx context.Context, command []string) (*Client, error) {
+	if len(command) == 0 {
+		command = DefaultCommand
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s not found; install it with 'codeforgeai github copilot lsp' or set \"copilot\": {\"command\": [...]}", command[0])
+		}
+		return nil, fmt.Errorf("starting the copilot language server: %w", err)
+	}
+	c := &Client{
+		Command:  command,
+		cmd:      cmd,
+		stdin:    stdin,
+		conn:     jsonrpc.NewConn(stdout, stdin),
+		done:     make(chan struct{}),
+		progress: map[string]func(json.RawMessage){},
+		versions: map[string]int{},
+	}
+	go c.readLoop()
+
+	cwd, _ := os.Getwd()
+	editor := map[string]string{"name": "codeforgeai", "version": "1.0"}
+	params := map[string]interface{}{
+		"processId":        os.Getpid(),
+		"clientInfo":       editor,
+		"rootUri":          pathToURI(cwd),
+		"workspaceFolders": []map[string]string{{"uri": pathToURI(cwd), "name": filepath.Base(cwd)}},
+		"capabilities": map[string]interface{}{
+			"workspace": map[string]interface{}{"workspaceFolders": true},
+			"window":    map[string]interface{}{"workDoneProgress": true},
+		},
+		"initializationOptions": map[string]interface{}{"editorInfo": editor, "editorPluginInfo": editor},
+	}
+	var result struct {
+		ServerInfo ServerInfo `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initializing the copilot language server: %w", err)
+	}
+	c.ServerInfo = result.ServerInfo
+	if err := c.conn.Notify("initialized", map[string]interface{}{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readLoop reads messages until the server exits, answering its requests
+// and routing progress reports.
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		m, err := c.conn.Read()
+		if err != nil {
+			var re *jsonrpc.Error
+			if errors.As(err, &re) {
+				slog.Warn("copilot: skipping malformed message", "error", err)
+				continue
+			}
+			c.err = err
+			return
+		}
+		switch {
+		case m.IsResponse():
+			c.conn.Deliver(m)
+		case m.IsRequest():
+			c.answer(m)
+		case m.Method == "$/progress":
+			var p struct {
+				Token json.RawMessage `json:"token"`
+				Value json.RawMessage `json:"value"`
+			}
+			if json.Unmarshal(m.Params, &p) != nil {
+				continue
+			}
+			c.mu.Lock()
+			handler := c.progress[strings.Trim(string(p.Token), `"`)]
+			c.mu.Unlock()
+			if handler != nil {
+				handler(p.Value)
+			}
+		default:
+			slog.Debug("copilot notification", "method", m.Method, "params", string(m.Params))
+		}
+	}
+}
+
+// answer replies to a request from the server. Nothing it asks for is
+// needed, so configuration is empty and the rest gets a null result.
+func (c *Client) answer(m *jsonrpc.Message) {
+	var result interface{}
+	if m.Method == "workspace/configuration" {
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(m.Params, &p)
+		result = make([]interface{}, len(p.Items))
+	}
+	slog.Debug("copilot request", "method", m.Method)
+	if err := c.conn.Reply(m.ID, result, nil); err != nil {
+		slog.Warn("copilot: could not reply", "method", m.Method, "error", err)
+	}
+}
+
+// call sends a request, giving up when the server exits.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := c.conn.Call(ctx, method, params, result)
+	if err != nil && c.Exited() {
+		return fmt.Errorf("the copilot language server exited: %v", c.err)
+	}
+	return err
+}
+
+// Exited reports whether the language server has stopped.
+func (c *Client) Exited() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close shuts the language server down.
+func (c *Client) Close() error {
+	if !c.Exited() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := c.call(ctx, "shutdown", nil, nil); err == nil {
+			c.conn.Notify("exit", nil)
+		}
+	}
+	c.stdin.Close()
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		return c.cmd.Process.Kill()
+	}
+}
+
+// CheckStatus returns the sign-in state.
+func (c *Client) CheckStatus(ctx context.Context) (Status, error) {
+	var s Status
+	err := c.call(ctx, "checkStatus", map[string]interface{}{"localChecksOnly": false}, &s)
+	return s, err
+}
+
+// SignInInitiate starts a device flow sign-in.
+func (c *Client) SignInInitiate(ctx context.Context) (*SignInPrompt, error) {
+	var p SignInPrompt
+	if err := c.call(ctx, "signInInitiate", map[string]interface{}{}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SignInConfirm waits for the user to enter userCode and returns the new
+// state.
+func (c *Client) SignInConfirm(ctx context.Context, userCode string) (Status, error) {
+	var s Status
+	err := c.call(ctx, "signInConfirm", map[string]interface{}{"userCode": userCode}, &s)
+	return s, err
+}
+
+// SignOut signs out of GitHub Copilot.
+func (c *Client) SignOut(ctx context.Context) (Status, error) {
+	var s Status
+	err := c.call(ctx, "signOut", map[string]interface{}{}, &s)
+	return s, err
+}
+
+// GetCompletions returns inline completions at pos in the document at
+// path whose contents are text, opening it in the server or updating it.
+func (c *Client) GetCompletions(ctx context.Context, path, languageID, text string, pos Position) ([]Completion, error) {
+	uri := pathToURI(path)
+	c.mu.Lock()
+	version, open := c.versions[uri]
+	version++
+	c.versions[uri] = version
+	c.mu.Unlock()
+	var err error
+	if !open {
+		err = c.conn.Notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": languageID, "version": version, "text": text},
+		})
+	} else {
+		err = c.conn.Notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": version},
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rel := path
+	if cwd, err := os.Getwd(); err == nil {
+		if r, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+		}
+	}
+	var result struct {
+		Completions []Completion `json:"completions"`
+	}
+	err = c.call(ctx, "getCompletions", map[string]interface{}{
+		"doc": map[string]interface{}{
+			"uri": uri, "version": version, "position": pos, "languageId": languageID,
+			"relativePath": filepath.ToSlash(rel), "insertSpaces": true, "tabSize": 4,
+		},
+	}, &result)
+	return result.Completions, err
+}
+
+// Converse sends turns as a new conversation and returns the reply to the
+// last one, passing its chunks to onToken (which may be nil) as they
+// arrive. model picks the chat model, "" for the server's default.
+func (c *Client) Converse(ctx context.Context, turns []Turn, model string, onToken func(string)) (string, error) {
+	c.mu.Lock()
+	c.nextID++
+	token := "codeforgeai-" + strconv.Itoa(c.nextID)
+	c.mu.Unlock()
+
+	var reply strings.Builder
+	ended := make(chan error, 1)
+	c.mu.Lock()
+	c.progress[token] = func(raw json.RawMessage) {
+		var v struct {
+			Kind  string `json:"kind"`
+			Reply string `json:"reply"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &v) != nil {
+			return
+		}
+		if v.Reply != "" {
+			reply.WriteString(v.Reply)
+			if onToken != nil {
+				onToken(v.Reply)
+			}
+		}
+		if v.Kind == "end" {
+			var err error
+			if v.Error != nil && v.Error.Message != "" {
+				err = errors.New(v.Error.Message)
+			}
+			select {
+			case ended <- err:
+			default:
+			}
+		}
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.progress, token)
+		c.mu.Unlock()
+	}()
+
+	params := map[string]interface{}{
+		"workDoneToken": token,
+		"turns":         turns,
+		"capabilities":  map[string]interface{}{"skills": []string{}, "allSkills": false},
+		"source":        "panel",
+	}
+	if model != "" {
+		params["model"] = model
+	}
+	var created struct {
+		ConversationID string `json:"conversationId"`
+	}
+	if err := c.call(ctx, "conversation/create", params, &created); err != nil {
+		return "", err
+	}
+	defer func() {
+		// Best effort: the server forgets conversations on exit anyway.
+		dctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		c.call(dctx, "conversation/destroy", map[string]string{"conversationId": created.ConversationID}, nil)
+	}()
+
+	select {
+	case err := <-ended:
+		if err != nil {
+			return "", err
+		}
+	case <-c.done:
+		return "", fmt.Errorf("the copilot language server exited: %v", c.err)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	// The "end" report is the last one, so reply is complete.
+	return reply.String(), nil
+}
+
+// pathToURI converts a path to a file:// URI.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// endOf returns the position after the last character of text.
+func endOf(text string) Position {
+	last := text[strings.LastIndex(text, "\n")+1:]
+	return Position{Line: strings.Count(text, "\n"), Character: len(utf16.Encode([]rune(last)))}
+}
@@ -0,0 +1,287 @@
+package githubcopilot
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/jsonrpc"
+)
+
+// TestMain lets the test binary act as the language server: started with
+// COPILOT_TEST_SERVER set, it serves the client's requests on stdio.
+// COPILOT_TEST_EXIT names a method on which it exits without answering.
+func TestMain(m *testing.M) {
+	if os.Getenv("COPILOT_TEST_SERVER") != "" {
+		fakeServer(os.Getenv("COPILOT_TEST_EXIT"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServer answers like the Copilot language server does, enough for
+// the client's requests.
+func fakeServer(exitOn string) {
+	conn := jsonrpc.NewConn(os.Stdin, os.Stdout)
+	docs := map[string]int{}     // uri -> version
+	texts := map[string]string{} // uri -> text
+	configured := make(chan bool, 1)
+	for {
+		m, err := conn.Read()
+		if err != nil {
+			return
+		}
+		if exitOn != "" && m.Method == exitOn {
+			os.Exit(3)
+		}
+		var p map[string]json.RawMessage
+		json.Unmarshal(m.Params, &p)
+		field := func(name string, v interface{}) { json.Unmarshal(p[name], v) }
+
+		var result interface{}
+		var rerr error
+		switch m.Method {
+		case "":
+			conn.Deliver(m)
+			continue
+		case "initialize":
+			var client struct {
+				Name string `json:"name"`
+			}
+			field("clientInfo", &client)
+			result = map[string]interface{}{"serverInfo": ServerInfo{Name: "fake for " + client.Name, Version: "1.2.3"}}
+		case "initialized":
+			go func() {
+				var items []interface{}
+				err := conn.Call(context.Background(), "workspace/configuration", map[string]interface{}{
+					"items": []map[string]string{{"section": "github"}, {"section": "http"}},
+				}, &items)
+				configured <- err == nil && len(items) == 2 && items[0] == nil
+			}()
+			continue
+		case "checkStatus":
+			// Signed in once the client has answered the configuration
+			// request, which it only gets after sending initialized.
+			go func(id json.RawMessage) {
+				status := "NotSignedIn"
+				select {
+				case ok := <-configured:
+					if ok {
+						status = "OK"
+					}
+				case <-time.After(5 * time.Second):
+				}
+				conn.Reply(id, Status{Status: status}, nil)
+			}(m.ID)
+			continue
+		case "signInInitiate":
+			result = SignInPrompt{Status: "PromptUserDeviceFlow", UserCode: "ABCD-1234", VerificationURI: "https://github.com/login/device", ExpiresIn: 900, Interval: 5}
+		case "signInConfirm":
+			var code string
+			field("userCode", &code)
+			if code != "ABCD-1234" {
+				rerr = &jsonrpc.Error{Code: 1001, Message: "wrong code " + code}
+				break
+			}
+			result = Status{Status: "OK", User: "octocat"}
+		case "textDocument/didOpen", "textDocument/didChange":
+			var doc struct {
+				URI     string `json:"uri"`
+				Version int    `json:"version"`
+				Text    string `json:"text"`
+			}
+			field("textDocument", &doc)
+			var changes []struct {
+				Text string `json:"text"`
+			}
+			field("contentChanges", &changes)
+			if len(changes) > 0 {
+				doc.Text = changes[0].Text
+			}
+			docs[doc.URI], texts[doc.URI] = doc.Version, doc.Text
+			continue
+		case "getCompletions":
+			var req struct {
+				Doc struct {
+					URI          string   `json:"uri"`
+					Version      int      `json:"version"`
+					Position     Position `json:"position"`
+					RelativePath string   `json:"relativePath"`
+				} `json:"doc"`
+			}
+			json.Unmarshal(m.Params, &req)
+			if docs[req.Doc.URI] != req.Doc.Version {
+				rerr = &jsonrpc.Error{Code: 1002, Message: "stale document version"}
+				break
+			}
+			line := strings.Split(texts[req.Doc.URI], "\n")[req.Doc.Position.Line]
+			c := Completion{UUID: strconv.Itoa(req.Doc.Version), Text: line + " // " + req.Doc.RelativePath, DisplayText: " // " + req.Doc.RelativePath, Position: req.Doc.Position}
+			c.Range.End = req.Doc.Position
+			result = map[string]interface{}{"completions": []Completion{c}}
+		case "conversation/create":
+			var token, model string
+			var turns []Turn
+			field("workDoneToken", &token)
+			field("turns", &turns)
+			field("model", &model)
+			conn.Reply(m.ID, map[string]string{"conversationId": "conv-1"}, nil)
+			progress := func(tok string, value interface{}) {
+				conn.Notify("$/progress", map[string]interface{}{"token": tok, "value": value})
+			}
+			progress(token, map[string]string{"kind": "begin"})
+			progress("someone-else", map[string]string{"kind": "report", "reply": "not for you"})
+			last := turns[len(turns)-1].Request
+			for _, chunk := range []string{model + ":", " " + strconv.Itoa(len(turns)) + " turns, ", last} {
+				progress(token, map[string]string{"kind": "report", "reply": chunk})
+			}
+			end := map[string]interface{}{"kind": "end"}
+			if last == "fail" {
+				end["error"] = map[string]string{"message": "quota exceeded"}
+			}
+			progress(token, end)
+			continue
+		case "conversation/destroy", "shutdown":
+		case "exit":
+			os.Exit(0)
+		default:
+			rerr = &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: m.Method}
+		}
+		if m.IsRequest() {
+			conn.Reply(m.ID, result, rerr)
+		}
+	}
+}
+
+// startFake starts the test binary as the language server.
+func startFake(t *testing.T, exitOn string) *Client {
+	t.Setenv("COPILOT_TEST_SERVER", "1")
+	t.Setenv("COPILOT_TEST_EXIT", exitOn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := Start(ctx, []string{os.Args[0], "-test.run=^$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestStart(t *testing.T) {
+	c := startFake(t, "")
+	if c.ServerInfo.Name != "fake for codeforgeai" || c.ServerInfo.Version != "1.2.3" {
+		t.Fatalf("ServerInfo = %+v", c.ServerInfo)
+	}
+	status, err := c.CheckStatus(testContext(t))
+	if err != nil || status.Status != "OK" || !status.SignedIn() {
+		t.Fatalf("CheckStatus = %+v, %v", status, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !c.Exited() {
+		t.Fatal("the server is still running after Close")
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	c := startFake(t, "")
+	ctx := testContext(t)
+	prompt, err := c.SignInInitiate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt.UserCode != "ABCD-1234" || prompt.VerificationURI != "https://github.com/login/device" || prompt.Interval != 5 {
+		t.Fatalf("SignInInitiate = %+v", prompt)
+	}
+	status, err := c.SignInConfirm(ctx, prompt.UserCode)
+	if err != nil || !status.SignedIn() || status.User != "octocat" {
+		t.Fatalf("SignInConfirm = %+v, %v", status, err)
+	}
+	if _, err := c.SignInConfirm(ctx, "WRONG"); err == nil || !strings.Contains(err.Error(), "wrong code WRONG") {
+		t.Fatalf("SignInConfirm with a wrong code = %v", err)
+	}
+}
+
+func TestGetCompletions(t *testing.T) {
+	c := startFake(t, "")
+	ctx := testContext(t)
+	path := t.TempDir() + "/main.go"
+	for i, text := range []string{"package main\nfunc main() {", "package main\n\nfunc main() {"} {
+		pos := endOf(text)
+		completions, err := c.GetCompletions(ctx, path, LanguageID(path), text, pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(completions) != 1 {
+			t.Fatalf("got %d completions", len(completions))
+		}
+		got := completions[0]
+		// The document is opened, then changed: its version goes up.
+		if got.UUID != strconv.Itoa(i+1) || got.Text != "func main() {"+got.DisplayText || got.Position != pos {
+			t.Fatalf("completion %d = %+v", i, got)
+		}
+	}
+}
+
+func TestConverse(t *testing.T) {
+	c := startFake(t, "")
+	ctx := testContext(t)
+	var chunks []string
+	reply, err := c.Converse(ctx, []Turn{{Request: "hi", Response: "hello"}, {Request: "explain"}}, "gpt-4o", func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "gpt-4o: 2 turns, explain"; reply != want {
+		t.Fatalf("reply = %q, want %q", reply, want)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("chunks = %q, want three", chunks)
+	}
+
+	if _, err := c.Converse(ctx, []Turn{{Request: "fail"}}, "", nil); err == nil || err.Error() != "quota exceeded" {
+		t.Fatalf("Converse ending in an error = %v", err)
+	}
+}
+
+func TestServerExited(t *testing.T) {
+	tests := []struct {
+		method string
+		call   func(c *Client, ctx context.Context) error
+	}{
+		{"getCompletions", func(c *Client, ctx context.Context) error {
+			_, err := c.GetCompletions(ctx, "a.go", "go", "x", Position{})
+			return err
+		}},
+		{"conversation/create", func(c *Client, ctx context.Context) error {
+			_, err := c.Converse(ctx, []Turn{{Request: "hi"}}, "", nil)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			c := startFake(t, tt.method)
+			err := tt.call(c, testContext(t))
+			if err == nil || !strings.Contains(err.Error(), "the copilot language server exited") {
+				t.Fatalf("error = %v, want one saying the server exited", err)
+			}
+			if !c.Exited() {
+				t.Fatal("Exited() = false")
+			}
+			if _, err := c.CheckStatus(testContext(t)); err == nil {
+				t.Fatal("requests still succeed after the server exited")
+			}
+		})
+	}
+}
@@ -1,13 +1,193 @@
 package githubcopilot
 
-import "github.com/codeforge-ide/codeforgeai.go/models"
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// ErrSamplingUnsupported is returned for requests with a temperature or
+// max_tokens, which Copilot Chat does not take.
+var ErrSamplingUnsupported = errors.New("github copilot does not support temperature or max_tokens")
+
+// CopilotModel answers through GitHub Copilot: prompts and conversations
+// go to Copilot Chat and fills to inline completions.
 type CopilotModel struct {
-	// ...fields...
+	// Command starts the language server; empty for DefaultCommand.
+	Command []string
+	// Model is the Copilot Chat model, "" for the account's default.
+	Model string
+	// Timeout bounds a chat reply; zero means two minutes.
+	Timeout time.Duration
+}
+
+func NewCopilotModel(command []string, model string) *CopilotModel {
+	return &CopilotModel{Command: command, Model: model}
 }
 
 func (c *CopilotModel) SendRequest(prompt string, config interface{}) (string, error) {
-	// TODO: Implement Copilot LSP protocol
-	return "", nil
+	return c.ChatStream([]modeliface.Message{{Role: "user", Content: prompt}}, config, nil)
+}
+
+// SendRequestContext is SendRequest stopped when ctx is cancelled.
+func (c *CopilotModel) SendRequestContext(ctx context.Context, prompt string, config interface{}) (string, error) {
+	if err := checkSampling(config); err != nil {
+		return "", err
+	}
+	return c.converse(ctx, []modeliface.Message{{Role: "user", Content: prompt}}, nil)
+}
+
+func (c *CopilotModel) Chat(messages []modeliface.Message, config interface{}) (string, error) {
+	return c.ChatStream(messages, config, nil)
+}
+
+// ChatStream sends messages as a new Copilot conversation. Copilot has no
+// system role, so system messages are put before the first request.
+func (c *CopilotModel) ChatStream(messages []modeliface.Message, config interface{}, onToken func(string)) (string, error) {
+	if err := checkSampling(config); err != nil {
+		return "", err
+	}
+	return c.converse(context.Background(), messages, onToken)
+}
+
+// checkSampling rejects the sampling options of OpenAI-style requests
+// rather than ignoring them.
+func checkSampling(config interface{}) error {
+	opts, _ := config.(map[string]interface{})
+	for _, k := range []string{"temperature", "max_tokens"} {
+		if _, ok := opts[k]; ok {
+			return ErrSamplingUnsupported
+		}
+	}
+	return nil
+}
+
+func (c *CopilotModel) converse(ctx context.Context, messages []modeliface.Message, onToken func(string)) (string, error) {
+	turns, err := toTurns(messages)
+	if err != nil {
+		return "", err
+	}
+	client, err := Shared(c.Command)
+	if err != nil {
+		return "", err
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return client.Converse(ctx, turns, c.Model, onToken)
+}
+
+// Fill asks for an inline completion at the end of prefix in a document
+// made of prefix and suffix, named by the "file_path" option.
+func (c *CopilotModel) Fill(ctx context.Context, prefix, suffix string, config interface{}, onToken func(string)) (string, error) {
+	opts, _ := config.(map[string]interface{})
+	path, _ := opts["file_path"].(string)
+	if path == "" {
+		path = "untitled"
+	}
+	client, err := Shared(c.Command)
+	if err != nil {
+		return "", err
+	}
+	completions, err := client.GetCompletions(ctx, path, LanguageID(path), prefix+suffix, endOf(prefix))
+	if err != nil || len(completions) == 0 {
+		return "", err
+	}
+	// Text replaces the whole line; keep only what goes after the cursor.
+	text := completions[0].DisplayText
+	line := prefix[strings.LastIndex(prefix, "\n")+1:]
+	if first := completions[0]; first.Range.Start.Character == 0 && strings.HasPrefix(first.Text, line) {
+		text = first.Text[len(line):]
+	}
+	if onToken != nil && text != "" {
+		onToken(text)
+	}
+	return text, nil
+}
+
+// toTurns pairs user messages with the assistant replies after them.
+func toTurns(messages []modeliface.Message) ([]Turn, error) {
+	var turns []Turn
+	var system []string
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "assistant":
+			if len(turns) == 0 {
+				return nil, errors.New("copilot: conversation starts with an assistant message")
+			}
+			turns[len(turns)-1].Response += m.Content
+		default:
+			turns = append(turns, Turn{Request: m.Content})
+		}
+	}
+	if len(turns) == 0 || turns[len(turns)-1].Response != "" {
+		return nil, errors.New("copilot: conversation must end with a user message")
+	}
+	if len(system) > 0 {
+		turns[0].Request = strings.Join(system, "\n\n") + "\n\n" + turns[0].Request
+	}
+	return turns, nil
+}
+
+var languageIDs = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "javascriptreact",
+	".ts": "typescript", ".tsx": "typescriptreact", ".rs": "rust", ".sol": "solidity",
+	".java": "java", ".c": "c", ".h": "c", ".cpp": "cpp", ".cs": "csharp", ".rb": "ruby",
+	".php": "php", ".sh": "shellscript", ".md": "markdown", ".json": "json",
+	".yaml": "yaml", ".yml": "yaml", ".html": "html", ".css": "css", ".sql": "sql",
 }
-var _ models.Model = (*CopilotModel)(nil)
+
+// LanguageID returns the LSP language identifier for path, "plaintext"
+// when the extension is unknown.
+func LanguageID(path string) string {
+	if id, ok := languageIDs[strings.ToLower(filepath.Ext(path))]; ok {
+		return id
+	}
+	return "plaintext"
+}
+
+var (
+	sharedMu      sync.Mutex
+	sharedClients = map[string]*Client{}
+)
+
+// Shared returns a running client for command, starting the language
+// server on first use and again if it has exited. Clients stay up until
+// the process ends.
+func Shared(command []string) (*Client, error) {
+	if len(command) == 0 {
+		command = DefaultCommand
+	}
+	key := strings.Join(command, "\x00")
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if c := sharedClients[key]; c != nil && !c.Exited() {
+		return c, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c, err := Start(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	sharedClients[key] = c
+	return c, nil
+}
+
+var (
+	_ modeliface.Model              = (*CopilotModel)(nil)
+	_ modeliface.ChatModel          = (*CopilotModel)(nil)
+	_ modeliface.StreamingChatModel = (*CopilotModel)(nil)
+	_ modeliface.FillModel          = (*CopilotModel)(nil)
+	_ modeliface.ContextModel       = (*CopilotModel)(nil)
+)
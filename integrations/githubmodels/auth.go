@@ -0,0 +1,71 @@
+package githubmodels
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+)
+
+// GithubAPIUserURL returns the authenticated user of a token.
+const GithubAPIUserURL = "https://api.github.com/user"
+
+// TokenInfo describes the owner and permissions of a GitHub token.
+type TokenInfo struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	// Scopes lists the OAuth scopes of a classic token. Fine-grained and
+	// app tokens report none, so FineGrained is set instead.
+	Scopes      []string `json:"scopes"`
+	FineGrained bool     `json:"fine_grained"`
+	// RateLimitRemaining is the remaining REST API quota, or -1 if unknown.
+	RateLimitRemaining int `json:"rate_limit_remaining"`
+}
+
+// WhoAmI verifies token against the GitHub API and reports its owner and
+// scopes.
+func WhoAmI(token string) (*TokenInfo, error) {
+	if err := httpclient.CheckURL("github api user", GithubAPIUserURL); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", GithubAPIUserURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := httpclient.NewProvider("github", "github api user", 15*time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("GitHub rejected the token (401 Unauthorized); it may be expired or revoked")
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("github API error (%s): %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	info := &TokenInfo{RateLimitRemaining: -1}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	if scopes, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		for _, s := range strings.Split(strings.Join(scopes, ","), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				info.Scopes = append(info.Scopes, s)
+			}
+		}
+	} else {
+		info.FineGrained = true
+	}
+	if v := resp.Header.Get("X-RateLimit-Remaining"); v != "" {
+		fmt.Sscan(v, &info.RateLimitRemaining)
+	}
+	return info, nil
+}
@@ -1,39 +1,114 @@
 package githubmodels
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
 )
 
-const githubModelsCatalogURL = "https://models.github.ai/catalog/models"
+// CatalogURL is the GitHub Models catalog endpoint.
+const CatalogURL = "https://models.github.ai/catalog/models"
+
+// ModelLimits are the token limits of a catalog model.
+type ModelLimits struct {
+	MaxInputTokens  int `json:"max_input_tokens"`
+	MaxOutputTokens int `json:"max_output_tokens"`
+}
 
 type ModelCatalogEntry struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Tags        []string `json:"tags"`
-	// Add more fields as needed
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	Publisher        string      `json:"publisher"`
+	Description      string      `json:"description,omitempty"`
+	Summary          string      `json:"summary"`
+	Tags             []string    `json:"tags"`
+	Capabilities     []string    `json:"capabilities"`
+	InputModalities  []string    `json:"supported_input_modalities"`
+	OutputModalities []string    `json:"supported_output_modalities"`
+	RateLimitTier    string      `json:"rate_limit_tier"`
+	Limits           ModelLimits `json:"limits"`
+}
+
+// ShortName returns the ID without its publisher prefix ("openai/gpt-4o"
+// becomes "gpt-4o"), which is what the inference endpoint accepts.
+func (m ModelCatalogEntry) ShortName() string {
+	if i := strings.LastIndex(m.ID, "/"); i >= 0 {
+		return m.ID[i+1:]
+	}
+	return m.ID
+}
+
+// ContextLength returns the maximum number of input tokens.
+func (m ModelCatalogEntry) ContextLength() int {
+	return m.Limits.MaxInputTokens
+}
+
+// ToolCalling reports whether the model supports function/tool calls.
+func (m ModelCatalogEntry) ToolCalling() bool {
+	return slices.Contains(m.Capabilities, "tool-calling")
+}
+
+// Vision reports whether the model accepts image input.
+func (m ModelCatalogEntry) Vision() bool {
+	return slices.Contains(m.InputModalities, "image")
+}
+
+// Has reports whether the model has a tag or capability. "vision" and
+// "tool-calling" are matched against the typed fields.
+func (m ModelCatalogEntry) Has(feature string) bool {
+	feature = strings.ToLower(feature)
+	switch feature {
+	case "vision", "image":
+		return m.Vision()
+	case "tool-calling", "tools":
+		return m.ToolCalling()
+	}
+	return slices.Contains(m.Tags, feature) || slices.Contains(m.Capabilities, feature) ||
+		slices.Contains(m.InputModalities, feature) || slices.Contains(m.OutputModalities, feature)
 }
 
 type ModelCatalogResponse struct {
 	Models []ModelCatalogEntry `json:"models"`
 }
 
+// catalogCache is the on-disk copy of the catalog.
+type catalogCache struct {
+	FetchedAt time.Time           `json:"fetched_at"`
+	Models    []ModelCatalogEntry `json:"models"`
+}
+
+// CatalogFile returns the path of the cached catalog.
+func CatalogFile() string {
+	return filepath.Join(config.DataDir(), "githubmodels_catalog.json")
+}
+
 // FetchModelCatalog fetches the model catalog from GitHub Models API.
 func FetchModelCatalog(token string) ([]ModelCatalogEntry, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest("GET", githubModelsCatalogURL, nil)
+	if err := httpclient.CheckURL("github models catalog fetch", CatalogURL); err != nil {
+		return nil, err
+	}
+	client := httpclient.NewProvider("githubmodels", "github models catalog fetch", 30*time.Second)
+	req, err := http.NewRequest("GET", CatalogURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
 	resp, err := client.Do(req)
@@ -45,23 +120,141 @@ func FetchModelCatalog(token string) ([]ModelCatalogEntry, error) {
 		b, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("github models catalog error: %s", string(b))
 	}
-	var catalog ModelCatalogResponse
-	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
 		return nil, err
 	}
-	return catalog.Models, nil
+	// The catalog is a bare array; older deployments wrapped it in an
+	// object.
+	var models []ModelCatalogEntry
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &models)
+	} else {
+		var catalog ModelCatalogResponse
+		err = json.Unmarshal(trimmed, &catalog)
+		models = catalog.Models
+	}
+	return models, err
+}
+
+// LoadCatalog returns the cached catalog, fetching a fresh copy when the
+// cache is older than refresh or force is set. If the fetch fails, a stale
+// cache is returned along with a logged warning.
+func LoadCatalog(token string, refresh time.Duration, force bool) ([]ModelCatalogEntry, time.Time, error) {
+	var cached catalogCache
+	if data, err := os.ReadFile(CatalogFile()); err == nil {
+		json.Unmarshal(data, &cached)
+	}
+	if !force && len(cached.Models) > 0 && time.Since(cached.FetchedAt) < refresh {
+		return cached.Models, cached.FetchedAt, nil
+	}
+	models, err := FetchModelCatalog(token)
+	if err != nil {
+		if len(cached.Models) > 0 {
+			slog.Warn("using stale github models catalog", "fetched_at", cached.FetchedAt, "error", err)
+			return cached.Models, cached.FetchedAt, nil
+		}
+		return nil, time.Time{}, err
+	}
+	cached = catalogCache{FetchedAt: time.Now(), Models: models}
+	if data, err := json.MarshalIndent(cached, "", "  "); err == nil {
+		if err := os.WriteFile(CatalogFile(), data, 0600); err != nil {
+			slog.Warn("could not cache github models catalog", "error", err)
+		}
+	}
+	return models, cached.FetchedAt, nil
+}
+
+// CatalogRefresh returns how long the cached catalog is used before it is
+// fetched again.
+func CatalogRefresh(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.GithubModelsCatalogHours) * time.Hour
 }
 
-// UpdateConfigWithModelList updates the config with the fetched model list.
-func UpdateConfigWithModelList(cfg *config.Config, models []ModelCatalogEntry) bool {
-	var names []string
+// FindModel returns the catalog entry whose ID or short name is name.
+func FindModel(models []ModelCatalogEntry, name string) (ModelCatalogEntry, bool) {
 	for _, m := range models {
-		names = append(names, m.ID)
+		if strings.EqualFold(m.ID, name) || strings.EqualFold(m.ShortName(), name) {
+			return m, true
+		}
+	}
+	return ModelCatalogEntry{}, false
+}
+
+// ErrUnknownModel is wrapped by ValidateModel errors.
+var ErrUnknownModel = errors.New("unknown github models model")
+
+// ValidateModel checks name against the catalog and suggests the closest
+// model names when it is not found.
+func ValidateModel(models []ModelCatalogEntry, name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := FindModel(models, name); ok {
+		return nil
+	}
+	if s := Suggest(models, name, 3); len(s) > 0 {
+		return fmt.Errorf("%w %q; did you mean %s?", ErrUnknownModel, name, strings.Join(quoteAll(s), " or "))
+	}
+	return fmt.Errorf("%w %q; run 'codeforgeai github-models list' to see available models", ErrUnknownModel, name)
+}
+
+// Suggest returns up to n model names close to name.
+func Suggest(models []ModelCatalogEntry, name string, n int) []string {
+	type candidate struct {
+		name string
+		dist int
+	}
+	var cands []candidate
+	lname := strings.ToLower(name)
+	for _, m := range models {
+		short := m.ShortName()
+		d := levenshtein(lname, strings.ToLower(short))
+		if strings.Contains(strings.ToLower(short), lname) || strings.Contains(lname, strings.ToLower(short)) {
+			d = min(d, 2)
+		}
+		if d <= max(2, len(name)/3) {
+			cands = append(cands, candidate{short, d})
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].dist < cands[j].dist })
+	var out []string
+	for _, c := range cands {
+		if len(out) == n {
+			break
+		}
+		if !slices.Contains(out, c.name) {
+			out = append(out, c.name)
+		}
+	}
+	return out
+}
+
+func quoteAll(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = fmt.Sprintf("%q", n)
+	}
+	return out
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
 	}
-	joined := strings.Join(names, ",")
-	if cfg.GithubModelsList != joined {
-		cfg.GithubModelsList = joined
-		return true
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
 	}
-	return false
+	return prev[len(b)]
 }
@@ -1,16 +1,20 @@
 package githubmodels
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
 )
 
 const (
@@ -29,6 +33,16 @@ type ChatRequest struct {
 	Messages []Message `json:"messages"`
 	Model    string    `json:"model"`
 	Stream   bool      `json:"stream,omitempty"`
+	// StreamOptions asks a streaming response to end with a usage chunk.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	// Temperature and MaxTokens are sent only when set.
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+}
+
+// StreamOptions configures a streaming chat completion.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // ChatResponse is a minimal response struct for non-streaming.
@@ -38,6 +52,7 @@ type ChatResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage modeliface.Usage `json:"usage"`
 }
 
 // Client for GitHub Models API.
@@ -46,6 +61,8 @@ type Client struct {
 	Model    string
 	Token    string
 	Timeout  time.Duration
+
+	lastUsage modeliface.Usage
 }
 
 // NewClient creates a new GitHub Models client.
@@ -69,36 +86,49 @@ func NewClient(token string, model string, endpoint string) *Client {
 	}
 }
 
-// Chat sends a chat completion request using Go's http client.
-func (c *Client) Chat(messages []Message, stream bool) (string, error) {
-	reqBody := ChatRequest{
-		Messages: messages,
-		Model:    c.Model,
-		Stream:   stream,
-	}
+// post sends a chat completion request and returns the successful response.
+func (c *Client) post(ctx context.Context, reqBody ChatRequest) (*http.Response, error) {
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", c.Endpoint, bytes.NewReader(body))
+	if err := httpclient.CheckURL("github models chat", c.Endpoint); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoint, bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	client := &http.Client{Timeout: c.Timeout}
+	client := httpclient.NewProvider("githubmodels", "github models chat", c.Timeout)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		b, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("github models API error: %s", string(b))
+		return nil, fmt.Errorf("github models API error (%s): %s", resp.Status, string(b))
+	}
+	return resp, nil
+}
+
+// Chat sends a chat completion request using Go's http client.
+func (c *Client) Chat(messages []Message, stream bool) (string, error) {
+	return c.chat(context.Background(), ChatRequest{Messages: messages, Stream: stream})
+}
+
+func (c *Client) chat(ctx context.Context, req ChatRequest) (string, error) {
+	req.Model = c.Model
+	stream := req.Stream
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
 	if stream {
 		// For streaming, just return the raw output for now
@@ -127,53 +157,72 @@ func (c *Client) Chat(messages []Message, stream bool) (string, error) {
 	if len(chatResp.Choices) == 0 {
 		return "", errors.New("no choices in response")
 	}
+	c.lastUsage = chatResp.Usage
 	return chatResp.Choices[0].Message.Content, nil
 }
 
-// ChatWithWget sends a chat completion request using wget as a fallback.
-func (c *Client) ChatWithWget(messages []Message, stream bool) (string, error) {
-	reqBody := ChatRequest{
-		Messages: messages,
-		Model:    c.Model,
-		Stream:   stream,
-	}
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
+// streamChunk is one server-sent event of a streaming response.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *modeliface.Usage `json:"usage"`
+}
 
-	tmpFile, err := os.CreateTemp("", "githubmodels_payload_*.json")
+// ChatStream sends a streaming chat completion request and passes each
+// piece of the reply to onToken as it arrives. It returns the whole reply.
+func (c *Client) ChatStream(messages []Message, onToken func(string)) (string, error) {
+	return c.chatStream(ChatRequest{Messages: messages}, onToken)
+}
+
+func (c *Client) chatStream(req ChatRequest, onToken func(string)) (string, error) {
+	req.Model = c.Model
+	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	resp, err := c.post(context.Background(), req)
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(tmpFile.Name())
-	if _, err := tmpFile.Write(body); err != nil {
-		return "", err
-	}
-	tmpFile.Close()
+	defer resp.Body.Close()
 
-	args := []string{
-		"--header=Content-Type: application/json",
-		"--header=Authorization: Bearer " + c.Token,
-		"--post-file=" + tmpFile.Name(),
-		"-q", "-O", "-", c.Endpoint,
-	}
-	cmd := exec.Command("wget", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("wget error: %v\n%s", err, string(out))
+	var sb strings.Builder
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return sb.String(), fmt.Errorf("github models stream: %w", err)
+		}
+		if chunk.Usage != nil {
+			c.lastUsage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			sb.WriteString(choice.Delta.Content)
+			if onToken != nil {
+				onToken(choice.Delta.Content)
+			}
+		}
 	}
-	return string(out), nil
+	return sb.String(), sc.Err()
 }
 
-// ChatAuto tries Go http client, falls back to wget if needed.
+// ChatAuto sends a chat completion request. Transient failures and rate
+// limits are retried by the shared provider transport.
 func (c *Client) ChatAuto(messages []Message, stream bool) (string, error) {
-	resp, err := c.Chat(messages, stream)
-	if err == nil {
-		return resp, nil
-	}
-	// fallback to wget
-	return c.ChatWithWget(messages, stream)
+	return c.Chat(messages, stream)
 }
 
 // Helper for simple prompt.
@@ -214,8 +263,83 @@ func (c *Client) ImagePrompt(prompt string, imageB64 string) (string, error) {
 	return c.ChatAuto(msgs, false)
 }
 
-// Ensure *Client implements models.Model interface
+// SendRequest sends a single prompt. config can be nil or a map with the
+// sampling options "temperature" and "max_tokens".
 func (c *Client) SendRequest(prompt string, config interface{}) (string, error) {
-	// Use SimplePrompt for general, or allow config to select other helpers
-	return c.SimplePrompt(prompt)
+	return c.SendRequestContext(context.Background(), prompt, config)
+}
+
+// SendRequestContext is SendRequest stopped when ctx is cancelled.
+func (c *Client) SendRequestContext(ctx context.Context, prompt string, config interface{}) (string, error) {
+	return c.chat(ctx, withSampling(ChatRequest{Messages: []Message{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: prompt},
+	}}, config))
+}
+
+// withSampling sets the temperature and max_tokens of req from config.
+func withSampling(req ChatRequest, config interface{}) ChatRequest {
+	opts, _ := config.(map[string]interface{})
+	if t, ok := toFloat(opts["temperature"]); ok {
+		req.Temperature = &t
+	}
+	if n, ok := toFloat(opts["max_tokens"]); ok {
+		tokens := int(n)
+		req.MaxTokens = &tokens
+	}
+	return req
+}
+
+// toFloat converts a number decoded from JSON or set in code.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ChatAdapter exposes a Client as a modeliface.ChatModel.
+type ChatAdapter struct {
+	*Client
+}
+
+// Chat sends a whole conversation. A default system message is added if the
+// conversation has none; config is as for SendRequest.
+func (a ChatAdapter) Chat(conversation []modeliface.Message, config interface{}) (string, error) {
+	return a.chat(context.Background(), withSampling(ChatRequest{Messages: apiMessages(conversation)}, config))
+}
+
+// ChatStream is Chat with the reply streamed to onToken.
+func (a ChatAdapter) ChatStream(conversation []modeliface.Message, config interface{}, onToken func(string)) (string, error) {
+	return a.chatStream(withSampling(ChatRequest{Messages: apiMessages(conversation)}, config), onToken)
+}
+
+func apiMessages(conversation []modeliface.Message) []Message {
+	var msgs []Message
+	if len(conversation) == 0 || conversation[0].Role != "system" {
+		msgs = append(msgs, Message{Role: "system", Content: "You are a helpful assistant."})
+	}
+	for _, m := range conversation {
+		msgs = append(msgs, Message{Role: m.Role, Content: m.Content})
+	}
+	return msgs
+}
+
+var _ modeliface.ChatModel = ChatAdapter{}
+var _ modeliface.StreamingChatModel = ChatAdapter{}
+var _ modeliface.ContextModel = ChatAdapter{}
+
+// LastUsage returns the token counts of the most recent response.
+func (c *Client) LastUsage() modeliface.Usage {
+	return c.lastUsage
 }
@@ -0,0 +1,51 @@
+package githubmodels
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+func TestSamplingOptions(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer srv.Close()
+	a := ChatAdapter{NewClient("token", "gpt-4o", srv.URL)}
+	conversation := []modeliface.Message{{Role: "user", Content: "hi"}}
+
+	tests := []struct {
+		name        string
+		config      interface{}
+		temperature interface{}
+		maxTokens   interface{}
+	}{
+		{"none", nil, nil, nil},
+		{"other options only", map[string]interface{}{"operation": "chat"}, nil, nil},
+		{"zero temperature", map[string]interface{}{"temperature": 0.0}, 0.0, nil},
+		{"both", map[string]interface{}{"temperature": 0.7, "max_tokens": 64}, 0.7, 64.0},
+		{"decoded from JSON", map[string]interface{}{"temperature": json.Number("1"), "max_tokens": 10.0}, 1.0, 10.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.Chat(conversation, tt.config); err != nil {
+				t.Fatal(err)
+			}
+			if got["temperature"] != tt.temperature || got["max_tokens"] != tt.maxTokens {
+				t.Errorf("sent temperature %v, max_tokens %v, want %v, %v", got["temperature"], got["max_tokens"], tt.temperature, tt.maxTokens)
+			}
+			if _, err := a.SendRequest("hi", tt.config); err != nil {
+				t.Fatal(err)
+			}
+			if got["temperature"] != tt.temperature || got["max_tokens"] != tt.maxTokens {
+				t.Errorf("SendRequest sent temperature %v, max_tokens %v", got["temperature"], got["max_tokens"])
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// fimTemplates are the fill-in-the-middle prompts of common code models,
+// keyed by model name without its tag.
+var fimTemplates = map[string]string{
+	"qwen2.5-coder":  "<|fim_prefix|>{prefix}<|fim_suffix|>{suffix}<|fim_middle|>",
+	"codegemma":      "<|fim_prefix|>{prefix}<|fim_suffix|>{suffix}<|fim_middle|>",
+	"starcoder":      "<fim_prefix>{prefix}<fim_suffix>{suffix}<fim_middle>",
+	"starcoder2":     "<fim_prefix>{prefix}<fim_suffix>{suffix}<fim_middle>",
+	"codellama":      "<PRE> {prefix} <SUF>{suffix} <MID>",
+	"deepseek-coder": "<｜fim▁begin｜>{prefix}<｜fim▁hole｜>{suffix}<｜fim▁end｜>",
+	"codestral":      "[SUFFIX]{suffix}[PREFIX]{prefix}",
+}
+
+// FIMTemplate returns the fill-in-the-middle template of model, with
+// {prefix} and {suffix} placeholders, or "" when there is none. The model
+// name, without its tag and registry path, matches the longest key it
+// starts with, in custom first and then in the built-in templates.
+func FIMTemplate(model string, custom map[string]string) string {
+	name, _, _ := strings.Cut(model, ":")
+	name = strings.ToLower(name[strings.LastIndex(name, "/")+1:])
+	for _, templates := range []map[string]string{custom, fimTemplates} {
+		best := ""
+		for key := range templates {
+			if strings.HasPrefix(name, strings.ToLower(key)) && len(key) > len(best) {
+				best = key
+			}
+		}
+		if best != "" {
+			return templates[best]
+		}
+	}
+	return ""
+}
+
+type generateRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	Suffix    string                 `json:"suffix,omitempty"`
+	Raw       bool                   `json:"raw,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+type generateResponse struct {
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	Error           string `json:"error,omitempty"`
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
+}
+
+// Fill completes the code between prefix and suffix with the generate API.
+// Models with a fill-in-the-middle template (see FIMTemplate) get a raw
+// prompt built from it; others get the suffix parameter, which Ollama
+// renders with the model's own template. config is as for SendRequest,
+// except that "system" and "format" are ignored. The "stop" sequences of
+// the options block are also checked here, so the stream is abandoned as
+// soon as one appears; cancelling ctx abandons it too.
+func (o *OllamaModel) Fill(ctx context.Context, prefix, suffix string, config interface{}, onToken func(string)) (string, error) {
+	opts, _ := config.(map[string]interface{})
+	reqBody := generateRequest{Model: o.Model, Prompt: prefix, Suffix: suffix, KeepAlive: o.KeepAlive}
+	if tmpl := FIMTemplate(o.Model, o.FIMTemplates); tmpl != "" {
+		reqBody.Prompt = strings.NewReplacer("{prefix}", prefix, "{suffix}", suffix).Replace(tmpl)
+		reqBody.Suffix, reqBody.Raw = "", true
+	}
+	genOpts, _ := opts["options"].(map[string]interface{})
+	if len(genOpts) > 0 {
+		reqBody.Options = genOpts
+	}
+	var stops []string
+	switch s := genOpts["stop"].(type) {
+	case []string:
+		stops = s
+	case []interface{}:
+		for _, v := range s {
+			if str, ok := v.(string); ok && str != "" {
+				stops = append(stops, str)
+			}
+		}
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := BaseURL(o.Endpoint) + "/api/generate"
+	if err := httpclient.CheckURL("ollama generate", endpoint); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := httpclient.NewProvider("ollama", "ollama generate", o.Timeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	// Closing the body early makes the server stop generating.
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama API error: %s", string(b))
+	}
+
+	o.lastUsage = modeliface.Usage{}
+	var result strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var r generateResponse
+		if err := decoder.Decode(&r); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+		if r.Error != "" {
+			return "", errors.New(r.Error)
+		}
+		text := result.String() + r.Response
+		cut := len(text)
+		for _, stop := range stops {
+			if i := strings.Index(text, stop); i >= 0 && i < cut {
+				cut = i
+			}
+		}
+		if cut < len(text) {
+			// A stop sequence can start in an earlier chunk, so the text
+			// already passed on may end with part of it.
+			if cut > result.Len() && onToken != nil {
+				onToken(text[result.Len():cut])
+			}
+			return text[:cut], nil
+		}
+		result.WriteString(r.Response)
+		if onToken != nil && r.Response != "" {
+			onToken(r.Response)
+		}
+		if r.Done {
+			o.lastUsage = modeliface.Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
+			break
+		}
+	}
+	return result.String(), nil
+}
+
+var _ modeliface.FillModel = (*OllamaModel)(nil)
@@ -0,0 +1,314 @@
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// ErrNotRunning is returned when the Ollama server cannot be reached.
+var ErrNotRunning = errors.New("ollama server is not reachable")
+
+// ModelDetails describes the weights of a model.
+type ModelDetails struct {
+	Format            string `json:"format"`
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// LocalModel is a model available on the Ollama server (/api/tags).
+type LocalModel struct {
+	Name       string       `json:"name"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Details    ModelDetails `json:"details"`
+}
+
+// RunningModel is a model currently loaded in memory (/api/ps).
+type RunningModel struct {
+	Name      string       `json:"name"`
+	Size      int64        `json:"size"`
+	SizeVRAM  int64        `json:"size_vram"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Details   ModelDetails `json:"details"`
+}
+
+// ModelInfo is the /api/show description of a model.
+type ModelInfo struct {
+	Modelfile    string                 `json:"modelfile"`
+	Parameters   string                 `json:"parameters"`
+	Template     string                 `json:"template"`
+	Details      ModelDetails           `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+}
+
+// PullProgress is one status line streamed by /api/pull.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Client manages the models of an Ollama server.
+type Client struct {
+	BaseURL string
+}
+
+// NewClient returns a management client for the server behind endpoint,
+// which may be a full API URL such as the generate endpoint.
+func NewClient(endpoint string) *Client {
+	if endpoint == "" {
+		endpoint = NewOllamaModel("", "", 0).Endpoint
+	}
+	return &Client{BaseURL: BaseURL(endpoint)}
+}
+
+// BaseURL strips the API path from an Ollama endpoint.
+func BaseURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(endpoint, "/")
+	}
+	if i := strings.Index(u.Path, "/api/"); i >= 0 {
+		u.Path = u.Path[:i]
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	return u.String()
+}
+
+func (c *Client) do(method, path string, body interface{}, timeout time.Duration) (*http.Response, error) {
+	target := c.BaseURL + path
+	call := "ollama " + strings.TrimPrefix(path, "/api/")
+	if err := httpclient.CheckURL(call, target); err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpclient.NewProvider("ollama", call, timeout).Do(req)
+	if err != nil {
+		var blocked *httpclient.BlockedError
+		if errors.As(err, &blocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w at %s: %v", ErrNotRunning, c.BaseURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(b, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("ollama API error: %s", apiErr.Error)
+		}
+		return nil, fmt.Errorf("ollama API error (%s): %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+func (c *Client) getJSON(method, path string, body, out interface{}) error {
+	resp, err := c.do(method, path, body, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Version returns the server version; it doubles as a health check.
+func (c *Client) Version() (string, error) {
+	var v struct {
+		Version string `json:"version"`
+	}
+	err := c.getJSON("GET", "/api/version", nil, &v)
+	return v.Version, err
+}
+
+// List returns the models pulled on the server.
+func (c *Client) List() ([]LocalModel, error) {
+	var out struct {
+		Models []LocalModel `json:"models"`
+	}
+	err := c.getJSON("GET", "/api/tags", nil, &out)
+	return out.Models, err
+}
+
+// Running returns the models currently loaded in memory.
+func (c *Client) Running() ([]RunningModel, error) {
+	var out struct {
+		Models []RunningModel `json:"models"`
+	}
+	err := c.getJSON("GET", "/api/ps", nil, &out)
+	return out.Models, err
+}
+
+// Show returns the details of a model.
+func (c *Client) Show(name string) (*ModelInfo, error) {
+	var info ModelInfo
+	if err := c.getJSON("POST", "/api/show", map[string]string{"model": name}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Delete removes a model from the server.
+func (c *Client) Delete(name string) error {
+	return c.getJSON("DELETE", "/api/delete", map[string]string{"model": name}, nil)
+}
+
+// Pull downloads a model, reporting each progress line to progress.
+func (c *Client) Pull(name string, progress func(PullProgress)) error {
+	resp, err := c.do("POST", "/api/pull", map[string]interface{}{"model": name, "stream": true}, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var p PullProgress
+		if err := dec.Decode(&p); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if p.Error != "" {
+			return errors.New(p.Error)
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+}
+
+// WarmUp loads a model into memory without generating anything, and keeps
+// it loaded for keepAlive (an Ollama duration such as "10m"; empty for the
+// server default).
+func (c *Client) WarmUp(name, keepAlive string) error {
+	body := map[string]interface{}{"model": name, "stream": false}
+	if keepAlive != "" {
+		body["keep_alive"] = keepAlive
+	}
+	// Loading a large model from disk can take a while.
+	resp, err := c.do("POST", "/api/generate", body, 5*time.Minute)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Embed returns one embedding vector per input from /api/embed, along with
+// the number of input tokens the server reports.
+func (c *Client) Embed(model string, inputs []string) ([][]float32, modeliface.Usage, error) {
+	// Embedding a large batch on a CPU can take a while.
+	resp, err := c.do("POST", "/api/embed", map[string]interface{}{"model": model, "input": inputs}, 5*time.Minute)
+	if err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Embeddings      [][]float32 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	if len(out.Embeddings) != len(inputs) {
+		return nil, modeliface.Usage{}, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(out.Embeddings), len(inputs))
+	}
+	return out.Embeddings, modeliface.Usage{PromptTokens: out.PromptEvalCount}, nil
+}
+
+// Has reports whether name is pulled. A name without a tag matches
+// ":latest", as it does in Ollama itself.
+func (c *Client) Has(name string) (bool, error) {
+	models, err := c.List()
+	if err != nil {
+		return false, err
+	}
+	want := name
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+	for _, m := range models {
+		if m.Name == name || m.Name == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ProgressBar returns a Pull callback that draws a progress bar on stderr.
+func ProgressBar() func(PullProgress) {
+	last := ""
+	return func(p PullProgress) {
+		if p.Total <= 0 {
+			if last != "" {
+				fmt.Fprintln(os.Stderr)
+			}
+			fmt.Fprintln(os.Stderr, p.Status)
+			last = ""
+			return
+		}
+		const width = 30
+		done := int(float64(width) * float64(p.Completed) / float64(p.Total))
+		bar := strings.Repeat("=", done) + strings.Repeat(" ", width-done)
+		status := p.Status
+		if len(status) > 24 {
+			status = status[:24]
+		}
+		fmt.Fprintf(os.Stderr, "\r%-24s [%s] %3d%% %s/%s", status, bar,
+			p.Completed*100/p.Total, FormatSize(p.Completed), FormatSize(p.Total))
+		last = p.Status
+		if p.Completed >= p.Total {
+			fmt.Fprintln(os.Stderr)
+			last = ""
+		}
+	}
+}
+
+// FormatSize renders a byte count the way the ollama CLI does.
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.0f MB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.0f KB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -2,6 +2,7 @@ package ollama
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,33 +11,54 @@ import (
 	"os"
 	"time"
 
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
 	"github.com/codeforge-ide/codeforgeai.go/modeliface"
 )
 
 // Ollama API endpoint (default)
-const defaultOllamaEndpoint = "http://localhost:11434/api/generate"
+const defaultOllamaEndpoint = "http://localhost:11434/api/chat"
 
 // OllamaModel holds model name and endpoint.
 type OllamaModel struct {
 	Model    string
 	Endpoint string
 	Timeout  time.Duration
+	// KeepAlive tells the server how long to keep the model loaded after
+	// a request, e.g. "15m"; empty uses the server default.
+	KeepAlive string
+	// FIMTemplates adds to or replaces the built-in fill-in-the-middle
+	// templates used by Fill.
+	FIMTemplates map[string]string
+
+	lastUsage modeliface.Usage
+}
+
+// ChatMessage is one message of an /api/chat conversation.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
 // Request/Response structs for Ollama API
 type ollamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	// Add other fields as needed (e.g., stream, options)
+	Model     string                 `json:"model"`
+	Messages  []ChatMessage          `json:"messages"`
+	Format    interface{}            `json:"format,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
 }
 
 type ollamaResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
-	Error    string `json:"error,omitempty"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	Error           string      `json:"error,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+	EvalCount       int         `json:"eval_count,omitempty"`
 }
 
 // NewOllamaModel creates a new OllamaModel with optional endpoint and timeout.
+// The endpoint may name any API path of the server (older configs point
+// $OLLAMA_API_ENDPOINT at /api/generate); requests always go to /api/chat.
 func NewOllamaModel(model string, endpoint string, timeout time.Duration) *OllamaModel {
 	if endpoint == "" {
 		endpoint = os.Getenv("OLLAMA_API_ENDPOINT")
@@ -49,25 +71,70 @@ func NewOllamaModel(model string, endpoint string, timeout time.Duration) *Ollam
 	}
 	return &OllamaModel{
 		Model:    model,
-		Endpoint: endpoint,
+		Endpoint: BaseURL(endpoint) + "/api/chat",
 		Timeout:  timeout,
 	}
 }
 
-// SendRequest sends a prompt to the Ollama API and returns the response.
-// config can be nil or a map with additional options.
+// SendRequest sends a prompt to the Ollama chat API and returns the response.
+// config can be nil or a map with additional options: "system" (string),
+// "format" ("json" or a JSON schema) and "options" (the Ollama options
+// block, e.g. temperature, num_ctx, seed, stop).
 func (o *OllamaModel) SendRequest(prompt string, config interface{}) (string, error) {
+	return o.Chat([]modeliface.Message{{Role: "user", Content: prompt}}, config)
+}
+
+// SendRequestContext is SendRequest stopped when ctx is cancelled.
+func (o *OllamaModel) SendRequestContext(ctx context.Context, prompt string, config interface{}) (string, error) {
+	return o.chat(ctx, []modeliface.Message{{Role: "user", Content: prompt}}, config, nil)
+}
+
+// Chat sends a whole conversation to the Ollama chat API. The "system"
+// option is only used when the conversation does not start with its own
+// system message; other options are as for SendRequest.
+func (o *OllamaModel) Chat(conversation []modeliface.Message, config interface{}) (string, error) {
+	return o.ChatStream(conversation, config, nil)
+}
+
+// ChatStream is Chat that also passes each chunk of the reply to onToken
+// as it arrives. onToken may be nil.
+func (o *OllamaModel) ChatStream(conversation []modeliface.Message, config interface{}, onToken func(string)) (string, error) {
+	return o.chat(context.Background(), conversation, config, onToken)
+}
+
+func (o *OllamaModel) chat(ctx context.Context, conversation []modeliface.Message, config interface{}, onToken func(string)) (string, error) {
+	opts, _ := config.(map[string]interface{})
+	var messages []ChatMessage
+	if system, _ := opts["system"].(string); system != "" && (len(conversation) == 0 || conversation[0].Role != "system") {
+		messages = append(messages, ChatMessage{Role: "system", Content: system})
+	}
+	for _, m := range conversation {
+		messages = append(messages, ChatMessage{Role: m.Role, Content: m.Content})
+	}
 	reqBody := ollamaRequest{
-		Model:  o.Model,
-		Prompt: prompt,
+		Model:     o.Model,
+		Messages:  messages,
+		Format:    opts["format"],
+		KeepAlive: o.KeepAlive,
+	}
+	if genOpts, ok := opts["options"].(map[string]interface{}); ok && len(genOpts) > 0 {
+		reqBody.Options = genOpts
 	}
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", err
 	}
 
-	client := &http.Client{Timeout: o.Timeout}
-	resp, err := client.Post(o.Endpoint, "application/json", bytes.NewReader(body))
+	if err := httpclient.CheckURL("ollama chat", o.Endpoint); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := httpclient.NewProvider("ollama", "ollama chat", o.Timeout)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -92,12 +159,25 @@ func (o *OllamaModel) SendRequest(prompt string, config interface{}) (string, er
 		if r.Error != "" {
 			return "", errors.New(r.Error)
 		}
-		result += r.Response
+		result += r.Message.Content
+		if onToken != nil && r.Message.Content != "" {
+			onToken(r.Message.Content)
+		}
 		if r.Done {
+			o.lastUsage = modeliface.Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
 			break
 		}
 	}
 	return result, nil
 }
 
+// LastUsage returns the token counts of the most recent response.
+func (o *OllamaModel) LastUsage() modeliface.Usage {
+	return o.lastUsage
+}
+
 var _ modeliface.Model = (*OllamaModel)(nil)
+var _ modeliface.ChatModel = (*OllamaModel)(nil)
+var _ modeliface.ContextModel = (*OllamaModel)(nil)
+var _ modeliface.StreamingChatModel = (*OllamaModel)(nil)
+var _ modeliface.UsageReporter = (*OllamaModel)(nil)
@@ -0,0 +1,83 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// EmbeddingsClient calls an OpenAI-compatible embeddings endpoint, such as
+// OpenAI's, GitHub Models' or a local server's.
+type EmbeddingsClient struct {
+	// BaseURL is the API root; "/embeddings" is appended.
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Provider names the service for rate limits and retries.
+	Provider string
+	Timeout  time.Duration
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage modeliface.Usage `json:"usage"`
+}
+
+// Embed returns one embedding vector per input, along with the reported
+// token usage.
+func (c *EmbeddingsClient) Embed(inputs []string) ([][]float32, modeliface.Usage, error) {
+	target := strings.TrimSuffix(c.BaseURL, "/") + "/embeddings"
+	call := c.Provider + " embeddings"
+	if err := httpclient.CheckURL(call, target); err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	body, err := json.Marshal(map[string]interface{}{"model": c.Model, "input": inputs})
+	if err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+	resp, err := httpclient.NewProvider(c.Provider, call, timeout).Do(req)
+	if err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, modeliface.Usage{}, fmt.Errorf("embeddings API error (%s): %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	var out embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, modeliface.Usage{}, err
+	}
+	if len(out.Data) != len(inputs) {
+		return nil, modeliface.Usage{}, fmt.Errorf("embeddings API returned %d vectors for %d inputs", len(out.Data), len(inputs))
+	}
+	sort.Slice(out.Data, func(i, j int) bool { return out.Data[i].Index < out.Data[j].Index })
+	vectors := make([][]float32, len(out.Data))
+	for i, d := range out.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, out.Usage, nil
+}
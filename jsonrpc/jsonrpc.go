@@ -0,0 +1,186 @@
+// Package jsonrpc reads and writes JSON-RPC 2.0 messages framed with
+// Content-Length headers, as the Language Server Protocol does over stdio.
+// It serves both sides: the language server and clients driving one.
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error codes.
+const (
+	CodeParseError       = -32700
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeRequestCancelled = -32800
+)
+
+// Message is a request, notification or response.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// IsRequest reports whether m is a request, which needs a response.
+func (m *Message) IsRequest() bool { return m.Method != "" && len(m.ID) > 0 }
+
+// IsResponse reports whether m answers a request.
+func (m *Message) IsResponse() bool { return m.Method == "" && len(m.ID) > 0 }
+
+// Error is the error of a response.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Conn reads and writes messages, and matches the responses it reads to
+// the requests it sent. Reading is left to the caller's loop, which hands
+// responses to Deliver.
+type Conn struct {
+	r *bufio.Reader
+
+	writeMu sync.Mutex
+	w       io.Writer
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan *Message
+}
+
+// NewConn returns a connection reading from r and writing to w.
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: bufio.NewReader(r), w: w, pending: map[string]chan *Message{}}
+}
+
+// Read returns the next message. A message that is not valid JSON gives an
+// *Error, after which reading can go on.
+func (c *Conn) Read() (*Message, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	var m Message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, &Error{Code: CodeParseError, Message: err.Error()}
+	}
+	return &m, nil
+}
+
+func (c *Conn) write(m *Message) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// Reply answers the request with id with result, or with err when it is
+// not nil; errors other than *Error are sent as internal errors.
+func (c *Conn) Reply(id json.RawMessage, result interface{}, err error) error {
+	m := &Message{ID: id}
+	if err != nil {
+		var re *Error
+		if !errors.As(err, &re) {
+			re = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		m.Error = re
+		return c.write(m)
+	}
+	data, merr := json.Marshal(result)
+	if merr != nil {
+		return merr
+	}
+	m.Result = data
+	return c.write(m)
+}
+
+// Notify sends a notification.
+func (c *Conn) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&Message{Method: method, Params: data})
+}
+
+// Call sends a request and waits for its response, which the read loop
+// hands over through Deliver, decoding the result into result.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(strconv.Quote("cf-" + strconv.Itoa(c.nextID)))
+	ch := make(chan *Message, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(&Message{ID: id, Method: method, Params: data}); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	}
+}
+
+// Deliver passes a response to the Call waiting for it.
+func (c *Conn) Deliver(m *Message) {
+	c.mu.Lock()
+	ch := c.pending[string(m.ID)]
+	c.mu.Unlock()
+	if ch != nil {
+		ch <- m
+	}
+}
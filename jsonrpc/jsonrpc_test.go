@@ -0,0 +1,182 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pair returns two connections talking to each other, with a read loop on
+// b answering every request with handle.
+func pair(t *testing.T, handle func(m *Message) (interface{}, error)) (a, b *Conn) {
+	ar, bw := io.Pipe()
+	br, aw := io.Pipe()
+	a, b = NewConn(ar, aw), NewConn(br, bw)
+	t.Cleanup(func() {
+		aw.Close()
+		bw.Close()
+	})
+	go func() {
+		for {
+			m, err := a.Read()
+			if err != nil {
+				return
+			}
+			a.Deliver(m)
+		}
+	}()
+	go func() {
+		for {
+			m, err := b.Read()
+			if err != nil {
+				return
+			}
+			if m.IsRequest() {
+				result, err := handle(m)
+				b.Reply(m.ID, result, err)
+			}
+		}
+	}()
+	return a, b
+}
+
+func TestCall(t *testing.T) {
+	a, _ := pair(t, func(m *Message) (interface{}, error) {
+		switch m.Method {
+		case "add":
+			var p []int
+			if err := json.Unmarshal(m.Params, &p); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+			return p[0] + p[1], nil
+		case "fail":
+			return nil, errors.New("boom")
+		case "nothing":
+			return nil, nil
+		}
+		return nil, &Error{Code: CodeMethodNotFound, Message: "no " + m.Method}
+	})
+	ctx := context.Background()
+
+	var sum int
+	if err := a.Call(ctx, "add", []int{2, 3}, &sum); err != nil || sum != 5 {
+		t.Fatalf("add = %d, %v", sum, err)
+	}
+	if err := a.Call(ctx, "nothing", nil, nil); err != nil {
+		t.Fatalf("nothing: %v", err)
+	}
+	tests := []struct {
+		method string
+		params interface{}
+		code   int
+	}{
+		{"fail", nil, CodeInternalError},
+		{"missing", nil, CodeMethodNotFound},
+		{"add", "not a list", CodeInvalidParams},
+	}
+	for _, tt := range tests {
+		err := a.Call(ctx, tt.method, tt.params, nil)
+		var re *Error
+		if !errors.As(err, &re) || re.Code != tt.code {
+			t.Errorf("%s: error = %v, want code %d", tt.method, err, tt.code)
+		}
+	}
+}
+
+func TestCallConcurrent(t *testing.T) {
+	a, _ := pair(t, func(m *Message) (interface{}, error) {
+		var n int
+		json.Unmarshal(m.Params, &n)
+		return n * n, nil
+	})
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func(n int) {
+			var got int
+			err := a.Call(context.Background(), "square", n, &got)
+			if err == nil && got != n*n {
+				err = errors.New("answer went to the wrong call")
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < 20; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCallCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	a, _ := pair(t, func(m *Message) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Call(ctx, "slow", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Call = %v, want the context's error", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) != 0 {
+		t.Fatalf("%d calls left pending", len(a.pending))
+	}
+}
+
+func TestRead(t *testing.T) {
+	frame := func(body string) string {
+		return "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	}
+	input := frame(`{"jsonrpc":"2.0","method":"ping","params":{"n":1}}`) +
+		frame(`{not json}`) +
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\n" + frame(`{"jsonrpc":"2.0","id":7,"result":true}`)
+	c := NewConn(strings.NewReader(input), io.Discard)
+
+	m, err := c.Read()
+	if err != nil || m.Method != "ping" || string(m.Params) != `{"n":1}` || m.IsRequest() || m.IsResponse() {
+		t.Fatalf("first message = %+v, %v", m, err)
+	}
+	var re *Error
+	if _, err := c.Read(); !errors.As(err, &re) || re.Code != CodeParseError {
+		t.Fatalf("bad JSON gave %v, want a parse error", err)
+	}
+	if m, err := c.Read(); err != nil || !m.IsResponse() || string(m.ID) != "7" {
+		t.Fatalf("reading went wrong after a bad message: %+v, %v", m, err)
+	}
+	if _, err := c.Read(); err != io.EOF {
+		t.Fatalf("at the end Read = %v, want io.EOF", err)
+	}
+
+	for _, bad := range []string{"Content-Length: x\r\n\r\n{}", "Content-Length: 10\r\n\r\n{}"} {
+		if _, err := NewConn(strings.NewReader(bad), io.Discard).Read(); err == nil || err == io.EOF {
+			t.Errorf("Read(%q) = %v, want an error", bad, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewConn(strings.NewReader(""), &buf)
+	if err := c.Notify("note", map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reply(json.RawMessage(`3`), nil, &Error{Code: CodeRequestCancelled, Message: "cancelled"}); err != nil {
+		t.Fatal(err)
+	}
+	note := `{"jsonrpc":"2.0","method":"note","params":{"a":1}}`
+	reply := `{"jsonrpc":"2.0","id":3,"error":{"code":-32800,"message":"cancelled"}}`
+	want := "Content-Length: " + strconv.Itoa(len(note)) + "\r\n\r\n" + note +
+		"Content-Length: " + strconv.Itoa(len(reply)) + "\r\n\r\n" + reply
+	if buf.String() != want {
+		t.Fatalf("wrote %q, want %q", buf.String(), want)
+	}
+}
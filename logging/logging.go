@@ -0,0 +1,21 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Setup configures the default slog logger from the global verbosity flags.
+// Without flags only warnings and errors are printed; -v enables INFO, and
+// -V or --debug enable DEBUG. --debug additionally records source locations.
+func Setup(verbose, veryVerbose, debug bool) {
+	level := slog.LevelWarn
+	switch {
+	case debug, veryVerbose:
+		level = slog.LevelDebug
+	case verbose:
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, AddSource: debug}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+}
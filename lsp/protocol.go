@@ -0,0 +1,280 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The subset of the Language Server Protocol the server speaks.
+
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version *int   `json:"version"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type InitializeParams struct {
+	ProcessID        *int   `json:"processId"`
+	RootURI          string `json:"rootUri,omitempty"`
+	RootPath         string `json:"rootPath,omitempty"`
+	WorkspaceFolders []struct {
+		URI string `json:"uri"`
+	} `json:"workspaceFolders,omitempty"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
+
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type ServerCapabilities struct {
+	// TextDocumentSync 2 is incremental: changes send the edited ranges.
+	TextDocumentSync         int                    `json:"textDocumentSync"`
+	CompletionProvider       map[string]interface{} `json:"completionProvider"`
+	InlineCompletionProvider bool                   `json:"inlineCompletionProvider"`
+	HoverProvider            bool                   `json:"hoverProvider"`
+	CodeActionProvider       map[string]interface{} `json:"codeActionProvider"`
+	ExecuteCommandProvider   ExecuteCommandOptions  `json:"executeCommandProvider"`
+}
+
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Range *Range `json:"range,omitempty"`
+		Text  string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type CompletionItem struct {
+	Label      string    `json:"label"`
+	Kind       int       `json:"kind,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	InsertText string    `json:"insertText,omitempty"`
+	TextEdit   *TextEdit `json:"textEdit,omitempty"`
+}
+
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+type InlineCompletionItem struct {
+	InsertText string `json:"insertText"`
+	Range      *Range `json:"range,omitempty"`
+}
+
+type InlineCompletionList struct {
+	Items []InlineCompletionItem `json:"items"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+type Command struct {
+	Title     string        `json:"title"`
+	Command   string        `json:"command"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+}
+
+type CodeAction struct {
+	Title   string   `json:"title"`
+	Kind    string   `json:"kind,omitempty"`
+	Command *Command `json:"command,omitempty"`
+}
+
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type TextDocumentEdit struct {
+	TextDocument VersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                      `json:"edits"`
+}
+
+type CreateFile struct {
+	Kind    string `json:"kind"`
+	URI     string `json:"uri"`
+	Options struct {
+		IgnoreIfExists bool `json:"ignoreIfExists"`
+	} `json:"options"`
+}
+
+// WorkspaceEdit holds TextDocumentEdit and CreateFile operations.
+type WorkspaceEdit struct {
+	DocumentChanges []interface{} `json:"documentChanges"`
+}
+
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label,omitempty"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+type ApplyWorkspaceEditResult struct {
+	Applied       bool   `json:"applied"`
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+type ShowMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// Message types of window/showMessage.
+const (
+	messageError = 1
+	messageInfo  = 3
+)
+
+type CancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+// uriToPath converts a file:// URI to a path.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI converts a path to a file:// URI.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// runeColumn converts a UTF-16 character offset in line, as LSP positions
+// count them, to a character (rune) offset.
+func runeColumn(line string, utf16Col int) int {
+	units, runes := 0, 0
+	for _, r := range line {
+		if units >= utf16Col {
+			break
+		}
+		units += utf16.RuneLen(r)
+		runes++
+	}
+	return runes
+}
+
+// endPosition returns the position after the last character of text.
+func endPosition(text string) Position {
+	line := strings.Count(text, "\n")
+	last := text[strings.LastIndex(text, "\n")+1:]
+	return Position{Line: line, Character: len(utf16.Encode([]rune(last)))}
+}
+
+// offset returns the byte offset of pos in text, clamped to the text.
+func offset(text string, pos Position) int {
+	start := 0
+	for i := 0; i < pos.Line; i++ {
+		j := strings.IndexByte(text[start:], '\n')
+		if j < 0 {
+			return len(text)
+		}
+		start += j + 1
+	}
+	line := text[start:]
+	if j := strings.IndexByte(line, '\n'); j >= 0 {
+		line = line[:j]
+	}
+	units := 0
+	for i, r := range line {
+		if units >= pos.Character {
+			return start + i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return start + len(line)
+}
+
+// wordAt returns the identifier around byte offset off of text, with any
+// qualifiers before it such as "pkg." or "Type.".
+func wordAt(text string, off int) string {
+	isWord := func(r rune) bool {
+		return r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	}
+	start, end := off, off
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !isWord(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !isWord(r) || r == '.' {
+			break
+		}
+		end += size
+	}
+	return strings.Trim(text[start:end], ".")
+}
@@ -0,0 +1,101 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRuneColumn(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		want int
+	}{
+		{"hello", 0, 0},
+		{"hello", 3, 3},
+		{"hello", 9, 5},
+		{"héllo", 2, 2},
+		{"a😀b", 1, 1},
+		{"a😀b", 3, 2}, // the emoji is two UTF-16 units
+		{"a😀b", 4, 3},
+		{"a😀b", 2, 2}, // inside the surrogate pair
+		{"", 1, 0},
+	}
+	for _, tt := range tests {
+		if got := runeColumn(tt.line, tt.col); got != tt.want {
+			t.Errorf("runeColumn(%q, %d) = %d, want %d", tt.line, tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	text := "one\nh😀llo\n\nlast"
+	tests := []struct {
+		name string
+		pos  Position
+		want int
+	}{
+		{"start", Position{0, 0}, 0},
+		{"first line", Position{0, 2}, 2},
+		{"past the end of a line", Position{0, 10}, 3},
+		{"second line", Position{1, 1}, 5},
+		{"after a surrogate pair", Position{1, 3}, 9},
+		{"empty line", Position{2, 0}, 13},
+		{"empty line past its end", Position{2, 4}, 13},
+		{"last line", Position{3, 4}, 18},
+		{"past the last line", Position{7, 0}, 18},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := offset(text, tt.pos); got != tt.want {
+				t.Errorf("offset(%+v) = %d, want %d", tt.pos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndPosition(t *testing.T) {
+	tests := map[string]Position{
+		"":           {0, 0},
+		"abc":        {0, 3},
+		"a\nb😀":      {1, 3},
+		"a\n":        {1, 0},
+		"x\r\nyz\n1": {2, 1},
+	}
+	for text, want := range tests {
+		if got := endPosition(text); got != want {
+			t.Errorf("endPosition(%q) = %+v, want %+v", text, got, want)
+		}
+	}
+}
+
+func TestWordAt(t *testing.T) {
+	text := "x := pkg.Type.Method(arg_1) + é"
+	tests := []struct {
+		off  int
+		want string
+	}{
+		{0, "x"},
+		{5, "pkg"},
+		{9, "pkg.Type"},
+		{15, "pkg.Type.Method"},
+		{21, "arg_1"},
+		{27, ""},
+		{len(text), ""},
+	}
+	for _, tt := range tests {
+		if got := wordAt(text, tt.off); got != tt.want {
+			t.Errorf("wordAt(%d) = %q, want %q", tt.off, got, tt.want)
+		}
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir with space", "main.go")
+	if got := uriToPath(pathToURI(path)); got != path {
+		t.Errorf("uriToPath(pathToURI(%q)) = %q", path, got)
+	}
+	if got := uriToPath("untitled:Untitled-1"); got != "untitled:Untitled-1" {
+		t.Errorf("uriToPath kept %q as %q", "untitled:Untitled-1", got)
+	}
+}
@@ -0,0 +1,520 @@
+// Package lsp is a Language Server Protocol server over stdio that gives
+// editors the engine's features: inline completion, hover explanations,
+// code actions to improve, document or test code, and a commit message
+// command.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/engine"
+	"github.com/codeforge-ide/codeforgeai.go/jsonrpc"
+	"github.com/codeforge-ide/codeforgeai.go/parser"
+)
+
+// Commands run through workspace/executeCommand.
+const (
+	CommandImprove       = "codeforgeai.improve"
+	CommandDocument      = "codeforgeai.document"
+	CommandGenerateTests = "codeforgeai.generateTests"
+	CommandCommitMessage = "codeforgeai.commitMessage"
+)
+
+// document is an open text document.
+type document struct {
+	version int
+	text    string
+}
+
+// Server is a language server backed by the engine.
+type Server struct {
+	eng  *engine.Engine
+	conn *jsonrpc.Conn
+
+	mu       sync.Mutex
+	docs     map[string]*document
+	cancels  map[string]context.CancelFunc
+	shutdown bool
+}
+
+// NewServer returns a server using eng.
+func NewServer(eng *engine.Engine) *Server {
+	return &Server{eng: eng, docs: map[string]*document{}, cancels: map[string]context.CancelFunc{}}
+}
+
+// Serve reads messages from r and writes to w until the client sends exit
+// or closes r. Requests run concurrently and can be cancelled; document
+// notifications are applied in order. It returns an error when the client
+// exits without asking to shut down first.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = jsonrpc.NewConn(r, w)
+	for {
+		m, err := s.conn.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var re *jsonrpc.Error
+			if errors.As(err, &re) {
+				slog.Warn("lsp: skipping malformed message", "error", err)
+				continue
+			}
+			return err
+		}
+		switch {
+		case m.IsResponse():
+			s.conn.Deliver(m)
+		case m.IsRequest():
+			ctx, cancel := context.WithCancel(context.Background())
+			s.mu.Lock()
+			s.cancels[string(m.ID)] = cancel
+			s.mu.Unlock()
+			go s.handle(ctx, m)
+		case m.Method == "exit":
+			s.mu.Lock()
+			clean := s.shutdown
+			s.mu.Unlock()
+			if !clean {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		default:
+			s.notification(m)
+		}
+	}
+}
+
+// handle answers a request.
+func (s *Server) handle(ctx context.Context, m *jsonrpc.Message) {
+	defer func() {
+		s.mu.Lock()
+		if cancel := s.cancels[string(m.ID)]; cancel != nil {
+			cancel()
+			delete(s.cancels, string(m.ID))
+		}
+		s.mu.Unlock()
+	}()
+
+	var result interface{}
+	var err error
+	switch m.Method {
+	case "initialize":
+		result, err = s.initialize(m.Params)
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+	case "textDocument/completion":
+		result, err = s.completion(ctx, m.Params)
+	case "textDocument/inlineCompletion":
+		result, err = s.inlineCompletion(ctx, m.Params)
+	case "textDocument/hover":
+		result, err = s.hover(ctx, m.Params)
+	case "textDocument/codeAction":
+		result, err = s.codeAction(m.Params)
+	case "workspace/executeCommand":
+		result, err = s.executeCommand(ctx, m.Params)
+	default:
+		err = &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: "method not supported: " + m.Method}
+	}
+	if ctx.Err() != nil {
+		err = &jsonrpc.Error{Code: jsonrpc.CodeRequestCancelled, Message: "request cancelled"}
+	}
+	if err != nil {
+		slog.Debug("lsp request failed", "method", m.Method, "error", err)
+	}
+	if werr := s.conn.Reply(m.ID, result, err); werr != nil {
+		slog.Warn("lsp: could not reply", "method", m.Method, "error", werr)
+	}
+}
+
+// notification applies a notification from the client.
+func (s *Server) notification(m *jsonrpc.Message) {
+	switch m.Method {
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if json.Unmarshal(m.Params, &p) == nil {
+			s.mu.Lock()
+			s.docs[p.TextDocument.URI] = &document{version: p.TextDocument.Version, text: p.TextDocument.Text}
+			s.mu.Unlock()
+		}
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if json.Unmarshal(m.Params, &p) != nil {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		doc := s.docs[p.TextDocument.URI]
+		if doc == nil {
+			return
+		}
+		for _, c := range p.ContentChanges {
+			if c.Range == nil {
+				doc.text = c.Text
+				continue
+			}
+			start, end := offset(doc.text, c.Range.Start), offset(doc.text, c.Range.End)
+			doc.text = doc.text[:start] + c.Text + doc.text[max(start, end):]
+		}
+		if p.TextDocument.Version != nil {
+			doc.version = *p.TextDocument.Version
+		}
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if json.Unmarshal(m.Params, &p) == nil {
+			s.mu.Lock()
+			delete(s.docs, p.TextDocument.URI)
+			s.mu.Unlock()
+		}
+	case "$/cancelRequest":
+		var p CancelParams
+		if json.Unmarshal(m.Params, &p) == nil {
+			s.mu.Lock()
+			if cancel := s.cancels[string(p.ID)]; cancel != nil {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p InitializeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+	}
+	// The project config, index and repo map are found from the working
+	// directory, so move to the workspace.
+	root := p.RootPath
+	if p.RootURI != "" {
+		root = uriToPath(p.RootURI)
+	} else if len(p.WorkspaceFolders) > 0 {
+		root = uriToPath(p.WorkspaceFolders[0].URI)
+	}
+	if root != "" {
+		if err := os.Chdir(root); err != nil {
+			slog.Warn("lsp: could not change to the workspace", "root", root, "error", err)
+		}
+	}
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:         2,
+			CompletionProvider:       map[string]interface{}{},
+			InlineCompletionProvider: true,
+			HoverProvider:            true,
+			CodeActionProvider:       map[string]interface{}{"codeActionKinds": []string{"refactor.rewrite", "source"}},
+			ExecuteCommandProvider: ExecuteCommandOptions{
+				Commands: []string{CommandImprove, CommandDocument, CommandGenerateTests, CommandCommitMessage},
+			},
+		},
+		ServerInfo: ServerInfo{Name: "codeforgeai"},
+	}, nil
+}
+
+// text returns the contents and version of the document at uri, from the
+// editor when it is open and from disk otherwise (with a nil version).
+func (s *Server) text(uri string) (string, *int, error) {
+	s.mu.Lock()
+	doc := s.docs[uri]
+	s.mu.Unlock()
+	if doc != nil {
+		version := doc.version
+		return doc.text, &version, nil
+	}
+	data, err := os.ReadFile(uriToPath(uri))
+	if err != nil {
+		return "", nil, err
+	}
+	return string(data), nil, nil
+}
+
+// complete returns the completion at a position.
+func (s *Server) complete(ctx context.Context, params json.RawMessage) (string, Position, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return "", Position{}, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+	}
+	text, _, err := s.text(p.TextDocument.URI)
+	if err != nil {
+		return "", p.Position, err
+	}
+	lines := strings.Split(text, "\n")
+	if p.Position.Line >= len(lines) {
+		return "", p.Position, nil
+	}
+	col := runeColumn(lines[p.Position.Line], p.Position.Character)
+	completion, err := s.eng.Complete(ctx, uriToPath(p.TextDocument.URI), text, p.Position.Line+1, col+1)
+	return completion, p.Position, err
+}
+
+func (s *Server) completion(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	completion, pos, err := s.complete(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	list := CompletionList{IsIncomplete: true, Items: []CompletionItem{}}
+	if strings.TrimSpace(completion) == "" {
+		return list, nil
+	}
+	label, _, _ := strings.Cut(strings.TrimSpace(completion), "\n")
+	list.Items = append(list.Items, CompletionItem{
+		Label:  label,
+		Kind:   1, // text
+		Detail: "codeforgeai",
+		// An empty range at the cursor inserts the text as is, whatever
+		// the editor takes the current word to be.
+		TextEdit: &TextEdit{Range: Range{Start: pos, End: pos}, NewText: completion},
+	})
+	return list, nil
+}
+
+func (s *Server) inlineCompletion(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	completion, pos, err := s.complete(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	list := InlineCompletionList{Items: []InlineCompletionItem{}}
+	if strings.TrimSpace(completion) != "" {
+		list.Items = append(list.Items, InlineCompletionItem{InsertText: completion, Range: &Range{Start: pos, End: pos}})
+	}
+	return list, nil
+}
+
+// hover explains the symbol under the cursor in a few sentences. The name
+// is looked up in the hovered document first, then in the whole project.
+func (s *Server) hover(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+	}
+	text, _, err := s.text(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	word := wordAt(text, offset(text, p.Position))
+	if len(word) < 2 {
+		return nil, nil
+	}
+	// Look the name up as written, then without a qualifier that may be
+	// a variable rather than a package or type.
+	names := []string{word}
+	if i := strings.LastIndex(word, "."); i >= 0 {
+		names = append(names, word[i+1:])
+	}
+	// Only a name declared in the document is explained from it, so a
+	// failed request is not paid for twice.
+	file := uriToPath(p.TextDocument.URI)
+	for _, name := range names {
+		if _, err := parser.Resolve(config.ProjectRoot(), name, file); err == nil {
+			return s.explainHover(ctx, file, name)
+		}
+	}
+	for _, name := range names {
+		if h, err := s.explainHover(ctx, "", name); h != nil || err != nil {
+			return h, err
+		}
+	}
+	return nil, nil
+}
+
+// explainHover explains name, or returns nil when that fails or ctx has
+// been cancelled, in which case no request is made. Only the error of a
+// model that cannot be used is returned, since it fails every name alike.
+func (s *Server) explainHover(ctx context.Context, file, name string) (interface{}, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+	explanation, err := s.eng.ExplainCode(ctx, file, engine.ExplainOptions{Symbol: name, Depth: engine.DepthSummary, Format: engine.FormatMarkdown})
+	if errors.Is(err, engine.ErrModel) {
+		return nil, err
+	}
+	if err != nil {
+		slog.Debug("lsp: no hover", "name", name, "error", err)
+		return nil, nil
+	}
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: explanation}}, nil
+}
+
+// codeActionRange widens r to whole lines, or when it is empty to the
+// innermost declaration around it (or its line).
+func codeActionRange(path, text string, r Range) Range {
+	lines := strings.Split(text, "\n")
+	start, end := r.Start.Line, r.End.Line
+	if r.End.Character == 0 && end > start {
+		end--
+	}
+	if r.Start == r.End {
+		if ex := parser.ExtractorFor(path); ex != nil {
+			if _, decls, err := ex.Extract([]byte(text)); err == nil {
+				best := -1
+				for i, d := range decls {
+					if d.StartLine-1 <= start && start <= d.EndLine-1 && (best < 0 || d.StartLine >= decls[best].StartLine) {
+						best = i
+					}
+				}
+				if best >= 0 {
+					start, end = decls[best].StartLine-1, decls[best].EndLine-1
+				}
+			}
+		}
+	}
+	end = min(end, len(lines)-1)
+	if end+1 < len(lines) {
+		return Range{Start: Position{Line: start}, End: Position{Line: end + 1}}
+	}
+	return Range{Start: Position{Line: start}, End: endPosition(text)}
+}
+
+func (s *Server) codeAction(params json.RawMessage) (interface{}, error) {
+	var p CodeActionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+	}
+	text, _, err := s.text(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	r := codeActionRange(uriToPath(p.TextDocument.URI), text, p.Range)
+	args := []interface{}{p.TextDocument.URI, r}
+	return []CodeAction{
+		{Title: "Improve with AI", Kind: "refactor.rewrite", Command: &Command{Title: "Improve with AI", Command: CommandImprove, Arguments: args}},
+		{Title: "Add docs", Kind: "refactor.rewrite", Command: &Command{Title: "Add docs", Command: CommandDocument, Arguments: args}},
+		{Title: "Generate test", Kind: "source", Command: &Command{Title: "Generate test", Command: CommandGenerateTests, Arguments: args}},
+	}, nil
+}
+
+func (s *Server) executeCommand(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p ExecuteCommandParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+	}
+	switch p.Command {
+	case CommandCommitMessage:
+		return s.commitMessage(ctx)
+	case CommandImprove, CommandDocument, CommandGenerateTests:
+	default:
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: "unknown command " + p.Command}
+	}
+
+	var uri string
+	var r Range
+	if len(p.Arguments) != 2 || json.Unmarshal(p.Arguments[0], &uri) != nil || json.Unmarshal(p.Arguments[1], &r) != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: p.Command + " takes a document URI and a range"}
+	}
+	text, version, err := s.text(uri)
+	if err != nil {
+		return nil, err
+	}
+	path := uriToPath(uri)
+	code := text[offset(text, r.Start):offset(text, r.End)]
+
+	var edit WorkspaceEdit
+	var label string
+	if p.Command == CommandGenerateTests {
+		label = "Generate test"
+		if edit, err = s.testsEdit(path, code); err != nil {
+			return nil, s.showError(ctx, err)
+		}
+	} else {
+		rewrite, title := engine.RewriteImprove, "Improve with AI"
+		if p.Command == CommandDocument {
+			rewrite, title = engine.RewriteDocument, "Add docs"
+		}
+		label = title
+		newCode, err := s.eng.RewriteCode(rewrite, path, strings.TrimSuffix(code, "\n"))
+		if err != nil {
+			return nil, s.showError(ctx, err)
+		}
+		if strings.HasSuffix(code, "\n") {
+			newCode += "\n"
+		}
+		edit.DocumentChanges = []interface{}{TextDocumentEdit{
+			TextDocument: VersionedTextDocumentIdentifier{URI: uri, Version: version},
+			Edits:        []TextEdit{{Range: r, NewText: newCode}},
+		}}
+	}
+
+	var applied ApplyWorkspaceEditResult
+	if err := s.conn.Call(ctx, "workspace/applyEdit", ApplyWorkspaceEditParams{Label: label, Edit: edit}, &applied); err != nil {
+		return nil, err
+	}
+	if !applied.Applied {
+		return nil, s.showError(ctx, fmt.Errorf("the editor did not apply the edit: %s", applied.FailureReason))
+	}
+	return nil, nil
+}
+
+// testsEdit generates tests for code from path and returns the edit that
+// adds them to its test file, creating the file if needed.
+func (s *Server) testsEdit(path, code string) (WorkspaceEdit, error) {
+	testPath := engine.TestFileFor(path)
+	testURI := pathToURI(testPath)
+	existing, version, err := s.text(testURI)
+	if err != nil && !os.IsNotExist(err) {
+		return WorkspaceEdit{}, err
+	}
+	tests, err := s.eng.GenerateTests(path, code, testPath, existing)
+	if err != nil {
+		return WorkspaceEdit{}, err
+	}
+
+	var edit WorkspaceEdit
+	at := Position{}
+	newText := tests + "\n"
+	if existing == "" && version == nil {
+		create := CreateFile{Kind: "create", URI: testURI}
+		create.Options.IgnoreIfExists = true
+		edit.DocumentChanges = append(edit.DocumentChanges, create)
+	} else {
+		at = endPosition(existing)
+		newText = "\n" + newText
+		if !strings.HasSuffix(existing, "\n") {
+			newText = "\n" + newText
+		}
+	}
+	edit.DocumentChanges = append(edit.DocumentChanges, TextDocumentEdit{
+		TextDocument: VersionedTextDocumentIdentifier{URI: testURI, Version: version},
+		Edits:        []TextEdit{{Range: Range{Start: at, End: at}, NewText: newText}},
+	})
+	return edit, nil
+}
+
+// commitMessage returns a commit message for the workspace's staged (or
+// else unstaged) changes, and shows it.
+func (s *Server) commitMessage(ctx context.Context) (interface{}, error) {
+	diff, err := s.eng.GetGitDiff()
+	if err != nil {
+		return nil, s.showError(ctx, err)
+	}
+	if strings.TrimSpace(diff) == "" {
+		return nil, s.showError(ctx, errors.New("there are no changes to describe"))
+	}
+	msg, err := s.eng.ProcessCommitMessage(diff)
+	if err != nil {
+		return nil, s.showError(ctx, err)
+	}
+	if err := s.conn.Notify("window/showMessage", ShowMessageParams{Type: messageInfo, Message: msg}); err != nil {
+		slog.Warn("lsp: could not show message", "error", err)
+	}
+	return msg, nil
+}
+
+// showError shows err in the editor and returns it.
+func (s *Server) showError(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		if nerr := s.conn.Notify("window/showMessage", ShowMessageParams{Type: messageError, Message: "codeforgeai: " + err.Error()}); nerr != nil {
+			slog.Warn("lsp: could not show message", "error", nerr)
+		}
+	}
+	return err
+}
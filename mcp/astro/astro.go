@@ -14,7 +14,7 @@ type AstroMCP struct {
 
 func NewAstroMCP() *AstroMCP {
 	return &AstroMCP{
-		client: mcp.NewMCPClient(AstrolescentMCPURL),
+		client: mcp.NewMCPClient("astrolescent", AstrolescentMCPURL),
 	}
 }
 
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/secrets"
 )
 
 type MCPClient struct {
+	server     string
 	serverURL  string
 	httpClient *http.Client
 }
@@ -32,14 +36,45 @@ type MCPInterface interface {
 	GetAvailableTools() []string
 }
 
-func NewMCPClient(serverURL string) MCPInterface {
+// NewMCPClient returns a client of the MCP server called server. Its
+// requests carry the server's credential, the mcp.<server> secret, when the
+// secrets agent holds one.
+func NewMCPClient(server, serverURL string) MCPInterface {
 	return &MCPClient{
+		server:     server,
 		serverURL:  serverURL,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: newHTTPClient(serverURL, secrets.MCPCredential(server)),
+	}
+}
+
+// newHTTPClient returns the HTTP client for serverURL, sending token as a
+// bearer token when set.
+func newHTTPClient(serverURL, token string) *http.Client {
+	client := httpclient.New("mcp server "+serverURL, 30*time.Second)
+	if token != "" {
+		client.Transport = &bearerTransport{base: client.Transport, token: token}
+	}
+	return client
+}
+
+// bearerTransport adds an Authorization header to requests without one.
+type bearerTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
 	}
+	return t.base.RoundTrip(req)
 }
 
 func (c *MCPClient) CallTool(ctx context.Context, toolName string, args map[string]interface{}) (*MCPResponse, error) {
+	if err := httpclient.CheckURL("mcp tool call "+toolName, c.serverURL); err != nil {
+		return nil, err
+	}
 	// For demo purposes with simulated responses that match Astrolescent API
 	// In production, this would implement the actual MCP SSE protocol
 	return c.simulateToolCall(toolName, args)
@@ -0,0 +1,37 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCredential(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		token, header, want string
+	}{
+		{"", "", ""},
+		{"s3cret", "", "Bearer s3cret"},
+		{"s3cret", "Basic abc", "Basic abc"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		resp, err := newHTTPClient(srv.URL, tt.token).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got != tt.want {
+			t.Errorf("token %q, header %q: server got Authorization %q, want %q", tt.token, tt.header, got, tt.want)
+		}
+	}
+}
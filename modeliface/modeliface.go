@@ -1,5 +1,81 @@
 package modeliface
 
+import (
+	"context"
+	"strings"
+)
+
 type Model interface {
 	SendRequest(prompt string, config interface{}) (string, error)
 }
+
+// ContextModel is implemented by models whose single-prompt requests can
+// be abandoned: cancelling ctx stops the request.
+type ContextModel interface {
+	SendRequestContext(ctx context.Context, prompt string, config interface{}) (string, error)
+}
+
+// Usage holds the token counts reported for a single model response.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Total returns prompt plus completion tokens.
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageReporter is implemented by models that can report the token usage
+// of their most recent response.
+type UsageReporter interface {
+	LastUsage() Usage
+}
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    string `json:"role" yaml:"role"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// ChatModel is implemented by models that accept a whole conversation
+// rather than a single prompt.
+type ChatModel interface {
+	Chat(messages []Message, config interface{}) (string, error)
+}
+
+// StreamingChatModel is implemented by chat models that can deliver a
+// reply as it is generated. onToken receives each chunk in order; the full
+// reply is also returned.
+type StreamingChatModel interface {
+	ChatStream(messages []Message, config interface{}, onToken func(string)) (string, error)
+}
+
+// FillModel is implemented by models that can fill in the middle: write
+// the code that goes between prefix and suffix, and nothing else.
+// Cancelling ctx abandons the request.
+type FillModel interface {
+	Fill(ctx context.Context, prefix, suffix string, config interface{}, onToken func(string)) (string, error)
+}
+
+// RenderFill turns a fill-in-the-middle request into a prompt for models
+// that only answer instructions.
+func RenderFill(prefix, suffix string) string {
+	return "Complete the code at <CURSOR>. Reply with only the code to insert at <CURSOR>, " +
+		"without explanations, Markdown or the code around it.\n\n" + prefix + "<CURSOR>" + suffix
+}
+
+// RenderMessages flattens a conversation into a single prompt for models
+// that only take one.
+func RenderMessages(messages []Message) string {
+	var sb strings.Builder
+	for i, m := range messages {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(m.Role)
+		sb.WriteString(": ")
+		sb.WriteString(m.Content)
+	}
+	return sb.String()
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// Providers returns the default provider followed by the fallback ones,
+// in the order they are tried.
+func Providers(cfg *config.Config) []string {
+	providers := []string{cfg.Integrations.Default}
+	for _, p := range cfg.Integrations.Fallback {
+		dup := p == ""
+		for _, q := range providers {
+			dup = dup || p == q
+		}
+		if !dup {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// fallbackModel sends each request to the first provider that accepts it.
+// Providers are set up when first needed, so a healthy default costs
+// nothing extra.
+type fallbackModel struct {
+	cfg       *config.Config
+	modelType string
+	providers []string
+
+	mu     sync.Mutex // guards models, errs and last
+	models []Model
+	errs   []error
+	last   Model
+}
+
+func newFallbackModel(cfg *config.Config, modelType string, providers []string) *fallbackModel {
+	return &fallbackModel{
+		cfg:       cfg,
+		modelType: modelType,
+		providers: providers,
+		models:    make([]Model, len(providers)),
+		errs:      make([]error, len(providers)),
+	}
+}
+
+// resolve returns the model of the i-th provider, creating and checking
+// it on first use. Errors are kept, and logged once when there are
+// providers to fall back to.
+func (f *fallbackModel) resolve(i int) (Model, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.models[i] == nil && f.errs[i] == nil {
+		provider := f.providers[i]
+		name := ModelName(f.cfg, provider, f.modelType)
+		model, err := NewModel(f.cfg, provider, name)
+		if err == nil {
+			err = Preflight(f.cfg, provider, name)
+		}
+		if err != nil {
+			if len(f.providers) > 1 {
+				slog.Warn("model provider unavailable", "provider", provider, "error", err)
+			}
+			f.errs[i] = err
+		} else {
+			f.models[i] = model
+		}
+	}
+	return f.models[i], f.errs[i]
+}
+
+// try calls send with each provider's model until one succeeds, and
+// returns the default provider's error when none does. Once streamed
+// gets true part of the reply has gone out, so a failure is final.
+func (f *fallbackModel) try(send func(Model) (string, error), streamed func() bool) (string, error) {
+	var firstErr error
+	for i, provider := range f.providers {
+		model, err := f.resolve(i)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		resp, err := send(model)
+		if err == nil {
+			f.mu.Lock()
+			f.last = model
+			f.mu.Unlock()
+			return resp, nil
+		}
+		if streamed != nil && streamed() {
+			return "", err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if i+1 < len(f.providers) {
+			slog.Warn("model provider failed, falling back", "provider", provider, "next", f.providers[i+1], "error", err)
+		}
+	}
+	return "", firstErr
+}
+
+func (f *fallbackModel) SendRequest(prompt string, cfg interface{}) (string, error) {
+	return f.try(func(m Model) (string, error) { return m.SendRequest(prompt, cfg) }, nil)
+}
+
+// SendRequestContext is SendRequest that stops falling back once ctx has
+// been cancelled.
+func (f *fallbackModel) SendRequestContext(ctx context.Context, prompt string, cfg interface{}) (string, error) {
+	return f.try(func(m Model) (string, error) {
+		return m.(modeliface.ContextModel).SendRequestContext(ctx, prompt, cfg)
+	}, func() bool { return ctx.Err() != nil })
+}
+
+func (f *fallbackModel) Chat(messages []modeliface.Message, cfg interface{}) (string, error) {
+	return f.ChatStream(messages, cfg, nil)
+}
+
+func (f *fallbackModel) ChatStream(messages []modeliface.Message, cfg interface{}, onToken func(string)) (string, error) {
+	streamed := false
+	var stream func(string)
+	if onToken != nil {
+		stream = func(s string) {
+			streamed = true
+			onToken(s)
+		}
+	}
+	return f.try(func(m Model) (string, error) {
+		return m.(modeliface.StreamingChatModel).ChatStream(messages, cfg, stream)
+	}, func() bool { return streamed })
+}
+
+// Fill completes code with the first provider that answers, unless ctx
+// has been cancelled: then nobody wants the completion any more.
+func (f *fallbackModel) Fill(ctx context.Context, prefix, suffix string, cfg interface{}, onToken func(string)) (string, error) {
+	streamed := false
+	var stream func(string)
+	if onToken != nil {
+		stream = func(s string) {
+			streamed = true
+			onToken(s)
+		}
+	}
+	return f.try(func(m Model) (string, error) {
+		return m.(modeliface.FillModel).Fill(ctx, prefix, suffix, cfg, stream)
+	}, func() bool { return streamed || ctx.Err() != nil })
+}
+
+// run makes c with the first provider that answers, leaving the usage of
+// that provider's call in c.Usage.
+func (f *fallbackModel) run(c *Call) (string, error) {
+	streamed := false
+	var stream func(string)
+	if c.Stream != nil {
+		stream = func(s string) {
+			streamed = true
+			c.Stream(s)
+		}
+	}
+	return f.try(func(m Model) (string, error) {
+		attempt := *c
+		attempt.Stream = stream
+		resp, err := Run(m, &attempt)
+		c.Usage = attempt.Usage
+		return resp, err
+	}, func() bool { return streamed || c.Ctx != nil && c.Ctx.Err() != nil })
+}
+
+// LastUsage returns the usage of the provider that answered last.
+func (f *fallbackModel) LastUsage() modeliface.Usage {
+	f.mu.Lock()
+	last := f.last
+	f.mu.Unlock()
+	if ur, ok := last.(modeliface.UsageReporter); ok {
+		return ur.LastUsage()
+	}
+	return modeliface.Usage{}
+}
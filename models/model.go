@@ -1,12 +1,22 @@
 package models
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/httpclient"
+	"github.com/codeforge-ide/codeforgeai.go/integrations/githubcopilot"
 	"github.com/codeforge-ide/codeforgeai.go/integrations/githubmodels"
 	"github.com/codeforge-ide/codeforgeai.go/integrations/ollama"
+	"github.com/codeforge-ide/codeforgeai.go/secrets"
+	"golang.org/x/term"
 	// ...add other integrations as needed...
 )
 
@@ -15,27 +25,210 @@ type Model interface {
 	SendRequest(prompt string, config interface{}) (string, error)
 }
 
-// GetModelFromConfig returns a Model implementation based on config.Integrations.Default
+// remoteModel, if set, returns a model answered by a process that keeps it
+// warm, or an error to set it up here.
+var remoteModel func(modelType string) (Model, error)
+
+// SetRemote makes GetModelFromConfig ask model first. It is set once at
+// startup, when the daemon is running.
+func SetRemote(model func(modelType string) (Model, error)) {
+	remoteModel = model
+}
+
+// GetModelFromConfig returns a Model implementation based on config.Integrations.Default,
+// falling back to the providers of config.Integrations.Fallback in order.
 func GetModelFromConfig(cfg *config.Config, modelType string) (Model, error) {
-	provider := cfg.Integrations.Default
+	if remoteModel != nil {
+		model, err := remoteModel(modelType)
+		if err == nil {
+			return model, nil
+		}
+		slog.Debug("setting up the model in-process", "error", err)
+	}
+	f := newFallbackModel(cfg, modelType, Providers(cfg))
+	for i := range f.providers {
+		model, err := f.resolve(i)
+		if err != nil {
+			continue
+		}
+		if len(f.providers) == 1 {
+			return model, nil
+		}
+		return f, nil
+	}
+	return nil, f.errs[0]
+}
+
+// ModelName returns the configured "general" or "code" model of provider.
+func ModelName(cfg *config.Config, provider, modelType string) string {
 	switch provider {
 	case "ollama":
-		// Use Ollama model
-		modelName := cfg.GeneralModel
 		if modelType == "code" {
-			modelName = cfg.CodeModel
+			return cfg.CodeModel
 		}
-		return ollama.NewOllamaModel(modelName, "", 60*time.Second), nil
+		return cfg.GeneralModel
 	case "githubmodels":
-		token := "" // You may want to load from env or config
-		modelName := cfg.GeneralModelGithub
 		if modelType == "code" {
-			modelName = cfg.CodeModelGithub
+			return cfg.CodeModelGithub
+		}
+		return cfg.GeneralModelGithub
+	case "githubcopilot":
+		return cfg.Copilot.Model
+	}
+	return ""
+}
+
+// ErrNotPulled is returned by Preflight for an Ollama model that has not
+// been pulled and is not pulled for the caller.
+var ErrNotPulled = errors.New("ollama model is not pulled")
+
+// interactive is false in servers, which cannot ask and must not block a
+// request on a pull.
+var interactive = true
+
+// SetInteractive sets whether Preflight may offer to pull a missing Ollama
+// model, or pull it when auto_pull is set. Servers turn it off at startup;
+// Preflight then fails with ErrNotPulled instead.
+func SetInteractive(on bool) {
+	interactive = on
+}
+
+// Preflight checks that modelName is ready to serve requests on provider
+// before the first one is sent.
+func Preflight(cfg *config.Config, provider, modelName string) error {
+	switch provider {
+	case "ollama":
+		return ollamaPreflight(cfg, modelName)
+	case "githubcopilot":
+		return copilotPreflight(cfg)
+	}
+	return nil
+}
+
+// copilotPreflight starts the Copilot language server and makes sure it is
+// signed in, so the first request does not fail with an auth error.
+func copilotPreflight(cfg *config.Config) error {
+	client, err := githubcopilot.Shared(cfg.Copilot.Command)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	status, err := client.CheckStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("checking GitHub Copilot sign-in: %w", err)
+	}
+	if !status.SignedIn() {
+		return fmt.Errorf("not signed in to GitHub Copilot (%s); run 'codeforgeai github copilot login'", status.Status)
+	}
+	return nil
+}
+
+// validateGithubModel rejects model names that are not in the GitHub Models
+// catalog, with did-you-mean suggestions. Without a catalog (offline, first
+// run) the name is accepted as is.
+func validateGithubModel(cfg *config.Config, modelName string) error {
+	if modelName == "" {
+		return nil
+	}
+	catalog, _, err := githubmodels.LoadCatalog(secrets.GithubToken(), githubmodels.CatalogRefresh(cfg), false)
+	if err != nil {
+		slog.Debug("skipping github models name check", "error", err)
+		return nil
+	}
+	return githubmodels.ValidateModel(catalog, modelName)
+}
+
+// ollamaPreflight makes sure the Ollama server is up and modelName has been
+// pulled, offering to pull it (or pulling it when auto_pull is set) instead
+// of failing later with an opaque "model not found". Servers only check.
+func ollamaPreflight(cfg *config.Config, modelName string) error {
+	client := ollama.NewClient("")
+	ok, err := client.Has(modelName)
+	if err != nil {
+		if errors.Is(err, ollama.ErrNotRunning) {
+			return fmt.Errorf("%w; start it with 'ollama serve'", err)
+		}
+		return err
+	}
+	if ok {
+		return nil
+	}
+	if !interactive {
+		return fmt.Errorf("%w: %s; run 'codeforgeai ollama pull %s'", ErrNotPulled, modelName, modelName)
+	}
+	if !cfg.Ollama.AutoPull {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("%w: %s; run 'codeforgeai ollama pull %s' or set \"auto_pull\": true", ErrNotPulled, modelName, modelName)
+		}
+		fmt.Fprintf(os.Stderr, "Ollama model %q is not pulled. Pull it now? [Y/n] ", modelName)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "" && a != "y" && a != "yes" {
+			return fmt.Errorf("%w: %s", ErrNotPulled, modelName)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Pulling %s...\n", modelName)
+	return client.Pull(modelName, ollama.ProgressBar())
+}
+
+// NewModel returns the named model of provider wrapped in the request
+// pipeline (privacy checks, redaction, logging).
+func NewModel(cfg *config.Config, provider, modelName string) (Model, error) {
+	// Each call gets a copy of the provider's client, which records the
+	// usage of that call only.
+	var newBase func() Model
+	var endpoint string
+	switch provider {
+	case "ollama":
+		om := ollama.NewOllamaModel(modelName, "", 60*time.Second)
+		om.KeepAlive = cfg.Ollama.KeepAlive
+		om.FIMTemplates = cfg.Completion.Templates
+		newBase = func() Model {
+			c := *om
+			return &c
+		}
+		endpoint = om.Endpoint
+	case "githubmodels":
+		token := secrets.GithubToken()
+		if token == "" {
+			return nil, errors.New("no GitHub token found: pass --github-token, set GITHUB_TOKEN, run 'codeforgeai secrets unlock', or log in with 'gh auth login'")
 		}
 		client := githubmodels.NewClient(token, modelName, "")
-		return client, nil
+		newBase = func() Model {
+			c := *client
+			return githubmodels.ChatAdapter{Client: &c}
+		}
+		endpoint = client.Endpoint
+	case "githubcopilot":
+		cm := githubcopilot.NewCopilotModel(cfg.Copilot.Command, modelName)
+		newBase = func() Model { return cm }
+		endpoint = githubcopilot.APIURL
 	// Add more providers here as needed
 	default:
 		return nil, errors.New("unknown model provider: " + provider)
 	}
+
+	if config.LocalOnly(cfg) {
+		httpclient.SetLocalOnly(true)
+	}
+	if err := httpclient.CheckURL("model provider "+provider, endpoint); err != nil {
+		return nil, err
+	}
+
+	if provider == "githubmodels" {
+		if err := validateGithubModel(cfg, modelName); err != nil {
+			return nil, err
+		}
+	}
+
+	redaction, err := redactionMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mws := []Middleware{redaction}
+	if provider == "ollama" {
+		mws = append(mws, ollamaOptionsMiddleware(cfg))
+	}
+	mws = append(mws, cacheMiddleware(cfg), usageMiddleware(cfg), loggingMiddleware(cfg))
+	return newPipeline(newBase, provider, modelName, mws...), nil
 }
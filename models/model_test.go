@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+)
+
+func TestPreflightNonInteractive(t *testing.T) {
+	var pulls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models": [{"name": "present:latest"}]}`))
+		case "/api/pull":
+			pulls++
+			w.Write([]byte(`{"status": "success"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_API_ENDPOINT", srv.URL)
+	SetInteractive(false)
+	defer SetInteractive(true)
+
+	cfg := config.Config{}
+	cfg.Ollama.AutoPull = true
+	if err := Preflight(&cfg, "ollama", "present"); err != nil {
+		t.Fatalf("Preflight of a pulled model: %v", err)
+	}
+	if err := Preflight(&cfg, "ollama", "missing"); !errors.Is(err, ErrNotPulled) {
+		t.Fatalf("Preflight of a missing model = %v, want ErrNotPulled", err)
+	}
+	if pulls != 0 {
+		t.Fatalf("pulled %d times without being interactive", pulls)
+	}
+}
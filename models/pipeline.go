@@ -0,0 +1,545 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/audit"
+	"github.com/codeforge-ide/codeforgeai.go/cache"
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/redact"
+	"github.com/codeforge-ide/codeforgeai.go/usage"
+)
+
+// Call is a single model invocation as it flows through the pipeline.
+type Call struct {
+	Provider string
+	Model    string
+	Prompt   string
+	Options  map[string]interface{}
+	// Messages is set for multi-turn calls; Prompt then holds the rendered
+	// conversation so caching and logging cover all of it.
+	Messages []modeliface.Message
+	// Stream, if set, receives the reply as it is generated. Middleware
+	// that rewrites the reply wraps it; cache hits call it once.
+	Stream func(string)
+	// Fill marks a fill-in-the-middle call: Prompt is the code before the
+	// cursor and Suffix the code after it.
+	Fill   bool
+	Suffix string
+	// Ctx, if set, abandons the request when cancelled. A call whose Ctx
+	// is already cancelled never reaches the provider.
+	Ctx context.Context
+
+	// Project is the root of the project the call is made for, recorded
+	// with its usage and in the audit log; "" for config.ProjectRoot().
+	Project string
+	// Notify, if set, receives the notices meant for the user, such as
+	// what was redacted; they are printed to stderr otherwise.
+	Notify func(string)
+
+	// Usage is filled in from the provider after the request completes.
+	Usage modeliface.Usage
+}
+
+// Operation returns the engine operation name recorded in the call options.
+func (c *Call) Operation() string {
+	op, _ := c.Options["operation"].(string)
+	return op
+}
+
+// project returns the project root the call is made for.
+func (c *Call) project() string {
+	if c.Project != "" {
+		return c.Project
+	}
+	return config.ProjectRoot()
+}
+
+// notify passes msg on to the user.
+func (c *Call) notify(msg string) {
+	if c.Notify != nil {
+		c.Notify(msg)
+		return
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// text returns everything the call sends, for cache keys and logs.
+func (c *Call) text() string {
+	if c.Fill {
+		return modeliface.RenderFill(c.Prompt, c.Suffix)
+	}
+	return c.Prompt
+}
+
+// Handler performs a call and returns the model output.
+type Handler func(c *Call) (string, error)
+
+// Middleware wraps a Handler with cross-cutting behaviour.
+type Middleware func(next Handler) Handler
+
+// pipelineModel runs every request through a chain of middleware before it
+// reaches the provider, so providers need no changes to benefit.
+type pipelineModel struct {
+	provider string
+	model    string
+	handler  Handler
+
+	mu        sync.Mutex
+	lastUsage modeliface.Usage
+}
+
+// newPipeline wraps the model newBase returns with middleware; the first
+// middleware runs first. Every call gets a base of its own, so calls can
+// run concurrently and each gets its own usage.
+func newPipeline(newBase func() Model, provider, model string, mws ...Middleware) Model {
+	h := func(c *Call) (string, error) {
+		base := newBase()
+		var resp string
+		var err error
+		sm, canStream := base.(modeliface.StreamingChatModel)
+		cm, canChat := base.(modeliface.ChatModel)
+		fm, canFill := base.(modeliface.FillModel)
+		xm, hasContext := base.(modeliface.ContextModel)
+		ctx := c.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		switch {
+		case c.Fill && canFill:
+			resp, err = fm.Fill(ctx, c.Prompt, c.Suffix, c.Options, c.Stream)
+		case canStream && c.Stream != nil && len(c.Messages) > 0:
+			resp, err = sm.ChatStream(c.Messages, c.Options, c.Stream)
+		default:
+			if canChat && len(c.Messages) > 0 {
+				resp, err = cm.Chat(c.Messages, c.Options)
+			} else if c.Fill {
+				resp, err = base.SendRequest(c.text(), c.Options)
+			} else if hasContext && c.Ctx != nil {
+				resp, err = xm.SendRequestContext(ctx, c.Prompt, c.Options)
+			} else {
+				resp, err = base.SendRequest(c.Prompt, c.Options)
+			}
+			// Deliver non-streaming replies in one piece.
+			if c.Stream != nil && err == nil {
+				c.Stream(resp)
+			}
+		}
+		if ur, ok := base.(modeliface.UsageReporter); ok && err == nil {
+			c.Usage = ur.LastUsage()
+		}
+		return resp, err
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return &pipelineModel{provider: provider, model: model, handler: h}
+}
+
+func (p *pipelineModel) SendRequest(prompt string, cfg interface{}) (string, error) {
+	return p.run(&Call{Provider: p.provider, Model: p.model, Prompt: prompt, Options: callOptions(cfg)})
+}
+
+// SendRequestContext is SendRequest abandoned when ctx is cancelled, for
+// providers that support it; the others finish the request.
+func (p *pipelineModel) SendRequestContext(ctx context.Context, prompt string, cfg interface{}) (string, error) {
+	return p.run(&Call{Provider: p.provider, Model: p.model, Prompt: prompt, Options: callOptions(cfg), Ctx: ctx})
+}
+
+// Chat sends a whole conversation through the pipeline. Providers that
+// cannot take one receive the rendered transcript as a single prompt.
+func (p *pipelineModel) Chat(messages []modeliface.Message, cfg interface{}) (string, error) {
+	return p.ChatStream(messages, cfg, nil)
+}
+
+// ChatStream is Chat with the reply passed to onToken as it is generated.
+// Providers that cannot stream deliver it in one piece.
+func (p *pipelineModel) ChatStream(messages []modeliface.Message, cfg interface{}, onToken func(string)) (string, error) {
+	msgs := append([]modeliface.Message(nil), messages...)
+	return p.run(&Call{
+		Provider: p.provider,
+		Model:    p.model,
+		Prompt:   modeliface.RenderMessages(msgs),
+		Options:  callOptions(cfg),
+		Messages: msgs,
+		Stream:   onToken,
+	})
+}
+
+// Fill completes the code between prefix and suffix. Providers without
+// fill-in-the-middle support get an instruction to do the same.
+func (p *pipelineModel) Fill(ctx context.Context, prefix, suffix string, cfg interface{}, onToken func(string)) (string, error) {
+	return p.run(&Call{
+		Provider: p.provider,
+		Model:    p.model,
+		Prompt:   prefix,
+		Options:  callOptions(cfg),
+		Stream:   onToken,
+		Fill:     true,
+		Suffix:   suffix,
+		Ctx:      ctx,
+	})
+}
+
+func (p *pipelineModel) run(c *Call) (string, error) {
+	c.Provider, c.Model = p.provider, p.model
+	if c.Options == nil {
+		c.Options = map[string]interface{}{}
+	}
+	if c.Prompt == "" && len(c.Messages) > 0 {
+		c.Prompt = modeliface.RenderMessages(c.Messages)
+	}
+	resp, err := p.handler(c)
+	p.mu.Lock()
+	p.lastUsage = c.Usage
+	p.mu.Unlock()
+	return resp, err
+}
+
+// LastUsage returns the token counts of the most recent call, which are
+// those stored with the entry on a cache hit. Callers making concurrent
+// calls use Run, which reports each call's own.
+func (p *pipelineModel) LastUsage() modeliface.Usage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastUsage
+}
+
+// Run makes the call c with m, leaving the call's usage in c.Usage. Unlike
+// reading LastUsage afterwards, that holds when m is used concurrently.
+// Provider and Model are filled in; Prompt is rendered from Messages when
+// empty.
+func Run(m Model, c *Call) (string, error) {
+	r, ok := m.(interface{ run(*Call) (string, error) })
+	if !ok {
+		return "", fmt.Errorf("model %T does not run calls", m)
+	}
+	return r.run(c)
+}
+
+func callOptions(cfg interface{}) map[string]interface{} {
+	opts, _ := cfg.(map[string]interface{})
+	if opts == nil {
+		opts = map[string]interface{}{}
+	}
+	return opts
+}
+
+// isLocalProvider reports whether provider runs on this machine.
+func isLocalProvider(provider string) bool {
+	return provider == "ollama"
+}
+
+// redactionMiddleware masks secrets and PII in the prompt before it is sent
+// and restores the placeholders in the model output, so edits written back
+// to files keep the original values.
+func redactionMiddleware(cfg *config.Config) (Middleware, error) {
+	mode := cfg.Redaction.Mode
+	patterns := cfg.Redaction.CustomPatterns
+	// Validate custom patterns up front so a typo fails loudly.
+	if _, err := redact.New(patterns); err != nil {
+		return nil, err
+	}
+	return func(next Handler) Handler {
+		return func(c *Call) (string, error) {
+			if mode == "off" || (mode == "cloud" && isLocalProvider(c.Provider)) {
+				return next(c)
+			}
+			r, _ := redact.New(patterns)
+			if len(c.Messages) > 0 {
+				// Redact a copy: the caller's messages may be sent on to
+				// another provider when this one fails.
+				msgs := make([]modeliface.Message, len(c.Messages))
+				for i, m := range c.Messages {
+					m.Content = r.Redact(m.Content)
+					msgs[i] = m
+				}
+				c.Messages = msgs
+				c.Prompt = modeliface.RenderMessages(msgs)
+			} else {
+				c.Prompt = r.Redact(c.Prompt)
+				c.Suffix = r.Redact(c.Suffix)
+			}
+			if findings := r.Report(); len(findings) > 0 {
+				c.notify(fmt.Sprintf("🔒 Redacted before sending to %s: %s", c.Provider, redact.Summary(findings)))
+			}
+			if c.Stream != nil {
+				sr := r.NewStreamRestorer(c.Stream)
+				c.Stream = sr.Write
+				defer sr.Flush()
+			}
+			resp, err := next(c)
+			if err != nil {
+				return "", err
+			}
+			return r.Restore(resp), nil
+		}
+	}, nil
+}
+
+// defaultOllamaContext is the num_ctx Ollama uses when none is given.
+const defaultOllamaContext = 2048
+
+// ollamaOptionsMiddleware resolves the system prompt, format and options
+// block of an Ollama call from the config, with per-operation overrides and
+// then per-call options (including "temperature" and "max_tokens") taking
+// precedence, and raises num_ctx when the prompt would not fit. It runs
+// before the cache so the resolved settings are part of the cache key.
+func ollamaOptionsMiddleware(cfg *config.Config) Middleware {
+	oc := cfg.Ollama
+	return func(next Handler) Handler {
+		return func(c *Call) (string, error) {
+			op := oc.Operations[c.Operation()]
+			genOpts := map[string]interface{}{}
+			for k, v := range oc.Options {
+				genOpts[k] = v
+			}
+			for k, v := range op.Options {
+				genOpts[k] = v
+			}
+			// The sampling options of OpenAI-style requests.
+			if t, ok := c.Options["temperature"]; ok {
+				genOpts["temperature"] = t
+			}
+			if n, ok := c.Options["max_tokens"]; ok {
+				genOpts["num_predict"] = n
+			}
+			if callOpts, ok := c.Options["options"].(map[string]interface{}); ok {
+				for k, v := range callOpts {
+					genOpts[k] = v
+				}
+			}
+			system := oc.System
+			if op.System != "" {
+				system = op.System
+			}
+			if s, ok := c.Options["system"].(string); ok {
+				system = s
+			}
+			format := oc.Format
+			if op.Format != nil {
+				format = op.Format
+			}
+			if f, ok := c.Options["format"]; ok {
+				format = f
+			}
+			if n := contextFor(system+c.Prompt, genOpts, oc.MaxContext); n > 0 {
+				slog.Info("raising ollama num_ctx for long prompt", "operation", c.Operation(), "num_ctx", n)
+				genOpts["num_ctx"] = n
+			}
+
+			resolved := make(map[string]interface{}, len(c.Options)+3)
+			for k, v := range c.Options {
+				resolved[k] = v
+			}
+			resolved["system"] = system
+			resolved["options"] = genOpts
+			if format != nil && format != "" {
+				resolved["format"] = format
+			}
+			c.Options = resolved
+			return next(c)
+		}
+	}
+}
+
+// contextFor returns the num_ctx needed to fit text plus the expected
+// output, rounded up to a power of two and capped at maxContext, or 0 if
+// the configured context already suffices or the feature is disabled.
+func contextFor(text string, genOpts map[string]interface{}, maxContext int) int {
+	if maxContext <= 0 {
+		return 0
+	}
+	current := defaultOllamaContext
+	if n, ok := toFloat(genOpts["num_ctx"]); ok && n > 0 {
+		current = int(n)
+	}
+	output := 1024
+	if n, ok := toFloat(genOpts["num_predict"]); ok && n > 0 {
+		output = int(n)
+	}
+	// About three characters per token for code keeps the estimate on the
+	// safe side.
+	needed := len(text)/3 + output
+	if needed <= current || current >= maxContext {
+		return 0
+	}
+	n := defaultOllamaContext
+	for n < needed {
+		n *= 2
+	}
+	return min(n, maxContext)
+}
+
+// cacheable reports whether c is deterministic enough to be served from the
+// cache: only calls with an explicit temperature of zero, unless force is
+// set. Without one the provider samples at its default temperature, so
+// asking again should give a new reply. Replays, which exist to hit the
+// provider again, are never cached.
+func cacheable(c *Call, force bool) bool {
+	if _, ok := c.Options["replay_of"]; ok {
+		return false
+	}
+	if force {
+		return true
+	}
+	if f, ok := c.Options["force_cache"].(bool); ok && f {
+		return true
+	}
+	temp, ok := c.Options["temperature"]
+	if genOpts, isMap := c.Options["options"].(map[string]interface{}); !ok && isMap {
+		temp, ok = genOpts["temperature"]
+	}
+	if t, isNum := toFloat(temp); ok && isNum {
+		return t <= 0
+	}
+	return false
+}
+
+// toFloat converts a JSON or Go number to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// cacheMiddleware serves repeated calls from the on-disk response cache. It
+// runs after redaction so cached entries only ever hold masked prompts and
+// responses.
+func cacheMiddleware(cfg *config.Config) Middleware {
+	cc := cfg.Cache
+	ttl := time.Duration(cc.TTLMinutes) * time.Minute
+	maxBytes := int64(cc.MaxSizeMB) << 20
+	return func(next Handler) Handler {
+		return func(c *Call) (string, error) {
+			if !cc.Enabled || cache.Disabled() || !cacheable(c, cc.Force) {
+				return next(c)
+			}
+			key := cache.Key(c.Provider, c.Model, c.text(), c.Options)
+			if e, ok := cache.Get(key, ttl); ok {
+				slog.Info("cache hit", "provider", c.Provider, "model", c.Model, "operation", c.Operation(), "key", key[:12])
+				c.Usage = e.Usage
+				if c.Stream != nil {
+					c.Stream(e.Response)
+				}
+				return e.Response, nil
+			}
+			resp, err := next(c)
+			if err != nil {
+				return resp, err
+			}
+			entry := cache.Entry{Provider: c.Provider, Model: c.Model, Response: resp, Usage: c.Usage}
+			if perr := cache.Put(key, entry, maxBytes); perr != nil {
+				slog.Warn("could not write response cache", "error", perr)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// usageMiddleware records token counts and cost per project, operation and
+// day, and enforces budgets. It runs inside the cache so cache hits are free,
+// and only blocks calls to models that have a price.
+func usageMiddleware(cfg *config.Config) Middleware {
+	return func(next Handler) Handler {
+		return func(c *Call) (string, error) {
+			project := c.project()
+			price, _ := usage.PriceFor(cfg, c.Provider, c.Model)
+			paid := price.Prompt > 0 || price.Completion > 0
+			if paid {
+				records, err := usage.Load()
+				if err != nil {
+					slog.Warn("could not read usage ledger", "error", err)
+				}
+				if _, blocking := usage.Check(cfg, records, project); blocking != nil {
+					return "", blocking
+				}
+			}
+
+			resp, err := next(c)
+			if err != nil {
+				return resp, err
+			}
+			records, uerr := usage.Add(cfg, project, c.Operation(), c.Provider, c.Model, c.Usage)
+			if uerr != nil {
+				slog.Warn("could not record usage", "error", uerr)
+				return resp, nil
+			}
+			if paid {
+				exceeded, _ := usage.Check(cfg, records, project)
+				for _, e := range exceeded {
+					c.notify(fmt.Sprintf("⚠️  %s %s budget exceeded: $%.4f of $%.2f spent", e.Scope, e.Period, e.Spent, e.Limit))
+				}
+			}
+			return resp, nil
+		}
+	}
+}
+
+// loggingMiddleware logs every call through slog and, when audit_log is
+// enabled, appends it to the JSONL audit log. It runs after redaction so
+// only masked prompts are recorded.
+func loggingMiddleware(cfg *config.Config) Middleware {
+	auditEnabled := cfg.AuditLog
+	return func(next Handler) Handler {
+		return func(c *Call) (string, error) {
+			slog.Debug("model request", "provider", c.Provider, "model", c.Model, "operation", c.Operation(), "prompt", c.text())
+			start := time.Now()
+			resp, err := next(c)
+			latency := time.Since(start)
+
+			attrs := []any{
+				"provider", c.Provider, "model", c.Model, "operation", c.Operation(),
+				"latency", latency.Round(time.Millisecond),
+				"prompt_tokens", c.Usage.PromptTokens, "completion_tokens", c.Usage.CompletionTokens,
+			}
+			if err != nil {
+				slog.Info("model call failed", append(attrs, "error", err)...)
+			} else {
+				slog.Info("model call", attrs...)
+				slog.Debug("model response", "response", resp)
+			}
+
+			if auditEnabled {
+				entry := audit.Entry{
+					Operation: c.Operation(),
+					Project:   c.project(),
+					Provider:  c.Provider,
+					Model:     c.Model,
+					Prompt:    c.text(),
+					Options:   c.Options,
+					Response:  resp,
+					LatencyMS: latency.Milliseconds(),
+					Usage:     c.Usage,
+				}
+				if replayOf, ok := c.Options["replay_of"].(string); ok {
+					entry.ReplayOf = replayOf
+				}
+				if err != nil {
+					entry.Error = err.Error()
+				}
+				if aerr := audit.Append(entry); aerr != nil {
+					slog.Warn("could not write audit log", "error", aerr)
+				}
+			}
+			return resp, err
+		}
+	}
+}
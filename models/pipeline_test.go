@@ -0,0 +1,213 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/usage"
+)
+
+// ctxModel records which entry point each request came through.
+type ctxModel struct {
+	calls []string
+}
+
+func (m *ctxModel) SendRequest(prompt string, cfg interface{}) (string, error) {
+	m.calls = append(m.calls, "plain")
+	return "ok", nil
+}
+
+func (m *ctxModel) SendRequestContext(ctx context.Context, prompt string, cfg interface{}) (string, error) {
+	m.calls = append(m.calls, "context")
+	return "ok", ctx.Err()
+}
+
+func TestPipelineContext(t *testing.T) {
+	base := &ctxModel{}
+	p := newPipeline(func() Model { return base }, "test", "m").(*pipelineModel)
+
+	if _, err := p.SendRequest("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SendRequestContext(context.Background(), "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.SendRequestContext(ctx, "hi", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled request returned %v", err)
+	}
+	if want := []string{"plain", "context"}; len(base.calls) != 2 || base.calls[0] != want[0] || base.calls[1] != want[1] {
+		t.Fatalf("provider calls = %q, want %q and no call once cancelled", base.calls, want)
+	}
+}
+
+// usageModel reports as many completion tokens as its prompt has bytes,
+// after a pause that lets concurrent calls overlap.
+type usageModel struct {
+	lastUsage modeliface.Usage
+}
+
+func (m *usageModel) SendRequest(prompt string, cfg interface{}) (string, error) {
+	time.Sleep(time.Millisecond)
+	m.lastUsage = modeliface.Usage{CompletionTokens: len(prompt)}
+	return prompt, nil
+}
+
+func (m *usageModel) LastUsage() modeliface.Usage { return m.lastUsage }
+
+func TestRunConcurrentUsage(t *testing.T) {
+	p := newPipeline(func() Model { return &usageModel{} }, "test", "m")
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			c := &Call{Prompt: strings.Repeat("x", n)}
+			if _, err := Run(p, c); err != nil {
+				t.Error(err)
+				return
+			}
+			if c.Usage.CompletionTokens != n || c.Provider != "test" || c.Model != "m" {
+				t.Errorf("call %d: usage %+v, provider %q, model %q", n, c.Usage, c.Provider, c.Model)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// chatModel echoes the last message it is sent, or fails with err.
+type chatModel struct {
+	err error
+}
+
+func (m *chatModel) SendRequest(prompt string, cfg interface{}) (string, error) {
+	return "", errors.New("chat only")
+}
+
+func (m *chatModel) Chat(messages []modeliface.Message, cfg interface{}) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	return "echo " + messages[len(messages)-1].Content, nil
+}
+
+func TestFallbackRedactsEachAttempt(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Redaction.Mode = "cloud"
+	redaction, err := redactionMiddleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFallbackModel(cfg, "general", []string{"githubmodels", "ollama"})
+	f.models[0] = newPipeline(func() Model { return &chatModel{err: errors.New("unavailable")} }, "githubmodels", "m", redaction)
+	f.models[1] = newPipeline(func() Model { return &chatModel{} }, "ollama", "m", redaction)
+
+	token := "ghp_" + strings.Repeat("a", 36)
+	c := &Call{Messages: []modeliface.Message{{Role: "user", Content: "my key is " + token}}}
+	reply, err := Run(f, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The local provider gets the original message, not the placeholders
+	// masked for the cloud one.
+	if want := "echo my key is " + token; reply != want {
+		t.Fatalf("reply = %q, want %q", reply, want)
+	}
+	if c.Messages[0].Content != "my key is "+token {
+		t.Fatalf("caller's message changed to %q", c.Messages[0].Content)
+	}
+}
+
+func TestCallProjectAndNotices(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := &config.Config{}
+	cfg.Redaction.Mode = "all"
+	redaction, err := redactionMiddleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPipeline(func() Model { return &usageModel{} }, "test", "m", redaction, usageMiddleware(cfg))
+
+	var notices []string
+	c := &Call{
+		Prompt:  "token ghp_" + strings.Repeat("a", 36),
+		Project: "/elsewhere/project",
+		Notify:  func(msg string) { notices = append(notices, msg) },
+	}
+	if _, err := Run(p, c); err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "Redacted before sending to test") {
+		t.Fatalf("notices = %q, want the redaction", notices)
+	}
+	records, err := usage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Project != c.Project {
+		t.Fatalf("usage records = %+v, want one for %s", records, c.Project)
+	}
+}
+
+func TestOllamaOptionsSampling(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Ollama.Options = map[string]interface{}{"temperature": 0.2, "num_predict": 100, "seed": 1}
+	tests := []struct {
+		name string
+		opts map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"config only", nil, map[string]interface{}{"temperature": 0.2, "num_predict": 100, "seed": 1}},
+		{"request sampling", map[string]interface{}{"temperature": 0.0, "max_tokens": 5},
+			map[string]interface{}{"temperature": 0.0, "num_predict": 5, "seed": 1}},
+		{"options block wins", map[string]interface{}{"max_tokens": 5, "options": map[string]interface{}{"num_predict": 7}},
+			map[string]interface{}{"temperature": 0.2, "num_predict": 7, "seed": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			h := ollamaOptionsMiddleware(&cfg)(func(c *Call) (string, error) {
+				got, _ = c.Options["options"].(map[string]interface{})
+				return "", nil
+			})
+			h(&Call{Prompt: "hi", Options: tt.opts})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("options block = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultCachedOperations(t *testing.T) {
+	cfg := config.DefaultConfig()
+	tests := []struct {
+		operation string
+		want      bool
+	}{
+		{"code_explanation", true},
+		{"commit_message", true},
+		{"gitmoji_selection", true},
+		{"directory_classification", true},
+		{"code_suggestion", false},
+		{"code_generation", false},
+	}
+	for _, tt := range tests {
+		var got bool
+		h := ollamaOptionsMiddleware(&cfg)(func(c *Call) (string, error) {
+			got = cacheable(c, false)
+			return "", nil
+		})
+		h(&Call{Prompt: "hi", Options: map[string]interface{}{"operation": tt.operation}})
+		if got != tt.want {
+			t.Errorf("%s cacheable = %v, want %v", tt.operation, got, tt.want)
+		}
+	}
+}
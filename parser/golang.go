@@ -0,0 +1,671 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Symbol kinds.
+const (
+	KindFunc      = "func"
+	KindMethod    = "method"
+	KindType      = "type"
+	KindInterface = "interface"
+	KindVar       = "var"
+	KindConst     = "const"
+)
+
+// Symbol is a top-level Go declaration, or a function declared outside the
+// loaded packages that one of them calls.
+type Symbol struct {
+	// ID is the import path followed by Name, e.g.
+	// "example.com/m/store.DB.Get".
+	ID string `json:"id"`
+	// Name is the declared name, prefixed with the receiver type for
+	// methods.
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Package string `json:"package"`
+	PkgName string `json:"package_name"`
+	// Signature is the declaration without a function body.
+	Signature string `json:"signature"`
+	Doc       string `json:"doc,omitempty"`
+	File      string `json:"file,omitempty"`
+	// StartLine and EndLine span the declaration including its doc
+	// comment.
+	StartLine int `json:"start_line,omitempty"`
+	EndLine   int `json:"end_line,omitempty"`
+	// Calls holds the IDs of the functions and methods called in the body.
+	Calls []string `json:"calls,omitempty"`
+	// Implements holds the IDs of the interfaces a type implements, and
+	// ImplementedBy the IDs of the types implementing an interface.
+	Implements    []string `json:"implements,omitempty"`
+	ImplementedBy []string `json:"implemented_by,omitempty"`
+	// External is set for callees outside the loaded packages; only their
+	// signature is known.
+	External bool `json:"external,omitempty"`
+}
+
+// GoPackage is a loaded Go package.
+type GoPackage struct {
+	Path   string   `json:"path"`
+	Name   string   `json:"name"`
+	Dir    string   `json:"dir"`
+	Files  []string `json:"files"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// GoTable is the symbol table of a set of Go packages.
+type GoTable struct {
+	Root     string
+	Packages []*GoPackage
+	Symbols  map[string]*Symbol
+}
+
+// goPackage is a parsed package with whatever type information could be
+// computed for it.
+type goPackage struct {
+	meta  *GoPackage
+	fset  *token.FileSet
+	files []*ast.File
+	info  *types.Info
+}
+
+// FindGoModule returns the directory of the go.mod that governs dir, or ""
+// when dir is not inside a module.
+func FindGoModule(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadGo builds the symbol table of the packages under dir that match
+// patterns, "./..." by default. It loads them with the go command so that
+// types from other packages resolve; when that is not possible, each
+// directory is parsed and type-checked on its own as far as it goes.
+func LoadGo(dir string, patterns ...string) (*GoTable, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := loadPackages(root, patterns)
+	if err != nil {
+		slog.Debug("could not load Go packages, parsing files directly", "dir", root, "error", err)
+		if pkgs, err = parseDirs(root); err != nil {
+			return nil, err
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", root)
+	}
+
+	t := &GoTable{Root: root, Symbols: map[string]*Symbol{}}
+	b := &builder{t: t, named: map[string]types.Type{}, funcs: map[string]*types.Func{}}
+	for _, p := range pkgs {
+		t.Packages = append(t.Packages, p.meta)
+		b.addPackage(p)
+	}
+	b.resolveCalls()
+	b.linkImplementations()
+	sort.Slice(t.Packages, func(i, j int) bool { return t.Packages[i].Path < t.Packages[j].Path })
+	return t, nil
+}
+
+// loadPackages type-checks the packages and their dependencies from source,
+// which unlike export data works with any toolchain version. Function
+// bodies and comments outside dir are dropped to keep that fast.
+func loadPackages(dir string, patterns []string) ([]*goPackage, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir: dir,
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			if strings.HasPrefix(filename, dir+string(filepath.Separator)) {
+				return goparser.ParseFile(fset, filename, src, goparser.ParseComments)
+			}
+			f, err := goparser.ParseFile(fset, filename, src, goparser.SkipObjectResolution)
+			if f != nil {
+				for _, decl := range f.Decls {
+					if fd, ok := decl.(*ast.FuncDecl); ok {
+						fd.Body = nil
+					}
+				}
+			}
+			return f, err
+		},
+	}
+	loaded, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var out []*goPackage
+	var firstErr error
+	for _, p := range loaded {
+		if len(p.Syntax) == 0 {
+			if len(p.Errors) > 0 && firstErr == nil {
+				firstErr = p.Errors[0]
+			}
+			continue
+		}
+		meta := &GoPackage{Path: p.PkgPath, Name: p.Name, Dir: filepath.Dir(p.GoFiles[0]), Files: p.GoFiles}
+		for _, e := range p.Errors {
+			meta.Errors = append(meta.Errors, e.Error())
+		}
+		out = append(out, &goPackage{meta: meta, fset: p.Fset, files: p.Syntax, info: p.TypesInfo})
+	}
+	if len(out) == 0 {
+		if firstErr == nil {
+			firstErr = errors.New("no packages matched")
+		}
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+// parseDirs parses every package below root without the go command.
+// Imports are resolved from source where possible; type errors are
+// tolerated.
+func parseDirs(root string) ([]*goPackage, error) {
+	byDir := map[string][]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			if ok, _ := build.Default.MatchFile(filepath.Dir(path), name); ok {
+				byDir[filepath.Dir(path)] = append(byDir[filepath.Dir(path)], path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	modPath := modulePath(root)
+	fset := token.NewFileSet()
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil), Error: func(error) {}}
+	var out []*goPackage
+	for _, dir := range dirs {
+		byName := map[string]*goPackage{}
+		var names []string
+		for _, path := range byDir[dir] {
+			f, err := goparser.ParseFile(fset, path, nil, goparser.ParseComments)
+			if err != nil {
+				slog.Debug("skipping unparsable file", "file", path, "error", err)
+				continue
+			}
+			p, ok := byName[f.Name.Name]
+			if !ok {
+				rel, _ := filepath.Rel(root, dir)
+				importPath := f.Name.Name
+				if modPath != "" {
+					importPath = strings.TrimSuffix(modPath+"/"+filepath.ToSlash(rel), "/.")
+				} else if rel != "." {
+					importPath = filepath.ToSlash(rel)
+				}
+				p = &goPackage{meta: &GoPackage{Path: importPath, Name: f.Name.Name, Dir: dir}, fset: fset, info: newInfo()}
+				byName[f.Name.Name] = p
+				names = append(names, f.Name.Name)
+			}
+			p.files = append(p.files, f)
+			p.meta.Files = append(p.meta.Files, path)
+		}
+		for _, name := range names {
+			p := byName[name]
+			if _, err := conf.Check(p.meta.Path, fset, p.files, p.info); err != nil {
+				p.meta.Errors = append(p.meta.Errors, err.Error())
+			}
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// modulePath reads the module path from root's go.mod, if it has one.
+func modulePath(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+func newInfo() *types.Info {
+	return &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+}
+
+type builder struct {
+	t *GoTable
+	// named holds the types declared in the loaded packages by ID.
+	named map[string]types.Type
+	// funcs holds every resolved callee by ID.
+	funcs map[string]*types.Func
+}
+
+func (b *builder) addPackage(p *goPackage) {
+	if p.info == nil {
+		p.info = newInfo()
+	}
+	for _, f := range p.files {
+		file := p.fset.File(f.Pos()).Name()
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				b.addFunc(p, file, d)
+			case *ast.GenDecl:
+				b.addGen(p, file, d)
+			}
+		}
+	}
+}
+
+func (b *builder) add(p *goPackage, file string, s *Symbol, node ast.Node, doc *ast.CommentGroup) *Symbol {
+	s.ID = p.meta.Path + "." + s.Name
+	if _, dup := b.t.Symbols[s.ID]; dup || strings.HasPrefix(s.Name, "_") {
+		return nil
+	}
+	s.Package = p.meta.Path
+	s.PkgName = p.meta.Name
+	s.File = file
+	start := node.Pos()
+	if doc != nil {
+		start = doc.Pos()
+		s.Doc = doc.Text()
+	}
+	s.StartLine = p.fset.Position(start).Line
+	s.EndLine = p.fset.Position(node.End()).Line
+	b.t.Symbols[s.ID] = s
+	return s
+}
+
+func (b *builder) addFunc(p *goPackage, file string, d *ast.FuncDecl) {
+	s := &Symbol{Name: d.Name.Name, Kind: KindFunc}
+	if d.Recv != nil && len(d.Recv.List) > 0 {
+		s.Name = receiverName(d.Recv.List[0].Type) + "." + s.Name
+		s.Kind = KindMethod
+	}
+	s.Signature = printNode(p.fset, &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type})
+	if s = b.add(p, file, s, d, d.Doc); s == nil || d.Body == nil {
+		return
+	}
+	seen := map[string]bool{}
+	ast.Inspect(d.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id := b.calleeID(p, call.Fun); id != "" && !seen[id] {
+				seen[id] = true
+				s.Calls = append(s.Calls, id)
+			}
+		}
+		return true
+	})
+}
+
+func (b *builder) addGen(p *goPackage, file string, d *ast.GenDecl) {
+	single := !d.Lparen.IsValid()
+	for _, spec := range d.Specs {
+		var node ast.Node = spec
+		doc := specDoc(spec)
+		if single {
+			node, doc = d, d.Doc
+		}
+		sig := printNode(p.fset, &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{spec}})
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			kind := KindType
+			if _, ok := spec.Type.(*ast.InterfaceType); ok {
+				kind = KindInterface
+			}
+			s := b.add(p, file, &Symbol{Name: spec.Name.Name, Kind: kind, Signature: sig}, node, doc)
+			if obj, ok := p.info.Defs[spec.Name].(*types.TypeName); ok && s != nil {
+				b.named[s.ID] = obj.Type()
+			}
+		case *ast.ValueSpec:
+			kind := KindVar
+			if d.Tok == token.CONST {
+				kind = KindConst
+			}
+			for _, name := range spec.Names {
+				b.add(p, file, &Symbol{Name: name.Name, Kind: kind, Signature: sig}, node, doc)
+			}
+		}
+	}
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return spec.Doc
+	case *ast.ValueSpec:
+		return spec.Doc
+	}
+	return nil
+}
+
+// calleeID returns the ID of the function called through fun, or "" for
+// builtins, conversions and calls of function values.
+func (b *builder) calleeID(p *goPackage, fun ast.Expr) string {
+	var ident *ast.Ident
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		ident = f
+	case *ast.SelectorExpr:
+		ident = f.Sel
+	case *ast.IndexExpr:
+		return b.calleeID(p, f.X)
+	case *ast.IndexListExpr:
+		return b.calleeID(p, f.X)
+	default:
+		return ""
+	}
+	obj := p.info.Uses[ident]
+	if fn, ok := obj.(*types.Func); ok {
+		fn = fn.Origin()
+		id := funcID(fn)
+		if id != "" && b.funcs[id] == nil {
+			b.funcs[id] = fn
+		}
+		return id
+	}
+	// Without type information, assume a plain name is a function of the
+	// same package; resolveCalls drops it if there is none.
+	if obj == nil && ident == ast.Unparen(fun) {
+		return p.meta.Path + "." + ident.Name
+	}
+	return ""
+}
+
+func funcID(fn *types.Func) string {
+	if fn.Pkg() == nil {
+		return ""
+	}
+	sig, _ := fn.Type().(*types.Signature)
+	if sig == nil || sig.Recv() == nil {
+		return fn.Pkg().Path() + "." + fn.Name()
+	}
+	t := sig.Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return ""
+	}
+	return fn.Pkg().Path() + "." + named.Obj().Name() + "." + fn.Name()
+}
+
+// resolveCalls adds external symbols for callees outside the loaded
+// packages and drops call edges that lead nowhere.
+func (b *builder) resolveCalls() {
+	qualifier := func(p *types.Package) string { return p.Name() }
+	for id, fn := range b.funcs {
+		if _, ok := b.t.Symbols[id]; ok {
+			continue
+		}
+		// The standard library needs no introduction.
+		if !strings.Contains(strings.Split(fn.Pkg().Path(), "/")[0], ".") {
+			continue
+		}
+		name := strings.TrimPrefix(id, fn.Pkg().Path()+".")
+		kind := KindFunc
+		if strings.Contains(name, ".") {
+			kind = KindMethod
+		}
+		b.t.Symbols[id] = &Symbol{
+			ID: id, Name: name, Kind: kind, Package: fn.Pkg().Path(), PkgName: fn.Pkg().Name(),
+			Signature: types.ObjectString(fn, qualifier), External: true,
+		}
+	}
+	for _, s := range b.t.Symbols {
+		calls := s.Calls[:0]
+		for _, id := range s.Calls {
+			if _, ok := b.t.Symbols[id]; ok && id != s.ID {
+				calls = append(calls, id)
+			}
+		}
+		s.Calls = calls
+	}
+}
+
+// linkImplementations records which declared types implement which
+// declared interfaces.
+func (b *builder) linkImplementations() {
+	var ifaces, concrete []string
+	for id, t := range b.named {
+		named, ok := t.(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		if iface, ok := named.Underlying().(*types.Interface); ok {
+			if iface.NumMethods() > 0 {
+				ifaces = append(ifaces, id)
+			}
+		} else {
+			concrete = append(concrete, id)
+		}
+	}
+	sort.Strings(ifaces)
+	sort.Strings(concrete)
+	for _, i := range ifaces {
+		iface := b.named[i].Underlying().(*types.Interface)
+		for _, c := range concrete {
+			t := b.named[c]
+			if types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface) {
+				b.t.Symbols[i].ImplementedBy = append(b.t.Symbols[i].ImplementedBy, c)
+				b.t.Symbols[c].Implements = append(b.t.Symbols[c].Implements, i)
+			}
+		}
+	}
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.ParenExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "_"
+}
+
+func printNode(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// Lookup returns the symbols called name, sorted by ID. name is an ID, a
+// declared name such as "Func" or "Type.Method" (or just "Method"), or
+// either qualified by the package name or a suffix of its import path, such
+// as "store.DB.Get".
+func (t *GoTable) Lookup(name string) []*Symbol {
+	if s, ok := t.Symbols[name]; ok {
+		return []*Symbol{s}
+	}
+	var out []*Symbol
+	for _, s := range t.Symbols {
+		if !s.External && s.matches(name) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (s *Symbol) matches(name string) bool {
+	names := []string{s.Name}
+	if s.Kind == KindMethod {
+		// Methods can be named without their receiver type.
+		names = append(names, s.Name[strings.Index(s.Name, ".")+1:])
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+		pkg, ok := strings.CutSuffix(name, "."+n)
+		if ok && (pkg == s.PkgName || pkg == s.Package || strings.HasSuffix(s.Package, "/"+pkg)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the one symbol called name, declared in file when file is
+// not empty.
+func (t *GoTable) Find(name, file string) (*Symbol, error) {
+	matches := t.Lookup(name)
+	if file != "" {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		kept := matches[:0]
+		for _, s := range matches {
+			if s.File == abs {
+				kept = append(kept, s)
+			}
+		}
+		matches = kept
+	}
+	switch len(matches) {
+	case 0:
+		if file != "" {
+			return nil, fmt.Errorf("no Go symbol %q in %s", name, file)
+		}
+		return nil, fmt.Errorf("no Go symbol %q in %s", name, t.Root)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, s := range matches {
+		ids[i] = s.ID
+	}
+	return nil, fmt.Errorf("%q is ambiguous, use one of: %s", name, strings.Join(ids, ", "))
+}
+
+// Methods returns the methods declared on the type s.
+func (t *GoTable) Methods(s *Symbol) []*Symbol {
+	var out []*Symbol
+	for _, m := range t.Symbols {
+		if m.Kind == KindMethod && !m.External && m.Package == s.Package && strings.HasPrefix(m.Name, s.Name+".") {
+			out = append(out, m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartLine < out[j].StartLine })
+	return out
+}
+
+// Source returns the declaration of s as it is in its file, including its
+// doc comment.
+func (s *Symbol) Source() (string, error) {
+	if s.File == "" {
+		return "", fmt.Errorf("the source of %s is not available", s.ID)
+	}
+	return readLines(s.File, s.StartLine, s.EndLine)
+}
+
+// Summary renders the doc comment and signature of s as Go source, headed
+// by its ID.
+func (s *Symbol) Summary() string {
+	var b strings.Builder
+	b.WriteString("// " + s.ID + "\n")
+	if doc := strings.TrimRight(s.Doc, "\n"); doc != "" {
+		b.WriteString("//\n")
+		for _, line := range strings.Split(doc, "\n") {
+			b.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+		}
+	}
+	b.WriteString(s.Signature)
+	return b.String()
+}
+
+// Context describes what s depends on for a prompt: the signatures and doc
+// comments of the functions it calls and, for types, their methods and the
+// interfaces involved. It returns "" when there is nothing to add.
+func (t *GoTable) Context(s *Symbol) string {
+	var b strings.Builder
+	section := func(heading string, syms []*Symbol) {
+		if len(syms) == 0 {
+			return
+		}
+		b.WriteString(heading + ":\n\n")
+		for _, c := range syms {
+			b.WriteString(c.Summary() + "\n\n")
+		}
+	}
+	byID := func(ids []string) []*Symbol {
+		var out []*Symbol
+		for _, id := range ids {
+			if c, ok := t.Symbols[id]; ok {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	section("Functions and methods it calls", byID(s.Calls))
+	if s.Kind == KindType || s.Kind == KindInterface {
+		section("Its methods", t.Methods(s))
+		section("Interfaces it implements", byID(s.Implements))
+		section("Types that implement it", byID(s.ImplementedBy))
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Decl is a declaration in an outline.
+type Decl struct {
+	// Name is the declared name, prefixed with the names of the
+	// declarations it is nested in, e.g. "Client.fetch".
+	Name string `json:"name"`
+	// Kind is "function", "method", "class", "interface", "struct",
+	// "enum", "trait", "impl", "type", "module", "contract", "library",
+	// "event", "modifier", "error" or "macro".
+	Kind   string `json:"kind"`
+	Parent string `json:"parent,omitempty"`
+	// Signature is the first line of the declaration.
+	Signature string `json:"signature"`
+	// StartLine and EndLine span the declaration including the comments,
+	// decorators and attributes directly above it.
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+}
+
+// Outline lists the imports and declarations of a source file.
+type Outline struct {
+	Path     string   `json:"path"`
+	Language string   `json:"language"`
+	Imports  []string `json:"imports,omitempty"`
+	Decls    []Decl   `json:"decls"`
+}
+
+// Extractor outlines the source files of one language.
+type Extractor interface {
+	Language() string
+	Extensions() []string
+	Extract(src []byte) (imports []string, decls []Decl, err error)
+}
+
+var extractors = map[string]Extractor{}
+
+// Register makes e the extractor for its file extensions.
+func Register(e Extractor) {
+	for _, ext := range e.Extensions() {
+		extractors[ext] = e
+	}
+}
+
+func init() {
+	Register(goExtractor{})
+	Register(pythonExtractor{})
+	for _, l := range braceLanguages {
+		Register(l)
+	}
+}
+
+// ExtractorFor returns the extractor for path, or nil when its language is
+// not supported.
+func ExtractorFor(path string) Extractor {
+	return extractors[strings.ToLower(filepath.Ext(path))]
+}
+
+// OutlineFile outlines the source file at path.
+func OutlineFile(path string) (*Outline, error) {
+	e := ExtractorFor(path)
+	if e == nil {
+		return nil, fmt.Errorf("no outline support for %s files", filepath.Ext(path))
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	imports, decls, err := e.Extract(src)
+	if err != nil {
+		return nil, err
+	}
+	if decls == nil {
+		decls = []Decl{}
+	}
+	return &Outline{Path: path, Language: e.Language(), Imports: imports, Decls: decls}, nil
+}
+
+// Lookup returns the declarations called name, either with the names of
+// the declarations they are nested in ("Client.fetch") or without them.
+func (o *Outline) Lookup(name string) []Decl {
+	var out []Decl
+	for _, d := range o.Decls {
+		if d.Name == name || d.Name[strings.LastIndex(d.Name, ".")+1:] == name {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// languageNames maps file extensions to language names, including
+// languages without an extractor.
+var languageNames = map[string]string{
+	".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++", ".cs": "C#",
+	".java": "Java", ".kt": "Kotlin", ".swift": "Swift", ".rb": "Ruby", ".php": "PHP",
+	".scala": "Scala", ".dart": "Dart", ".lua": "Lua", ".ex": "Elixir", ".exs": "Elixir",
+	".sh": "Shell", ".bash": "Shell", ".zig": "Zig", ".vue": "Vue", ".svelte": "Svelte",
+}
+
+// LanguageFor returns the programming language of path from its extension,
+// or "" when it is not source code.
+func LanguageFor(path string) string {
+	if e := ExtractorFor(path); e != nil {
+		return e.Language()
+	}
+	return languageNames[strings.ToLower(filepath.Ext(path))]
+}
+
+// LanguageShare is how much of a project is written in a language.
+type LanguageShare struct {
+	Language string  `json:"language"`
+	Files    int     `json:"files"`
+	Percent  float64 `json:"percent"`
+}
+
+// DetectLanguages counts the source files among paths per language, most
+// used first.
+func DetectLanguages(paths []string) []LanguageShare {
+	counts := map[string]int{}
+	total := 0
+	for _, p := range paths {
+		if lang := LanguageFor(p); lang != "" {
+			counts[lang]++
+			total++
+		}
+	}
+	out := make([]LanguageShare, 0, len(counts))
+	for lang, n := range counts {
+		out = append(out, LanguageShare{Language: lang, Files: n, Percent: 100 * float64(n) / float64(total)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Files != out[j].Files {
+			return out[i].Files > out[j].Files
+		}
+		return out[i].Language < out[j].Language
+	})
+	return out
+}
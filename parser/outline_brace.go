@@ -0,0 +1,412 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// maskOptions describes the comments and string literals of a language.
+type maskOptions struct {
+	lineComment   string
+	blockComments bool
+	quotes        string
+	tripleQuotes  bool
+	// rust makes ' start a char literal only where one parses, since it
+	// also starts lifetimes, and recognises raw strings.
+	rust bool
+}
+
+var (
+	rustChar      = regexp.MustCompile(`^'(?:\\(?:x[0-9a-fA-F]{2}|u\{[0-9a-fA-F]+\}|.)|[^\\'\n])'`)
+	rustRawString = regexp.MustCompile(`^b?r(#*)"`)
+)
+
+// mask blanks out the comments and string literals in src, keeping line
+// breaks, so that declarations and braces can be matched in what is left.
+func mask(src string, o maskOptions) string {
+	b := []byte(src)
+	out := []byte(src)
+	blank := func(from, to int) int {
+		to = min(to, len(out))
+		for k := from; k < to; k++ {
+			if out[k] != '\n' {
+				out[k] = ' '
+			}
+		}
+		return to
+	}
+	// closing returns the end of the text from i that ends with delim.
+	closing := func(i int, delim []byte) int {
+		if end := bytes.Index(b[i:], delim); end >= 0 {
+			return i + end + len(delim)
+		}
+		return len(b)
+	}
+	for i := 0; i < len(b); {
+		rest := b[i:]
+		switch {
+		case o.lineComment != "" && bytes.HasPrefix(rest, []byte(o.lineComment)):
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			i = blank(i, i+end)
+		case o.blockComments && bytes.HasPrefix(rest, []byte("/*")):
+			i = blank(i, closing(i+2, []byte("*/")))
+		case o.tripleQuotes && (bytes.HasPrefix(rest, []byte(`"""`)) || bytes.HasPrefix(rest, []byte(`'''`))):
+			i = blank(i, closing(i+3, rest[:3]))
+		case o.rust && (i == 0 || !isIdentByte(b[i-1])) && rustRawString.Match(rest):
+			m := rustRawString.FindSubmatch(rest)
+			i = blank(i, closing(i+len(m[0]), append([]byte{'"'}, m[1]...)))
+		case o.rust && b[i] == '\'':
+			if m := rustChar.Find(rest); m != nil {
+				i = blank(i, i+len(m))
+			} else {
+				i++
+			}
+		case strings.IndexByte(o.quotes, b[i]) >= 0:
+			q, j := b[i], i+1
+			for j < len(b) && b[j] != q && (b[j] != '\n' || q == '`') {
+				if b[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(b) && b[j] == q {
+				j++
+			}
+			i = blank(i, j)
+		default:
+			i++
+		}
+	}
+	return string(out)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// declRule recognises one kind of declaration by its first line.
+type declRule struct {
+	// kind is the kind of declaration; when empty it is taken from the
+	// "kind" submatch.
+	kind string
+	// re matches the declaration's first line with comments and strings
+	// masked; the "name" submatch is its name unless name is set.
+	re   *regexp.Regexp
+	name string
+	// Container declarations hold others; member rules only apply
+	// directly inside one.
+	container bool
+	member    bool
+	// skip, if set, reports whether a match called name inside a parent
+	// of kind parentKind ("" at the top level) is not a declaration.
+	skip func(name, parentKind string) bool
+}
+
+// braceLanguage outlines a language whose blocks are delimited by braces,
+// using regular expressions for declarations and brace matching for their
+// extent.
+type braceLanguage struct {
+	name       string
+	extensions []string
+	mask       maskOptions
+	// imports match whole lines; the first submatch is the import.
+	imports []*regexp.Regexp
+	rules   []declRule
+	// attribute matches lines above a declaration that belong to it,
+	// such as decorators and attributes.
+	attribute *regexp.Regexp
+	// terminated languages always end a declaration with a body or a
+	// semicolon; otherwise a line that does not continue ends it too.
+	terminated bool
+}
+
+func (l *braceLanguage) Language() string     { return l.name }
+func (l *braceLanguage) Extensions() []string { return l.extensions }
+
+// methodParents are the kinds of declaration whose functions are methods.
+var methodParents = map[string]bool{
+	"class": true, "interface": true, "trait": true, "impl": true, "contract": true, "library": true,
+}
+
+func (l *braceLanguage) Extract(src []byte) ([]string, []Decl, error) {
+	lines := strings.Split(string(src), "\n")
+	masked := strings.Split(mask(string(src), l.mask), "\n")
+	depth := make([]int, len(masked))
+	d := 0
+	for i, line := range masked {
+		depth[i] = d
+		d = max(0, d+strings.Count(line, "{")-strings.Count(line, "}"))
+	}
+
+	type scope struct {
+		name, kind string
+		depth, end int
+	}
+	var stack []scope
+	var imports []string
+	var decls []Decl
+	for i, line := range masked {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, re := range l.imports {
+			if m := re.FindStringSubmatch(lines[i]); m != nil {
+				imports = append(imports, strings.TrimSpace(m[1]))
+			}
+		}
+		for len(stack) > 0 && i > stack[len(stack)-1].end {
+			stack = stack[:len(stack)-1]
+		}
+		var parent *scope
+		if len(stack) > 0 {
+			parent = &stack[len(stack)-1]
+		}
+		// Only look at declarations at the top level or directly inside a
+		// container, not inside function bodies.
+		if (parent == nil && depth[i] != 0) || (parent != nil && depth[i] != parent.depth+1) {
+			continue
+		}
+		for _, r := range l.rules {
+			if r.member && parent == nil {
+				continue
+			}
+			loc := r.re.FindStringSubmatchIndex(line)
+			if loc == nil {
+				continue
+			}
+			name, kind := r.name, r.kind
+			if name == "" {
+				k := r.re.SubexpIndex("name")
+				name = line[loc[2*k]:loc[2*k+1]]
+			}
+			if kind == "" {
+				k := r.re.SubexpIndex("kind")
+				kind = line[loc[2*k]:loc[2*k+1]]
+			}
+			if r.skip != nil {
+				parentKind := ""
+				if parent != nil {
+					parentKind = parent.kind
+				}
+				if r.skip(name, parentKind) {
+					continue
+				}
+			}
+			end := l.blockEnd(masked, i, loc[1])
+			decl := Decl{Name: name, Kind: kind, Signature: strings.TrimSpace(lines[i]), StartLine: i + 1, EndLine: end + 1}
+			if parent != nil {
+				decl.Name, decl.Parent = parent.name+"."+name, parent.name
+				if kind == "function" && methodParents[parent.kind] {
+					decl.Kind = "method"
+				}
+			}
+			for start := i - 1; start >= 0 && l.leading(lines[start], masked[start]); start-- {
+				decl.StartLine = start + 1
+			}
+			decls = append(decls, decl)
+			if r.container {
+				stack = append(stack, scope{name: decl.Name, kind: kind, depth: depth[i], end: end})
+			}
+			break
+		}
+	}
+	return imports, decls, nil
+}
+
+// leading reports whether a line directly above a declaration belongs to
+// it: a comment, decorator or attribute.
+func (l *braceLanguage) leading(line, masked string) bool {
+	if strings.TrimSpace(line) == "" {
+		return false
+	}
+	return strings.TrimSpace(masked) == "" || (l.attribute != nil && l.attribute.MatchString(line))
+}
+
+// maxHeaderLines bounds the search for the body of a declaration.
+const maxHeaderLines = 50
+
+// blockEnd returns the last line of the declaration that starts on line i
+// and whose first line matched up to col: the line of the brace closing its
+// body, or of whatever ends a declaration without one.
+func (l *braceLanguage) blockEnd(masked []string, i, col int) int {
+	parens := 0
+	for j := i; j < len(masked) && j < i+maxHeaderLines; j++ {
+		line := masked[j]
+		from := 0
+		if j == i {
+			from = col
+		}
+		for k := from; k < len(line); k++ {
+			switch line[k] {
+			case '(', '[':
+				parens++
+			case ')', ']':
+				parens--
+			case '{':
+				if parens <= 0 {
+					return closingBrace(masked, j, k)
+				}
+			case ';':
+				if parens <= 0 {
+					return j
+				}
+			}
+		}
+		if !l.terminated && parens <= 0 && !continues(masked, j) {
+			return j
+		}
+	}
+	return i
+}
+
+// continues reports whether the declaration on line j goes on to the next
+// line.
+func continues(masked []string, j int) bool {
+	if t := strings.TrimSpace(masked[j]); t == "" || strings.ContainsAny(t[len(t)-1:], ",(=>:|&+-.") {
+		return true
+	}
+	for _, next := range masked[j+1:] {
+		n := strings.TrimSpace(next)
+		if n == "" {
+			continue
+		}
+		for _, p := range []string{"{", ".", "|", "&", "=", ":", "?", "extends", "implements"} {
+			if strings.HasPrefix(n, p) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// closingBrace returns the line of the brace matching the one at line j,
+// column k.
+func closingBrace(masked []string, j, k int) int {
+	d := 0
+	for ; j < len(masked); j++ {
+		for ; k < len(masked[j]); k++ {
+			switch masked[j][k] {
+			case '{':
+				d++
+			case '}':
+				d--
+				if d == 0 {
+					return j
+				}
+			}
+		}
+		k = 0
+	}
+	return len(masked) - 1
+}
+
+var jsMask = maskOptions{lineComment: "//", blockComments: true, quotes: "\"'`"}
+
+var jsImports = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*import\s+(?:type\s+)?(?:[^'"]*?\s+from\s+)?["']([^"']+)["']`),
+	regexp.MustCompile(`^\s*(?:\}\s*from|export\s+[^'"]*?\s+from)\s+["']([^"']+)["']`),
+	regexp.MustCompile(`\brequire\(\s*["']([^"']+)["']\s*\)`),
+}
+
+var jsRules = []declRule{
+	{kind: "class", container: true, re: regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`)},
+	{kind: "interface", container: true, re: regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?interface\s+(?P<name>[\w$]+)`)},
+	{kind: "module", container: true, re: regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?(?:namespace|module)\s+(?P<name>[\w$.]+)`)},
+	{kind: "enum", re: regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`)},
+	{kind: "type", re: regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?type\s+(?P<name>[\w$]+)\s*(?:<.*?>)?\s*=`)},
+	{kind: "function", re: regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`)},
+	{kind: "function", re: regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|(?:\([^)]*\)|[\w$]+)\s*(?::[^=]+)?=>|\(\s*$)`)},
+	{kind: "function", member: true, skip: jsNotMethod, re: regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|readonly|abstract|override|async|declare|get|set)\s+)*\*?\s*(?P<name>#?[\w$]+)\s*\??\s*(?:<[^>]*>)?\s*\(`)},
+}
+
+// jsStatements are the keywords that look like method declarations at the
+// start of a statement, such as "if (x) {".
+var jsStatements = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"function": true, "new": true, "typeof": true, "else": true, "do": true, "try": true,
+	"super": true, "with": true, "delete": true, "await": true, "yield": true, "throw": true,
+	"void": true,
+}
+
+// jsNotMethod reports whether what looks like a method is a statement in a
+// namespace body or a construct signature in an interface. Class members
+// may be called anything, "delete" and "with" included.
+func jsNotMethod(name, parentKind string) bool {
+	switch parentKind {
+	case "module":
+		return jsStatements[name]
+	case "interface":
+		return name == "new"
+	}
+	return false
+}
+
+// rustVis matches Rust visibility modifiers.
+const rustVis = `(?:pub(?:\s*\([^)]*\))?\s+)?`
+
+var braceLanguages = []*braceLanguage{
+	{
+		name:       "TypeScript",
+		extensions: []string{".ts", ".tsx", ".mts", ".cts"},
+		mask:       jsMask,
+		imports:    jsImports,
+		rules:      jsRules,
+		attribute:  regexp.MustCompile(`^\s*@[\w$.]+`),
+	},
+	{
+		name:       "JavaScript",
+		extensions: []string{".js", ".jsx", ".mjs", ".cjs"},
+		mask:       jsMask,
+		imports:    jsImports,
+		rules:      jsRules,
+		attribute:  regexp.MustCompile(`^\s*@[\w$.]+`),
+	},
+	{
+		name:       "Rust",
+		extensions: []string{".rs"},
+		mask:       maskOptions{lineComment: "//", blockComments: true, quotes: `"`, rust: true},
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*` + rustVis + `use\s+([^;]+);?`),
+			regexp.MustCompile(`^\s*extern\s+crate\s+(\w+)`),
+			regexp.MustCompile(`^\s*` + rustVis + `mod\s+(\w+)\s*;`),
+		},
+		rules: []declRule{
+			{kind: "function", re: regexp.MustCompile(`^\s*` + rustVis + `(?:default\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+)?fn\s+(?P<name>\w+)`)},
+			{kind: "struct", re: regexp.MustCompile(`^\s*` + rustVis + `struct\s+(?P<name>\w+)`)},
+			{kind: "enum", re: regexp.MustCompile(`^\s*` + rustVis + `enum\s+(?P<name>\w+)`)},
+			{kind: "trait", container: true, re: regexp.MustCompile(`^\s*` + rustVis + `(?:unsafe\s+)?trait\s+(?P<name>\w+)`)},
+			{kind: "impl", container: true, re: regexp.MustCompile(`^\s*(?:unsafe\s+)?impl\b(?:\s*<[^{]*?>)?\s+(?:[\w:<>, &']+?\s+for\s+)?(?P<name>[\w:]+)`)},
+			{kind: "module", container: true, re: regexp.MustCompile(`^\s*` + rustVis + `mod\s+(?P<name>\w+)\s*\{`)},
+			{kind: "type", re: regexp.MustCompile(`^\s*` + rustVis + `type\s+(?P<name>\w+)`)},
+			{kind: "macro", re: regexp.MustCompile(`^\s*macro_rules!\s*(?P<name>\w+)`)},
+		},
+		attribute:  regexp.MustCompile(`^\s*#!?\[`),
+		terminated: true,
+	},
+	{
+		name:       "Solidity",
+		extensions: []string{".sol"},
+		mask:       maskOptions{lineComment: "//", blockComments: true, quotes: `"'`},
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*import\s+(?:[^'"]*?\s+from\s+)?["']([^"']+)["']`),
+		},
+		rules: []declRule{
+			{container: true, re: regexp.MustCompile(`^\s*(?:abstract\s+)?(?P<kind>contract|interface|library)\s+(?P<name>\w+)`)},
+			{kind: "function", re: regexp.MustCompile(`^\s*function\s+(?P<name>\w+)`)},
+			{kind: "function", name: "constructor", re: regexp.MustCompile(`^\s*constructor\s*\(`)},
+			{kind: "function", name: "fallback", re: regexp.MustCompile(`^\s*fallback\s*\(`)},
+			{kind: "function", name: "receive", re: regexp.MustCompile(`^\s*receive\s*\(`)},
+			{kind: "modifier", re: regexp.MustCompile(`^\s*modifier\s+(?P<name>\w+)`)},
+			{kind: "event", re: regexp.MustCompile(`^\s*event\s+(?P<name>\w+)`)},
+			{kind: "error", re: regexp.MustCompile(`^\s*error\s+(?P<name>\w+)`)},
+			{kind: "struct", re: regexp.MustCompile(`^\s*struct\s+(?P<name>\w+)`)},
+			{kind: "enum", re: regexp.MustCompile(`^\s*enum\s+(?P<name>\w+)`)},
+		},
+		terminated: true,
+	},
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// goExtractor outlines Go files with the standard parser.
+type goExtractor struct{}
+
+func (goExtractor) Language() string     { return "Go" }
+func (goExtractor) Extensions() []string { return []string{".go"} }
+
+func (goExtractor) Extract(src []byte) ([]string, []Decl, error) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", src, goparser.ParseComments|goparser.SkipObjectResolution)
+	if f == nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(string(src), "\n")
+	var imports []string
+	for _, spec := range f.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, path)
+		}
+	}
+	var decls []Decl
+	add := func(name, kind, parent string, node ast.Node, doc *ast.CommentGroup) {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		decls = append(decls, Decl{
+			Name: name, Kind: kind, Parent: parent,
+			Signature: strings.TrimSpace(lines[fset.Position(node.Pos()).Line-1]),
+			StartLine: fset.Position(start).Line, EndLine: fset.Position(node.End()).Line,
+		})
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				add(recv+"."+d.Name.Name, "method", recv, d, d.Doc)
+			} else {
+				add(d.Name.Name, "function", "", d, d.Doc)
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				kind := "type"
+				switch ts.Type.(type) {
+				case *ast.StructType:
+					kind = "struct"
+				case *ast.InterfaceType:
+					kind = "interface"
+				}
+				if d.Lparen.IsValid() {
+					add(ts.Name.Name, kind, "", ts, ts.Doc)
+				} else {
+					add(ts.Name.Name, kind, "", d, d.Doc)
+				}
+			}
+		}
+	}
+	// A syntax error still leaves the declarations before it.
+	return imports, decls, nil
+}
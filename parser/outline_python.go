@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	pyDecl       = regexp.MustCompile(`^(\s*)(?:async\s+)?(def|class)\s+(\w+)`)
+	pyImport     = regexp.MustCompile(`^\s*import\s+(.+)`)
+	pyFromImport = regexp.MustCompile(`^\s*from\s+(\S+)\s+import\b`)
+	pyAlias      = regexp.MustCompile(`\s+as\s+\w+$`)
+)
+
+// pythonExtractor outlines Python files by indentation.
+type pythonExtractor struct{}
+
+func (pythonExtractor) Language() string     { return "Python" }
+func (pythonExtractor) Extensions() []string { return []string{".py", ".pyi"} }
+
+func (pythonExtractor) Extract(src []byte) ([]string, []Decl, error) {
+	lines := strings.Split(string(src), "\n")
+	masked := strings.Split(mask(string(src), maskOptions{lineComment: "#", quotes: `"'`, tripleQuotes: true}), "\n")
+
+	type scope struct {
+		name, kind string
+		indent     int
+		end        int
+	}
+	var stack []scope
+	var imports []string
+	var decls []Decl
+	for i, line := range masked {
+		if m := pyFromImport.FindStringSubmatch(line); m != nil {
+			imports = append(imports, m[1])
+		} else if m := pyImport.FindStringSubmatch(line); m != nil {
+			for _, name := range strings.Split(m[1], ",") {
+				imports = append(imports, pyAlias.ReplaceAllString(strings.TrimSpace(name), ""))
+			}
+		}
+
+		m := pyDecl.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent := indentWidth(m[1])
+		for len(stack) > 0 && (i > stack[len(stack)-1].end || indent <= stack[len(stack)-1].indent) {
+			stack = stack[:len(stack)-1]
+		}
+		kind, name, parent := "function", m[3], ""
+		if m[2] == "class" {
+			kind = "class"
+		}
+		if len(stack) > 0 {
+			p := stack[len(stack)-1]
+			// Functions nested in functions are part of their body.
+			if p.kind != "class" {
+				continue
+			}
+			parent, name = p.name, p.name+"."+name
+			if kind == "function" {
+				kind = "method"
+			}
+		}
+		end := pythonBlockEnd(masked, i, indent)
+		decl := Decl{Name: name, Kind: kind, Parent: parent, Signature: strings.TrimSpace(lines[i]), StartLine: i + 1, EndLine: end + 1}
+		for start := i - 1; start >= 0; start-- {
+			t := strings.TrimSpace(lines[start])
+			if t == "" || !(strings.HasPrefix(t, "@") || strings.HasPrefix(t, "#")) {
+				break
+			}
+			decl.StartLine = start + 1
+		}
+		decls = append(decls, decl)
+		stack = append(stack, scope{name: name, kind: kind, indent: indent, end: end})
+	}
+	return imports, decls, nil
+}
+
+// pythonBlockEnd returns the last line of the block whose header starts on
+// line i at the given indentation.
+func pythonBlockEnd(masked []string, i, indent int) int {
+	// The header ends where its brackets close.
+	header, parens := i, 0
+	for ; header < len(masked); header++ {
+		parens += strings.Count(masked[header], "(") + strings.Count(masked[header], "[") -
+			strings.Count(masked[header], ")") - strings.Count(masked[header], "]")
+		if parens <= 0 {
+			break
+		}
+	}
+	end := min(header, len(masked)-1)
+	for j := end + 1; j < len(masked); j++ {
+		if strings.TrimSpace(masked[j]) == "" {
+			continue
+		}
+		if indentWidth(masked[j]) <= indent {
+			break
+		}
+		end = j
+	}
+	return end
+}
+
+func indentWidth(line string) int {
+	w := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			w++
+		case '\t':
+			w += 8 - w%8
+		default:
+			return w
+		}
+	}
+	return w
+}
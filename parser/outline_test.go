@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		src     string
+		imports []string
+		decls   []string // "kind name start-end"
+	}{
+		{
+			name: "rust constructors and literals",
+			path: "lib.rs",
+			src: `use std::fmt;
+
+/// A stack.
+#[derive(Debug)]
+pub struct S<'a> {
+    items: Vec<&'a str>,
+}
+
+impl<'a> S<'a> {
+    pub fn new() -> Self {
+        let open = '{';
+        let raw = r#"}" {"#;
+        S { items: vec![] }
+    }
+
+    fn with(mut self, item: &'a str) -> Self {
+        self.items.push(item);
+        self
+    }
+}
+
+fn main() {}
+`,
+			imports: []string{"std::fmt"},
+			decls: []string{
+				"struct S 3-7",
+				"impl S 9-20",
+				"method S.new 10-14",
+				"method S.with 16-19",
+				"function main 22-22",
+			},
+		},
+		{
+			name: "typescript members named like keywords",
+			path: "store.ts",
+			src: "import { Item } from './item';\n" +
+				"\n" +
+				"export class Store {\n" +
+				"  delete(id: string) {\n" +
+				"    const s = `${id} {`;\n" +
+				"  }\n" +
+				"\n" +
+				"  with(x: Item): Store {\n" +
+				"    return this;\n" +
+				"  }\n" +
+				"}\n" +
+				"\n" +
+				"interface Factory {\n" +
+				"  new (name: string): Store;\n" +
+				"  create(name: string): Store;\n" +
+				"}\n" +
+				"\n" +
+				"namespace Setup {\n" +
+				"  if (ready) {\n" +
+				"  }\n" +
+				"  export function init() {}\n" +
+				"}\n",
+			imports: []string{"./item"},
+			decls: []string{
+				"class Store 3-11",
+				"method Store.delete 4-6",
+				"method Store.with 8-10",
+				"interface Factory 13-16",
+				"method Factory.create 15-15",
+				"module Setup 18-22",
+				"function Setup.init 21-21",
+			},
+		},
+		{
+			name: "javascript functions and template strings",
+			path: "app.js",
+			src: "const http = require('http');\n" +
+				"\n" +
+				"// Handles a request.\n" +
+				"async function handle(req) {\n" +
+				"  return `}`;\n" +
+				"}\n" +
+				"\n" +
+				"const add = (a, b) => a + b;\n" +
+				"\n" +
+				"export default function* ids() {\n" +
+				"  yield '{';\n" +
+				"}\n",
+			imports: []string{"http"},
+			decls: []string{
+				"function handle 3-6",
+				"function add 8-8",
+				"function ids 10-12",
+			},
+		},
+		{
+			name: "python classes and docstrings",
+			path: "tool.py",
+			src: `import os, sys as system
+from pathlib import Path
+
+
+class Tool:
+    """A tool.
+
+    def not_a_method(self):
+    """
+
+    @property
+    def name(self):
+        return "x"
+
+
+async def run():
+    pass
+`,
+			imports: []string{"os", "sys", "pathlib"},
+			decls: []string{
+				"class Tool 5-13",
+				"method Tool.name 11-13",
+				"function run 16-17",
+			},
+		},
+		{
+			name: "solidity contract",
+			path: "Token.sol",
+			src: `import "./IERC20.sol";
+
+contract Token {
+    event Transfer(address from, address to);
+
+    constructor() {
+        string memory s = "}";
+    }
+
+    function transfer(address to) public {
+    }
+}
+`,
+			imports: []string{"./IERC20.sol"},
+			decls: []string{
+				"contract Token 3-12",
+				"event Token.Transfer 4-4",
+				"method Token.constructor 6-8",
+				"method Token.transfer 10-11",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ExtractorFor(tt.path)
+			if e == nil {
+				t.Fatalf("no extractor for %s", tt.path)
+			}
+			imports, decls, err := e.Extract([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range decls {
+				got = append(got, fmt.Sprintf("%s %s %d-%d", d.Kind, d.Name, d.StartLine, d.EndLine))
+			}
+			if !reflect.DeepEqual(imports, tt.imports) {
+				t.Errorf("imports = %q, want %q", imports, tt.imports)
+			}
+			if !reflect.DeepEqual(got, tt.decls) {
+				t.Errorf("decls =\n%q\nwant\n%q", got, tt.decls)
+			}
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		o    maskOptions
+		want string
+	}{
+		{"line comment", "a // {\nb", jsMask, "a     \nb"},
+		{"block comment keeps lines", "a /* {\n} */ b", jsMask, "a     \n     b"},
+		{"escaped quote", `x = "a\"{" + y`, jsMask, `x =        + y`},
+		{"template string spans lines", "`{\n}` z", jsMask, "  \n   z"},
+		{"rust char literal", "let c = '{';", maskOptions{quotes: `"`, rust: true}, "let c =    ;"},
+		{"rust lifetime is not a char", "fn f<'a>(x: &'a str) {", maskOptions{quotes: `"`, rust: true}, "fn f<'a>(x: &'a str) {"},
+		{"rust raw string", `let s = r#"a "} b"#;`, maskOptions{quotes: `"`, rust: true}, `let s =            ;`},
+		{"python triple quotes", "x = '''{\n'''\ny", maskOptions{lineComment: "#", quotes: `"'`, tripleQuotes: true}, "x =     \n   \ny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mask(tt.src, tt.o); got != tt.want {
+				t.Errorf("mask(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
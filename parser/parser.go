@@ -1,4 +1,3 @@
+// Package parser extracts declarations from source code so that prompts can
+// be built from individual symbols instead of whole files.
 package parser
-
-// In Go, argument parsing is handled by cobra in cmd/root.go.
-// This file can contain helpers if needed, but is not required for basic CLI.
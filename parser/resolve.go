@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/directory"
+)
+
+// Target is a declaration found by name, in Go or any language with an
+// outline extractor.
+type Target struct {
+	// ID is the Go symbol ID, or the file relative to the project root
+	// and the declaration name, e.g. "web/api.ts:Client.fetch".
+	ID        string
+	Language  string
+	File      string
+	StartLine int
+	EndLine   int
+	// Context describes what the declaration depends on, for a prompt.
+	Context string
+}
+
+// Source returns the text of the declaration.
+func (t *Target) Source() (string, error) {
+	return readLines(t.File, t.StartLine, t.EndLine)
+}
+
+func readLines(file string, start, end int) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	if start < 1 || end > len(lines) || start > end {
+		return "", fmt.Errorf("%s has changed since it was parsed", file)
+	}
+	return strings.Join(lines[start-1:end], "\n"), nil
+}
+
+// Resolve finds the one declaration called name in the project at root, or
+// in file when it is not empty. Go declarations are looked up in the symbol
+// table of their module, so their context lists what they call; others
+// are found in the outlines of the project's files, with the rest of the
+// file's outline as context.
+func Resolve(root, name, file string) (*Target, error) {
+	var found []*Target
+	if file == "" || isGo(file) {
+		dir := root
+		if file != "" {
+			dir = filepath.Dir(file)
+		}
+		if mod := FindGoModule(dir); mod != "" {
+			dir = mod
+		}
+		if table, err := LoadGo(dir); err == nil {
+			matches := table.Lookup(name)
+			if file != "" {
+				if sym, err := table.Find(name, file); err == nil {
+					matches = []*Symbol{sym}
+				} else {
+					return nil, err
+				}
+			}
+			for _, s := range matches {
+				found = append(found, &Target{
+					ID: s.ID, Language: "Go", File: s.File, StartLine: s.StartLine, EndLine: s.EndLine,
+					Context: table.Context(s),
+				})
+			}
+		} else if file != "" {
+			return nil, err
+		}
+	}
+
+	if file == "" {
+		paths, err := directory.SelectFiles(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			if ExtractorFor(p) != nil && !isGo(p) {
+				found = append(found, outlineTargets(root, filepath.Join(root, p), name)...)
+			}
+		}
+	} else if !isGo(file) {
+		found = outlineTargets(root, file, name)
+	}
+
+	switch len(found) {
+	case 0:
+		where := root
+		if file != "" {
+			where = file
+		}
+		return nil, fmt.Errorf("no symbol %q in %s", name, where)
+	case 1:
+		return found[0], nil
+	}
+	ids := make([]string, len(found))
+	for i, t := range found {
+		ids[i] = t.ID
+	}
+	return nil, fmt.Errorf("%q is ambiguous, use one of: %s", name, strings.Join(ids, ", "))
+}
+
+func isGo(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".go")
+}
+
+// outlineTargets returns the declarations called name in file.
+func outlineTargets(root, file, name string) []*Target {
+	// name may be qualified with the file, as in the IDs of the targets.
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		rel = file
+	}
+	rel = filepath.ToSlash(rel)
+	if f, rest, ok := strings.Cut(name, ":"); ok {
+		if f != rel {
+			return nil
+		}
+		name = rest
+	}
+	o, err := OutlineFile(file)
+	if err != nil {
+		return nil
+	}
+	var out []*Target
+	for _, d := range o.Lookup(name) {
+		out = append(out, &Target{
+			ID: rel + ":" + d.Name, Language: o.Language, File: file, StartLine: d.StartLine, EndLine: d.EndLine,
+			Context: o.context(rel, d),
+		})
+	}
+	return out
+}
+
+// context renders the rest of the outline around d for a prompt.
+func (o *Outline) context(rel string, d Decl) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Outline of %s:\n\n", rel)
+	if len(o.Imports) > 0 {
+		b.WriteString("imports: " + strings.Join(o.Imports, ", ") + "\n")
+	}
+	n := 0
+	for _, other := range o.Decls {
+		if other.StartLine == d.StartLine || (other.StartLine > d.StartLine && other.EndLine <= d.EndLine) {
+			continue
+		}
+		fmt.Fprintf(&b, "%d: %s\n", other.StartLine, other.Signature)
+		n++
+	}
+	if n == 0 && len(o.Imports) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(b.String())
+}
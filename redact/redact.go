@@ -0,0 +1,252 @@
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Rule detects one kind of sensitive value. When Group is non-zero only that
+// submatch is masked, so surrounding context (e.g. the variable name) stays.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Group   int
+	// Check optionally filters candidate matches (used for entropy checks).
+	Check func(string) bool
+}
+
+// Finding summarizes what a rule masked.
+type Finding struct {
+	Rule         string   `json:"rule"`
+	Count        int      `json:"count"`
+	Placeholders []string `json:"placeholders"`
+}
+
+// Redactor replaces sensitive values with stable placeholders and can put
+// them back. The same value always maps to the same placeholder for the
+// lifetime of the Redactor.
+type Redactor struct {
+	rules []Rule
+
+	mu            sync.Mutex
+	byValue       map[string]string
+	byPlaceholder map[string]string
+	counters      map[string]int
+	findings      map[string]*Finding
+}
+
+// DefaultRules returns the built-in detectors, ordered from most to least
+// specific so that e.g. a GitHub token is not reported as generic entropy.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "private_key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+		{Name: "aws_access_key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{Name: "aws_secret_key", Pattern: regexp.MustCompile(`(?i)aws[a-z_]*(?:secret|private)[a-z_]*['"]?\s*[:=]\s*['"]?([A-Za-z0-9/+=]{40})`), Group: 1},
+		{Name: "github_token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+		{Name: "openai_key", Pattern: regexp.MustCompile(`\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{20,}`)},
+		{Name: "url_credentials", Pattern: regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`), Group: 1},
+		{Name: "env_value", Pattern: regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?["']?[A-Za-z0-9_]*(?i:key|secret|token|passw(?:or)?d|pwd|credentials?|auth)[A-Za-z0-9_]*["']?[ \t]*[:=][ \t]*["']?([^\s"'#=,][^\s"'#,]*)`), Group: 1},
+		{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+		{Name: "high_entropy", Pattern: regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`), Check: looksRandom},
+	}
+}
+
+// New builds a Redactor from the default rules plus custom regular
+// expressions (from config). Custom patterns are reported as "custom_N".
+func New(custom []string) (*Redactor, error) {
+	rules := DefaultRules()
+	for i, pat := range custom {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom redaction pattern %q: %w", pat, err)
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		// Custom patterns are explicit, so they run before the generic rules.
+		rules = append([]Rule{{Name: fmt.Sprintf("custom_%d", i+1), Pattern: re, Group: group}}, rules...)
+	}
+	return &Redactor{
+		rules:         rules,
+		byValue:       map[string]string{},
+		byPlaceholder: map[string]string{},
+		counters:      map[string]int{},
+		findings:      map[string]*Finding{},
+	}, nil
+}
+
+// Redact returns text with every detected secret replaced by a placeholder.
+func (r *Redactor) Redact(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rule := range r.rules {
+		text = r.apply(rule, text)
+	}
+	return text
+}
+
+func (r *Redactor) apply(rule Rule, text string) string {
+	matches := rule.Pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if rule.Group > 0 && len(m) > 2*rule.Group+1 && m[2*rule.Group] >= 0 {
+			start, end = m[2*rule.Group], m[2*rule.Group+1]
+		}
+		value := text[start:end]
+		if isPlaceholder(value) || (rule.Check != nil && !rule.Check(value)) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(r.placeholderFor(rule.Name, value))
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (r *Redactor) placeholderFor(rule, value string) string {
+	f, ok := r.findings[rule]
+	if !ok {
+		f = &Finding{Rule: rule}
+		r.findings[rule] = f
+	}
+	f.Count++
+	if ph, ok := r.byValue[value]; ok {
+		return ph
+	}
+	r.counters[rule]++
+	ph := fmt.Sprintf("[[REDACTED_%s_%d]]", strings.ToUpper(rule), r.counters[rule])
+	r.byValue[value] = ph
+	r.byPlaceholder[ph] = value
+	f.Placeholders = append(f.Placeholders, ph)
+	return ph
+}
+
+var placeholderRe = regexp.MustCompile(`\[\[REDACTED_[A-Z0-9_]+_\d+\]\]`)
+
+func isPlaceholder(s string) bool {
+	return placeholderRe.MatchString(s)
+}
+
+// Restore replaces placeholders in text (typically model output) with the
+// original values. Unknown placeholders are left untouched.
+func (r *Redactor) Restore(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return placeholderRe.ReplaceAllStringFunc(text, func(ph string) string {
+		if value, ok := r.byPlaceholder[ph]; ok {
+			return value
+		}
+		return ph
+	})
+}
+
+// maxPlaceholderLen bounds how much streamed output is held back while it
+// might be the start of a placeholder.
+const maxPlaceholderLen = 64
+
+// StreamRestorer restores placeholders in output that arrives in chunks,
+// where a placeholder may be split across chunks.
+type StreamRestorer struct {
+	r       *Redactor
+	emit    func(string)
+	pending string
+}
+
+// NewStreamRestorer returns a StreamRestorer that passes restored text to
+// emit.
+func (r *Redactor) NewStreamRestorer(emit func(string)) *StreamRestorer {
+	return &StreamRestorer{r: r, emit: emit}
+}
+
+// Write restores and emits chunk, holding back any trailing text that
+// could be an unfinished placeholder.
+func (s *StreamRestorer) Write(chunk string) {
+	s.pending += chunk
+	cut := len(s.pending)
+	if i := strings.LastIndex(s.pending, "[["); i >= 0 && !strings.Contains(s.pending[i:], "]]") && cut-i < maxPlaceholderLen {
+		cut = i
+	} else if strings.HasSuffix(s.pending, "[") {
+		cut--
+	}
+	if cut > 0 {
+		s.emit(s.r.Restore(s.pending[:cut]))
+		s.pending = s.pending[cut:]
+	}
+}
+
+// Flush emits whatever is still held back.
+func (s *StreamRestorer) Flush() {
+	if s.pending != "" {
+		s.emit(s.r.Restore(s.pending))
+		s.pending = ""
+	}
+}
+
+// Report returns what has been masked so far, sorted by rule name.
+func (r *Redactor) Report() []Finding {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Finding
+	for _, f := range r.findings {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rule < out[j].Rule })
+	return out
+}
+
+// Summary renders findings as a one-line human readable report.
+func Summary(findings []Finding) string {
+	var parts []string
+	total := 0
+	for _, f := range findings {
+		parts = append(parts, fmt.Sprintf("%s x%d", f.Rule, f.Count))
+		total += f.Count
+	}
+	return fmt.Sprintf("%d value(s) masked: %s", total, strings.Join(parts, ", "))
+}
+
+// looksRandom reports whether s has the character mix and Shannon entropy of
+// a generated secret rather than an identifier, path or hex digest.
+func looksRandom(s string) bool {
+	var lower, upper, digit bool
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	if !(lower && upper && digit) {
+		return false
+	}
+	return entropy(s) > 4.5
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+	n := float64(len(s))
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
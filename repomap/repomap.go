@@ -0,0 +1,379 @@
+// Package repomap summarizes a project for prompts: its files ranked by how
+// often the rest of the project refers to them, each with its key
+// declarations, trimmed to a token budget.
+package repomap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/conversation"
+	"github.com/codeforge-ide/codeforgeai.go/directory"
+	"github.com/codeforge-ide/codeforgeai.go/parser"
+	"github.com/codeforge-ide/codeforgeai.go/utils"
+)
+
+// maxSignature caps the length of a declaration in the map.
+const maxSignature = 120
+
+var contextDisabled atomic.Bool
+
+// SetContextDisabled turns off the repository map in prompts for this
+// process (the --no-context flag).
+func SetContextDisabled(off bool) {
+	contextDisabled.Store(off)
+}
+
+// identifier matches the names counted as references; shorter ones are
+// too ambiguous to count.
+var identifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+
+// Symbol is a declaration shown in the map.
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Parent    string `json:"parent,omitempty"`
+	Signature string `json:"signature"`
+	Line      int    `json:"line"`
+}
+
+// File is the cached outline of one file.
+type File struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Language string    `json:"language,omitempty"`
+	Symbols  []Symbol  `json:"symbols,omitempty"`
+	// Idents are the distinct identifiers the file uses.
+	Idents []string `json:"idents,omitempty"`
+}
+
+// Cache holds the outlines of a project's files, keyed by path relative to
+// Root, so that only changed files are parsed again.
+type Cache struct {
+	Root    string           `json:"root"`
+	Updated time.Time        `json:"updated"`
+	Files   map[string]*File `json:"files"`
+}
+
+// Entry is a file of the map with the number of references to it.
+type Entry struct {
+	Path     string   `json:"path"`
+	Language string   `json:"language,omitempty"`
+	Refs     int      `json:"refs"`
+	Symbols  []Ranked `json:"symbols,omitempty"`
+}
+
+// Ranked is a symbol with the number of other files referring to it.
+type Ranked struct {
+	Symbol
+	Refs int `json:"refs"`
+}
+
+// Path returns the cache file of the project at root.
+func Path(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	dir := filepath.Join(config.DataDir(), "repomap")
+	os.MkdirAll(dir, 0700)
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Load reads the cache of the project at root; a missing or unreadable
+// cache yields an empty one.
+func Load(root string) *Cache {
+	c := &Cache{Root: root, Files: map[string]*File{}}
+	data, err := os.ReadFile(Path(root))
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		slog.Warn("discarding unreadable repo map cache", "error", err)
+		return &Cache{Root: root, Files: map[string]*File{}}
+	}
+	if c.Files == nil {
+		c.Files = map[string]*File{}
+	}
+	return c
+}
+
+// Save writes the cache atomically.
+func (c *Cache) Save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	path := Path(c.Root)
+	return utils.WriteFileAtomic(path, data, 0600)
+}
+
+// Update brings the cache of the project at root up to date, outlining only
+// files whose size or modification time changed, and saves it if anything
+// did.
+func Update(cfg *config.Config, root string) (*Cache, error) {
+	c := Load(root)
+	paths, err := directory.SelectFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes := int64(cfg.RepoMap.MaxFileKB) * 1024
+	seen := map[string]bool{}
+	dirty := false
+	for _, rel := range paths {
+		if strings.HasSuffix(rel, ".codeforgedit") || filepath.Base(rel) == ".codeforge.json" {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(root, rel))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		seen[rel] = true
+		if old := c.Files[rel]; old != nil && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		dirty = true
+		f := &File{Size: info.Size(), ModTime: info.ModTime(), Language: parser.LanguageFor(rel)}
+		c.Files[rel] = f
+		if parser.ExtractorFor(rel) == nil || info.Size() > maxBytes {
+			continue
+		}
+		if err := f.outline(filepath.Join(root, rel)); err != nil {
+			slog.Debug("could not outline file", "file", rel, "error", err)
+		}
+	}
+	for rel := range c.Files {
+		if !seen[rel] {
+			delete(c.Files, rel)
+			dirty = true
+		}
+	}
+	if dirty {
+		c.Updated = time.Now()
+		if err := c.Save(); err != nil {
+			slog.Warn("could not save repo map cache", "error", err)
+		}
+	}
+	return c, nil
+}
+
+func (f *File) outline(path string) error {
+	o, err := parser.OutlineFile(path)
+	if err != nil {
+		return err
+	}
+	for _, d := range o.Decls {
+		sig := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(d.Signature), "{"))
+		if len(sig) > maxSignature {
+			sig = sig[:maxSignature] + "…"
+		}
+		f.Symbols = append(f.Symbols, Symbol{Name: d.Name, Kind: d.Kind, Parent: d.Parent, Signature: sig, Line: d.StartLine})
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	idents := map[string]bool{}
+	for _, id := range identifier.FindAllString(string(src), -1) {
+		idents[id] = true
+	}
+	for id := range idents {
+		f.Idents = append(f.Idents, id)
+	}
+	sort.Strings(f.Idents)
+	return nil
+}
+
+// shortName is a declaration name without its parents.
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// Rank returns every file, the most referenced first. A symbol's references
+// are the other files using its name; a file's are the sum of its symbols'.
+func (c *Cache) Rank() []Entry {
+	defs := map[string][]string{}
+	for rel, f := range c.Files {
+		for _, s := range f.Symbols {
+			defs[shortName(s.Name)] = append(defs[shortName(s.Name)], rel)
+		}
+	}
+	// refs[file][name] counts the files other than file using name.
+	refs := map[string]map[string]int{}
+	for rel, f := range c.Files {
+		for _, id := range f.Idents {
+			for _, def := range defs[id] {
+				if def == rel {
+					continue
+				}
+				if refs[def] == nil {
+					refs[def] = map[string]int{}
+				}
+				refs[def][id]++
+			}
+		}
+	}
+
+	entries := make([]Entry, 0, len(c.Files))
+	for rel, f := range c.Files {
+		e := Entry{Path: rel, Language: f.Language}
+		// A name defined more than once in a file is counted once.
+		counted := map[string]bool{}
+		for _, s := range f.Symbols {
+			n := refs[rel][shortName(s.Name)]
+			e.Symbols = append(e.Symbols, Ranked{Symbol: s, Refs: n})
+			if !counted[shortName(s.Name)] {
+				counted[shortName(s.Name)] = true
+				e.Refs += n
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Refs != b.Refs {
+			return a.Refs > b.Refs
+		}
+		if (len(a.Symbols) > 0) != (len(b.Symbols) > 0) {
+			return len(a.Symbols) > 0
+		}
+		return a.Path < b.Path
+	})
+	return entries
+}
+
+// Render writes entries as a map of at most tokens tokens (estimated).
+// Files come in order, each with its declarations; when a file's do not all
+// fit, the least referenced are left out. Files without declarations are
+// listed by path at the end.
+func Render(entries []Entry, tokens int) string {
+	var b strings.Builder
+	used := 0
+	fits := func(s string) bool {
+		n := conversation.EstimateTokens(s)
+		if used+n > tokens {
+			return false
+		}
+		used += n
+		return true
+	}
+
+	var others []string
+	omitted := 0
+	for _, e := range entries {
+		if len(e.Symbols) == 0 {
+			others = append(others, e.Path)
+			continue
+		}
+		header := e.Path + ":\n"
+		if !fits(header) {
+			omitted++
+			continue
+		}
+		b.WriteString(header)
+		// Pick the most referenced symbols that fit, then show them in
+		// source order.
+		order := make([]int, len(e.Symbols))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return e.Symbols[order[i]].Refs > e.Symbols[order[j]].Refs })
+		keep := make([]bool, len(e.Symbols))
+		for _, i := range order {
+			keep[i] = fits(symbolLine(e.Symbols, nil, i))
+		}
+		for i := range e.Symbols {
+			if keep[i] {
+				b.WriteString(symbolLine(e.Symbols, keep, i))
+			}
+		}
+	}
+	if len(others) > 0 && fits("Other files:\n") {
+		b.WriteString("Other files:\n")
+		for _, p := range others {
+			if !fits("  " + p + "\n") {
+				omitted++
+				continue
+			}
+			b.WriteString("  " + p + "\n")
+		}
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "(%d more files not shown)\n", omitted)
+	}
+	return b.String()
+}
+
+// symbolLine renders symbol i, indented under its parent when the parent
+// is shown in the same file; keep, when not nil, tells which are shown.
+func symbolLine(symbols []Ranked, keep []bool, i int) string {
+	s := symbols[i]
+	indent := "  "
+	for p := s.Parent; p != ""; {
+		found := false
+		for j, other := range symbols {
+			if other.Name == p && other.Line <= s.Line && (keep == nil || keep[j]) {
+				indent += "  "
+				p, found = other.Parent, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return indent + s.Signature + "\n"
+}
+
+// remoteBuild, if set, renders the map from a process that keeps the cache
+// up to date, or returns an error to build it here.
+var remoteBuild func(root string, tokens int) (string, error)
+
+// SetRemote makes Build ask build first. It is set once at startup, when
+// the daemon is running.
+func SetRemote(build func(root string, tokens int) (string, error)) {
+	remoteBuild = build
+}
+
+// Build updates the cache of the project at root and renders its map within
+// tokens tokens.
+func Build(cfg *config.Config, root string, tokens int) (string, error) {
+	if remoteBuild != nil {
+		m, err := remoteBuild(root, tokens)
+		if err == nil {
+			return m, nil
+		}
+		slog.Debug("building repo map in-process", "error", err)
+	}
+	c, err := Update(cfg, root)
+	if err != nil {
+		return "", err
+	}
+	return Render(c.Rank(), tokens), nil
+}
+
+// Context returns the repository map of the project at root formatted for
+// a prompt. It returns "" when the map is turned off or cannot be built,
+// so callers can always use it.
+func Context(cfg *config.Config, root string) string {
+	if contextDisabled.Load() || cfg.RepoMap.Tokens <= 0 {
+		return ""
+	}
+	m, err := Build(cfg, root, cfg.RepoMap.Tokens)
+	if err != nil {
+		slog.Warn("could not build repo map", "error", err)
+		return ""
+	}
+	if strings.TrimSpace(m) == "" {
+		return ""
+	}
+	return "Map of the project (the most referenced files first):\n\n" + strings.TrimRight(m, "\n")
+}
@@ -0,0 +1,241 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+)
+
+// DefaultAgentTimeout is how long the agent keeps secrets after the last request.
+const DefaultAgentTimeout = 15 * time.Minute
+
+// ErrAgentNotRunning is returned when no secret agent is listening.
+var ErrAgentNotRunning = errors.New("secrets agent is not running")
+
+type agentRequest struct {
+	Op   string `json:"op"` // get, list, status, lock
+	Name string `json:"name,omitempty"`
+}
+
+type agentResponse struct {
+	Error  string       `json:"error,omitempty"`
+	Value  string       `json:"value,omitempty"`
+	Names  []string     `json:"names,omitempty"`
+	Status *AgentStatus `json:"status,omitempty"`
+}
+
+// AgentStatus describes a running agent.
+type AgentStatus struct {
+	PID         int           `json:"pid"`
+	Secrets     int           `json:"secrets"`
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	LocksAt     time.Time     `json:"locks_at"`
+}
+
+// AgentSocketPath returns the Unix socket the agent listens on.
+func AgentSocketPath() string {
+	return filepath.Join(config.DataDir(), "agent.sock")
+}
+
+// ServeAgent holds the decrypted vault in memory and answers requests on the
+// agent socket until it is locked or no secret has been read for idle.
+func ServeAgent(v *Vault, idle time.Duration) error {
+	if idle <= 0 {
+		idle = DefaultAgentTimeout
+	}
+	sock := AgentSocketPath()
+	if AgentRunning() {
+		return errors.New("secrets agent is already running")
+	}
+	os.Remove(sock) // stale socket from a previous agent
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+	if err := os.Chmod(sock, 0600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	var mu sync.Mutex
+	locksAt := time.Now().Add(idle)
+	timer := time.AfterFunc(idle, func() { ln.Close() })
+	lock := func() {
+		mu.Lock()
+		for name := range v.entries {
+			delete(v.entries, name)
+		}
+		mu.Unlock()
+		ln.Close()
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				lock()
+				return nil
+			}
+			return err
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			var req agentRequest
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				return
+			}
+			var resp agentResponse
+			// Only using a secret keeps the agent unlocked: status checks
+			// and failed requests do not.
+			used := func() {
+				timer.Reset(idle)
+				locksAt = time.Now().Add(idle)
+			}
+			mu.Lock()
+			switch req.Op {
+			case "get":
+				value, err := v.Get(req.Name)
+				if err != nil {
+					resp.Error = err.Error()
+				} else {
+					used()
+				}
+				resp.Value = value
+			case "list":
+				resp.Names = v.List()
+				used()
+			case "status":
+				resp.Status = &AgentStatus{
+					PID:         os.Getpid(),
+					Secrets:     len(v.entries),
+					IdleTimeout: idle,
+					LocksAt:     locksAt,
+				}
+			case "lock":
+			default:
+				resp.Error = "unknown agent operation: " + req.Op
+			}
+			mu.Unlock()
+			json.NewEncoder(conn).Encode(resp)
+			if req.Op == "lock" {
+				lock()
+			}
+		}(conn)
+	}
+}
+
+// agentCall sends one request to the running agent.
+func agentCall(req agentRequest) (*agentResponse, error) {
+	conn, err := net.DialTimeout("unix", AgentSocketPath(), time.Second)
+	if err != nil {
+		return nil, ErrAgentNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp agentResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		if strings.HasPrefix(resp.Error, ErrNotFound.Error()) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, req.Name)
+		}
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// AgentRunning reports whether an agent is answering on the socket.
+func AgentRunning() bool {
+	_, err := AgentStatusInfo()
+	return err == nil
+}
+
+// AgentGet fetches a named secret from the running agent.
+func AgentGet(name string) (string, error) {
+	resp, err := agentCall(agentRequest{Op: "get", Name: name})
+	if err != nil {
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+// AgentList returns the names of secrets held by the running agent.
+func AgentList() ([]string, error) {
+	resp, err := agentCall(agentRequest{Op: "list"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Names, nil
+}
+
+// AgentStatusInfo returns the status of the running agent.
+func AgentStatusInfo() (*AgentStatus, error) {
+	resp, err := agentCall(agentRequest{Op: "status"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// AgentLock tells the running agent to forget its secrets and exit.
+func AgentLock() error {
+	_, err := agentCall(agentRequest{Op: "lock"})
+	return err
+}
+
+// FromAgent returns the named secret if an unlocked agent holds it, or an
+// empty string otherwise. Providers use it to pick up credentials silently.
+func FromAgent(name string) string {
+	value, err := AgentGet(name)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// StartAgentProcess re-executes the current binary as a detached
+// `secrets agent` process, handing it the vault password over stdin, and
+// waits for its socket to come up.
+func StartAgentProcess(password string, idle time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "secrets", "agent", "--timeout", idle.String())
+	cmd.SysProcAttr = detachedProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdin, password)
+	stdin.Close()
+	cmd.Process.Release()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if AgentRunning() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("secrets agent did not start")
+}
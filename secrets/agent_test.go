@@ -0,0 +1,65 @@
+package secrets
+
+import (
+	"testing"
+	"time"
+)
+
+// serveTestAgent runs an agent holding one secret, returning a channel
+// closed when it stops.
+func serveTestAgent(t *testing.T, idle time.Duration) <-chan struct{} {
+	t.Setenv("HOME", t.TempDir())
+	v := &Vault{entries: map[string]string{"k": "v"}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := ServeAgent(v, idle); err != nil {
+			t.Error(err)
+		}
+	}()
+	for deadline := time.Now().Add(5 * time.Second); !AgentRunning(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not start")
+		}
+	}
+	return done
+}
+
+func TestAgentIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		poll func() error
+		// locks is whether the agent locks while being polled.
+		locks bool
+	}{
+		{"status", func() error { _, err := AgentStatusInfo(); return err }, true},
+		{"missing secret", func() error { AgentGet("missing"); return nil }, true},
+		{"get", func() error { _, err := AgentGet("k"); return err }, false},
+		{"list", func() error { _, err := AgentList(); return err }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idle := 300 * time.Millisecond
+			done := serveTestAgent(t, idle)
+			t.Cleanup(func() { AgentLock(); <-done })
+			deadline := time.Now().Add(4 * idle)
+			for time.Now().Before(deadline) {
+				select {
+				case <-done:
+					if !tt.locks {
+						t.Fatal("agent locked while its secrets were in use")
+					}
+					return
+				default:
+				}
+				if err := tt.poll(); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(idle / 6)
+			}
+			if tt.locks {
+				t.Fatalf("agent still unlocked after %s of %s requests", 4*idle, tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package secrets
+
+import "syscall"
+
+// detachedProcAttr starts the agent in its own session so it outlives the
+// terminal that unlocked it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package secrets
+
+import "syscall"
+
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the agent without a console so it outlives the
+// terminal that unlocked it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess}
+}
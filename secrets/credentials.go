@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credential sources reported by ResolveGithubToken. Environment variables
+// are reported by name.
+const (
+	SourceFlag  = "--github-token flag"
+	SourceAgent = "secrets agent"
+	SourceGhCLI = "gh auth token"
+)
+
+var (
+	credMu         sync.Mutex
+	explicitToken  string
+	resolvedToken  string
+	resolvedSource string
+	resolved       bool
+)
+
+// SetExplicitGithubToken sets the token given on the command line, which
+// takes precedence over every other source.
+func SetExplicitGithubToken(token string) {
+	credMu.Lock()
+	defer credMu.Unlock()
+	explicitToken = token
+	resolved = false
+}
+
+// ResolveGithubToken returns the GitHub token used by every GitHub-backed
+// feature and where it came from: the --github-token flag, then
+// GITHUB_TOKEN or GH_TOKEN, then the unlocked secrets agent, then the
+// output of `gh auth token` when the gh CLI is installed. It returns empty
+// strings if no source has a token. The result is cached for the process.
+func ResolveGithubToken() (token, source string) {
+	credMu.Lock()
+	defer credMu.Unlock()
+	if resolved {
+		return resolvedToken, resolvedSource
+	}
+	resolvedToken, resolvedSource = resolveGithubToken()
+	resolved = true
+	return resolvedToken, resolvedSource
+}
+
+// GithubToken is ResolveGithubToken without the source.
+func GithubToken() string {
+	token, _ := ResolveGithubToken()
+	return token
+}
+
+func resolveGithubToken() (string, string) {
+	if explicitToken != "" {
+		return explicitToken, SourceFlag
+	}
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token, name + " environment variable"
+		}
+	}
+	if token := FromAgent(GithubTokenName); token != "" {
+		return token, SourceAgent
+	}
+	if token := ghAuthToken(); token != "" {
+		return token, SourceGhCLI
+	}
+	return "", ""
+}
+
+// OpenAIKey returns the API key for OpenAI-compatible services other than
+// GitHub Models: OPENAI_API_KEY, then the openai_api_key secret of the
+// unlocked secrets agent. It returns "" if neither has one.
+func OpenAIKey() string {
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		return key
+	}
+	return FromAgent(OpenAIKeyName)
+}
+
+// MCPCredential returns the credential of the MCP server called server,
+// the mcp.<server> secret of the unlocked secrets agent, or "" if it has
+// none.
+func MCPCredential(server string) string {
+	return FromAgent(MCPCredentialName(server))
+}
+
+// ghAuthToken asks the gh CLI for its token, if gh is installed and logged
+// in. The token is read from gh's stdout and never logged.
+func ghAuthToken() string {
+	path, err := exec.LookPath("gh")
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "auth", "token").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// MaskToken shows only enough of a token to tell tokens apart.
+func MaskToken(token string) string {
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+	return token[:4] + strings.Repeat("*", 8) + token[len(token)-4:]
+}
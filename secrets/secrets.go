@@ -1,22 +1,22 @@
 package secrets
 
 import (
+	"bufio"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/codeforge-ide/codeforgeai.go/config"
 	"golang.org/x/term"
 )
 
-// getSecretFile returns the path to the encrypted token file.
+// getSecretFile returns the path to the legacy encrypted token file.
 func getSecretFile() string {
 	return filepath.Join(config.DataDir(), "github_token.enc")
 }
@@ -29,87 +29,155 @@ func promptPassword(prompt string) (string, error) {
 	return string(pw), err
 }
 
-// deriveKey derives a 32-byte key from the password using SHA-256.
-func deriveKey(password string) []byte {
+// PromptSecret reads a secret value without echo when stdin is a terminal,
+// or a single line from stdin otherwise so values can be piped in.
+func PromptSecret(prompt string) (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return promptPassword(prompt)
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// legacyKey derives the key used by the pre-vault token file: a single
+// unsalted SHA-256 of the password. Only used to migrate old files.
+func legacyKey(password string) []byte {
 	hash := sha256.Sum256([]byte(password))
 	return hash[:]
 }
 
-// Encrypts and saves the token to disk.
-func StoreGithubToken(token string, password string) error {
-	key := deriveKey(password)
-	block, err := aes.NewCipher(key)
+// loadLegacyGithubToken decrypts the pre-vault github_token.enc file.
+func loadLegacyGithubToken(password string) (string, error) {
+	data, err := os.ReadFile(getSecretFile())
 	if err != nil {
-		return err
+		return "", err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(legacyKey(password))
+	if err != nil {
+		return "", err
 	}
 	aesgcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if len(ciphertext) < aesgcm.NonceSize() {
+		return "", errors.New("invalid ciphertext")
 	}
-	nonce := make([]byte, aesgcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	nonce := ciphertext[:aesgcm.NonceSize()]
+	enc := ciphertext[aesgcm.NonceSize():]
+	plaintext, err := aesgcm.Open(nil, nonce, enc, nil)
+	if err != nil {
+		return "", errors.New("invalid password or corrupted token")
+	}
+	return string(plaintext), nil
+}
+
+// Encrypts and saves the token in the vault.
+func StoreGithubToken(token string, password string) error {
+	v, err := OpenVault(password)
+	if err != nil {
 		return err
 	}
-	ciphertext := aesgcm.Seal(nonce, nonce, []byte(token), nil)
-	enc := base64.StdEncoding.EncodeToString(ciphertext)
-	secretFile := getSecretFile()
-	if err := os.MkdirAll(filepath.Dir(secretFile), 0700); err != nil {
+	if err := v.Set(GithubTokenName, token); err != nil {
 		return err
 	}
-	return os.WriteFile(secretFile, []byte(enc), 0600)
+	return v.Save()
 }
 
-// Loads and decrypts the token from disk.
+// Loads and decrypts the token from the vault.
 func LoadGithubToken(password string) (string, error) {
-	key := deriveKey(password)
-	secretFile := getSecretFile()
-	data, err := os.ReadFile(secretFile)
+	v, err := OpenVault(password)
 	if err != nil {
 		return "", err
 	}
-	ciphertext, err := base64.StdEncoding.DecodeString(string(data))
+	return v.Get(GithubTokenName)
+}
+
+// UnlockInteractive prompts for the vault password and opens the vault.
+// When no vault exists yet the password is asked for twice to create one.
+func UnlockInteractive() (*Vault, error) {
+	pw, err := PromptUnlockPassword()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	block, err := aes.NewCipher(key)
+	return OpenVault(pw)
+}
+
+// PromptUnlockPassword asks for the vault password, or for a new one
+// (confirmed) when no vault exists yet.
+func PromptUnlockPassword() (string, error) {
+	if VaultExists() {
+		return promptPassword("Enter your secrets vault password: ")
+	}
+	return promptNewPassword("Set a password for your secrets vault: ")
+}
+
+// promptNewPassword asks for a new password twice and checks they match.
+func promptNewPassword(prompt string) (string, error) {
+	pw, err := promptPassword(prompt)
 	if err != nil {
 		return "", err
 	}
-	aesgcm, err := cipher.NewGCM(block)
+	if pw == "" {
+		return "", errors.New("password cannot be empty")
+	}
+	confirm, err := promptPassword("Confirm password: ")
 	if err != nil {
 		return "", err
 	}
-	if len(ciphertext) < aesgcm.NonceSize() {
-		return "", errors.New("invalid ciphertext")
+	if pw != confirm {
+		return "", errors.New("passwords do not match")
 	}
-	nonce := ciphertext[:aesgcm.NonceSize()]
-	enc := ciphertext[aesgcm.NonceSize():]
-	plaintext, err := aesgcm.Open(nil, nonce, enc, nil)
+	return pw, nil
+}
+
+// InteractiveRotatePassword unlocks the vault and re-encrypts it under a
+// newly prompted password.
+func InteractiveRotatePassword() error {
+	if !VaultExists() {
+		return errors.New("no secrets vault found")
+	}
+	v, err := UnlockInteractive()
 	if err != nil {
-		return "", errors.New("invalid password or corrupted token")
+		return err
 	}
-	return string(plaintext), nil
+	pw, err := promptNewPassword("New vault password: ")
+	if err != nil {
+		return err
+	}
+	return v.RotatePassword(pw)
 }
 
-// Interactive helper: prompt for password and token, then store.
+// Interactive helper: unlock the vault, prompt for the token, then store.
 func InteractiveStoreGithubToken() error {
-	pw, err := promptPassword("Set a password for your GitHub token: ")
+	v, err := UnlockInteractive()
 	if err != nil {
 		return err
 	}
-	fmt.Print("Enter your GitHub token: ")
-	var token string
-	fmt.Scanln(&token)
-	return StoreGithubToken(token, pw)
+	token, err := PromptSecret("Enter your GitHub token: ")
+	if err != nil {
+		return err
+	}
+	if err := v.Set(GithubTokenName, strings.TrimSpace(token)); err != nil {
+		return err
+	}
+	return v.Save()
 }
 
 // Interactive helper: prompt for password, load token, and set env var.
 func InteractiveLoadGithubToken() (string, error) {
-	pw, err := promptPassword("Enter your GitHub token password: ")
+	v, err := UnlockInteractive()
 	if err != nil {
 		return "", err
 	}
-	token, err := LoadGithubToken(pw)
+	token, err := v.Get(GithubTokenName)
 	if err != nil {
 		return "", err
 	}
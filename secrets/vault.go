@@ -0,0 +1,286 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Well-known secret names used by the integrations.
+const (
+	GithubTokenName = "github_token"
+	OpenAIKeyName   = "openai_api_key"
+)
+
+const (
+	vaultVersion = 1
+	vaultKDF     = "scrypt"
+
+	// scrypt parameters recommended for interactive logins.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	saltSize = 16
+	keySize  = 32
+
+	// Bounds on the scrypt parameters accepted from a vault file, whose
+	// header is only authenticated once the key has been derived.
+	minScryptN = 1 << 14
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+// ErrNotFound is returned when a named secret is not in the vault.
+var ErrNotFound = errors.New("secret not found")
+
+// vaultHeader describes how the vault payload was encrypted. It is
+// authenticated together with the ciphertext so it cannot be tampered with.
+type vaultHeader struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+}
+
+// vaultFile is the on-disk representation of the vault.
+type vaultFile struct {
+	vaultHeader
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Vault holds named secrets decrypted in memory.
+type Vault struct {
+	path    string
+	header  vaultHeader
+	key     []byte
+	entries map[string]string
+}
+
+// getVaultFile returns the path to the encrypted vault.
+func getVaultFile() string {
+	return filepath.Join(config.DataDir(), "vault.enc")
+}
+
+// VaultExists reports whether a vault (or a legacy token file that will be
+// migrated into one) is present on disk.
+func VaultExists() bool {
+	if _, err := os.Stat(getVaultFile()); err == nil {
+		return true
+	}
+	_, err := os.Stat(getSecretFile())
+	return err == nil
+}
+
+// MCPCredentialName returns the vault name used for an MCP server credential.
+func MCPCredentialName(server string) string {
+	return "mcp." + server
+}
+
+// OpenVault decrypts the vault with password. A missing vault is created
+// empty, and a legacy github_token.enc file is migrated on first unlock.
+func OpenVault(password string) (*Vault, error) {
+	path := getVaultFile()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return migrateOrCreate(path, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vf vaultFile
+	if err := json.Unmarshal(data, &vf); err != nil {
+		return nil, fmt.Errorf("corrupted vault: %w", err)
+	}
+	if vf.Version != vaultVersion || vf.KDF != vaultKDF {
+		return nil, fmt.Errorf("unsupported vault format: version %d, kdf %q", vf.Version, vf.KDF)
+	}
+	if err := vf.vaultHeader.check(); err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(password), vf.Salt, vf.N, vf.R, vf.P, keySize)
+	if err != nil {
+		return nil, err
+	}
+	aad, err := json.Marshal(vf.vaultHeader)
+	if err != nil {
+		return nil, err
+	}
+	aesgcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aesgcm.Open(nil, vf.Nonce, vf.Ciphertext, aad)
+	if err != nil {
+		return nil, errors.New("invalid password or corrupted vault")
+	}
+	entries := map[string]string{}
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("corrupted vault: %w", err)
+	}
+	return &Vault{path: path, header: vf.vaultHeader, key: key, entries: entries}, nil
+}
+
+// migrateOrCreate builds a new vault, importing the legacy GitHub token file
+// when one exists.
+func migrateOrCreate(path, password string) (*Vault, error) {
+	v, err := newVault(path, password)
+	if err != nil {
+		return nil, err
+	}
+	legacy := getSecretFile()
+	if _, err := os.Stat(legacy); err != nil {
+		return v, nil
+	}
+	token, err := loadLegacyGithubToken(password)
+	if err != nil {
+		return nil, err
+	}
+	v.entries[GithubTokenName] = token
+	if err := v.Save(); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(legacy); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "Migrated legacy GitHub token file into the secrets vault.")
+	return v, nil
+}
+
+func newVault(path, password string) (*Vault, error) {
+	header := vaultHeader{Version: vaultVersion, KDF: vaultKDF, N: scryptN, R: scryptR, P: scryptP}
+	key, err := deriveVaultKey(password, &header)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{path: path, header: header, key: key, entries: map[string]string{}}, nil
+}
+
+// check rejects key derivation settings that are too weak, or so costly
+// that deriving the key would exhaust the machine.
+func (h vaultHeader) check() error {
+	if h.N < minScryptN || h.N > maxScryptN || h.N&(h.N-1) != 0 ||
+		h.R < 1 || h.R > maxScryptR || h.P < 1 || h.P > maxScryptP || len(h.Salt) < saltSize {
+		return fmt.Errorf("corrupted vault: unsupported scrypt parameters N=%d r=%d p=%d", h.N, h.R, h.P)
+	}
+	return nil
+}
+
+// deriveVaultKey picks a fresh salt for header and derives the key from it.
+func deriveVaultKey(password string, header *vaultHeader) ([]byte, error) {
+	header.Salt = make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, header.Salt); err != nil {
+		return nil, err
+	}
+	if err := header.check(); err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(password), header.Salt, header.N, header.R, header.P, keySize)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Save encrypts the vault and writes it atomically to disk.
+func (v *Vault) Save() error {
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+	aad, err := json.Marshal(v.header)
+	if err != nil {
+		return err
+	}
+	aesgcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aesgcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(vaultFile{
+		vaultHeader: v.header,
+		Nonce:       nonce,
+		Ciphertext:  aesgcm.Seal(nil, nonce, plaintext, aad),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(v.path, data, 0600)
+}
+
+// Get returns the named secret.
+func (v *Vault) Get(name string) (string, error) {
+	value, ok := v.entries[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Set stores a named secret in memory; call Save to persist it.
+func (v *Vault) Set(name, value string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("secret name cannot be empty")
+	}
+	v.entries[name] = value
+	return nil
+}
+
+// Delete removes a named secret; call Save to persist the change.
+func (v *Vault) Delete(name string) error {
+	if _, ok := v.entries[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(v.entries, name)
+	return nil
+}
+
+// List returns the sorted names of all stored secrets.
+func (v *Vault) List() []string {
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RotatePassword re-encrypts the vault under a new password and salt.
+func (v *Vault) RotatePassword(newPassword string) error {
+	header := v.header
+	key, err := deriveVaultKey(newPassword, &header)
+	if err != nil {
+		return err
+	}
+	v.header = header
+	v.key = key
+	return v.Save()
+}
@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestVaultConcurrentSave(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v, err := OpenVault("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Vaults saved at once, as by the agent and the CLI, each leave a
+	// whole file behind.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		w := &Vault{path: v.path, header: v.header, key: v.key, entries: map[string]string{"n": strconv.Itoa(i)}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Save(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := OpenVault("pw"); err != nil {
+		t.Fatalf("vault unreadable after concurrent saves: %v", err)
+	}
+}
+
+func TestVaultRejectsScryptParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		n, r, p int
+	}{
+		{"huge N", 1 << 30, 8, 1},
+		{"weak N", 1 << 4, 8, 1},
+		{"N not a power of two", 1<<15 + 1, 8, 1},
+		{"zero r", 1 << 15, 0, 1},
+		{"huge r", 1 << 15, 1 << 20, 1},
+		{"huge p", 1 << 15, 8, 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			v, err := OpenVault("pw")
+			if err != nil {
+				t.Fatal(err)
+			}
+			v.header.N, v.header.R, v.header.P = tt.n, tt.r, tt.p
+			if err := v.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := OpenVault("pw"); err == nil || !strings.Contains(err.Error(), "unsupported scrypt parameters") {
+				t.Fatalf("OpenVault = %v, want the parameters rejected", err)
+			}
+		})
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v, err := OpenVault("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set(MCPCredentialName("github"), "tok"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set(" ", "x"); err == nil {
+		t.Fatal("Set accepted an empty name")
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenVault("wrong"); err == nil {
+		t.Fatal("OpenVault accepted a wrong password")
+	}
+	v, err = OpenVault("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Get("mcp.github"); err != nil || got != "tok" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if _, err := v.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing secret = %v", err)
+	}
+
+	if err := v.RotatePassword("new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenVault("pw"); err == nil {
+		t.Fatal("the old password still opens the vault")
+	}
+	if v, err = OpenVault("new"); err != nil || len(v.List()) != 1 {
+		t.Fatalf("after rotating: %v, %v", v, err)
+	}
+}
+
+// writeLegacyToken writes token as the pre-vault github_token.enc file
+// did: AES-GCM under the SHA-256 of the password, base64 encoded.
+func writeLegacyToken(t *testing.T, token, password string) {
+	aesgcm, err := newGCM(legacyKey(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aesgcm.NonceSize())
+	sealed := aesgcm.Seal(nonce, nonce, []byte(token), nil)
+	if err := os.WriteFile(getSecretFile(), []byte(base64.StdEncoding.EncodeToString(sealed)), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVaultMigratesLegacyToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeLegacyToken(t, "ghp_legacy", "pw")
+	if !VaultExists() {
+		t.Fatal("VaultExists = false with a legacy token file")
+	}
+	if _, err := OpenVault("wrong"); err == nil {
+		t.Fatal("migration accepted a wrong password")
+	}
+	if _, err := os.Stat(getSecretFile()); err != nil {
+		t.Fatal("legacy file removed after a failed migration")
+	}
+
+	v, err := OpenVault("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Get(GithubTokenName); err != nil || got != "ghp_legacy" {
+		t.Fatalf("migrated token = %q, %v", got, err)
+	}
+	if _, err := os.Stat(getSecretFile()); !os.IsNotExist(err) {
+		t.Fatal("legacy file kept after migration")
+	}
+	// The token now lives in the saved vault.
+	if v, err = OpenVault("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := v.Get(GithubTokenName); got != "ghp_legacy" {
+		t.Fatalf("token after reopening = %q", got)
+	}
+}
@@ -0,0 +1,286 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+	"github.com/codeforge-ide/codeforgeai.go/utils"
+)
+
+// Record aggregates the calls sharing a day, project, operation, provider
+// and model.
+type Record struct {
+	Day              string  `json:"day"`
+	Project          string  `json:"project"`
+	Operation        string  `json:"operation"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (r Record) key() string {
+	return strings.Join([]string{r.Day, r.Project, r.Operation, r.Provider, r.Model}, "\x00")
+}
+
+// Totals sums a set of records.
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add accumulates r into t.
+func (t *Totals) Add(r Record) {
+	t.Requests += r.Requests
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.Cost += r.Cost
+}
+
+// BudgetError describes an exhausted budget; it is returned as an error
+// when the budget blocks further calls.
+type BudgetError struct {
+	Scope  string
+	Period string
+	Limit  float64
+	Spent  float64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s %s budget of $%.2f exhausted ($%.4f spent); raise it in the config or wait for the next period", e.Scope, e.Period, e.Limit, e.Spent)
+}
+
+const dayFormat = "2006-01-02"
+
+// mu and the lock file serialize updates of the ledger within this process
+// and across processes (the CLI, the daemon, lsp and serve).
+var mu sync.Mutex
+
+// File returns the path of the usage ledger.
+func File() string {
+	return filepath.Join(config.DataDir(), "usage.json")
+}
+
+// Load returns every record in the ledger.
+func Load() ([]Record, error) {
+	data, err := os.ReadFile(File())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func save(records []Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(File(), data, 0600)
+}
+
+// lock takes the ledger lock of every process.
+func lock() (unlock func(), err error) {
+	mu.Lock()
+	unlockFile, err := utils.LockFile(File() + ".lock")
+	if err != nil {
+		mu.Unlock()
+		return nil, fmt.Errorf("locking usage ledger: %w", err)
+	}
+	return func() {
+		unlockFile()
+		mu.Unlock()
+	}, nil
+}
+
+// PriceFor looks up the price of model, preferring a "provider/model" entry
+// over a bare model name. Unknown models are free.
+func PriceFor(cfg *config.Config, provider, model string) (config.Price, bool) {
+	if p, ok := cfg.Pricing[provider+"/"+model]; ok {
+		return p, true
+	}
+	p, ok := cfg.Pricing[model]
+	return p, ok
+}
+
+// Cost prices u for the given model.
+func Cost(cfg *config.Config, provider, model string, u modeliface.Usage) float64 {
+	p, _ := PriceFor(cfg, provider, model)
+	return (float64(u.PromptTokens)*p.Prompt + float64(u.CompletionTokens)*p.Completion) / 1e6
+}
+
+// Add records one call against project and returns the updated ledger.
+func Add(cfg *config.Config, project, operation, provider, model string, u modeliface.Usage) ([]Record, error) {
+	unlock, err := lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	records, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	r := Record{
+		Day:              time.Now().Format(dayFormat),
+		Project:          project,
+		Operation:        operation,
+		Provider:         provider,
+		Model:            model,
+		Requests:         1,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Cost:             Cost(cfg, provider, model, u),
+	}
+	found := false
+	for i := range records {
+		if records[i].key() == r.key() {
+			records[i].Requests++
+			records[i].PromptTokens += r.PromptTokens
+			records[i].CompletionTokens += r.CompletionTokens
+			records[i].Cost += r.Cost
+			found = true
+			break
+		}
+	}
+	if !found {
+		records = append(records, r)
+	}
+	return records, save(records)
+}
+
+// Reset deletes the ledger.
+func Reset() error {
+	unlock, err := lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	err = os.Remove(File())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Spent returns the cost recorded today and this month, limited to project
+// unless it is empty.
+func Spent(records []Record, project string, now time.Time) (day, month float64) {
+	today := now.Format(dayFormat)
+	thisMonth := now.Format("2006-01")
+	for _, r := range records {
+		if project != "" && r.Project != project {
+			continue
+		}
+		if r.Day == today {
+			day += r.Cost
+		}
+		if strings.HasPrefix(r.Day, thisMonth) {
+			month += r.Cost
+		}
+	}
+	return day, month
+}
+
+// budgetScope is a budget and the records it applies to.
+type budgetScope struct {
+	name    string
+	project string
+	budget  config.BudgetConfig
+}
+
+func scopes(cfg *config.Config, project string) []budgetScope {
+	out := []budgetScope{{name: "global", budget: cfg.Budget}}
+	if pc, _, err := config.LoadProjectConfig(); err == nil && pc.Budget != nil {
+		b := *pc.Budget
+		if b.Action == "" {
+			b.Action = cfg.Budget.Action
+		}
+		out = append(out, budgetScope{name: "project", project: project, budget: b})
+	}
+	return out
+}
+
+// Check returns every exhausted budget for project, and the first one
+// whose action is "block", if any.
+func Check(cfg *config.Config, records []Record, project string) (exceeded []*BudgetError, blocking *BudgetError) {
+	now := time.Now()
+	for _, s := range scopes(cfg, project) {
+		day, month := Spent(records, s.project, now)
+		check := func(period string, limit, spent float64) {
+			if limit <= 0 || spent < limit {
+				return
+			}
+			e := &BudgetError{Scope: s.name, Period: period, Limit: limit, Spent: spent}
+			exceeded = append(exceeded, e)
+			if s.budget.Action == "block" && blocking == nil {
+				blocking = e
+			}
+		}
+		check("daily", s.budget.Daily, day)
+		check("monthly", s.budget.Monthly, month)
+	}
+	return exceeded, blocking
+}
+
+// Group sums records by the named dimension: day, month, project,
+// operation, provider or model. Keys are returned sorted. Records whose
+// day cannot be parsed are left out of the day and month groups.
+func Group(records []Record, by string) (map[string]Totals, []string, error) {
+	groups := map[string]Totals{}
+	for _, r := range records {
+		var k string
+		switch by {
+		case "day", "month":
+			// Records of a hand-edited ledger may have no usable day.
+			d, err := time.Parse(dayFormat, r.Day)
+			if err != nil {
+				continue
+			}
+			k = d.Format(dayFormat)
+			if by == "month" {
+				k = d.Format("2006-01")
+			}
+		case "project":
+			k = r.Project
+		case "operation":
+			k = r.Operation
+		case "provider":
+			k = r.Provider
+		case "model":
+			k = r.Provider + "/" + r.Model
+		default:
+			return nil, nil, fmt.Errorf("cannot group usage by %q", by)
+		}
+		if k == "" {
+			k = "(none)"
+		}
+		t := groups[k]
+		t.Add(r)
+		groups[k] = t
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return groups, keys, nil
+}
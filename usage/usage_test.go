@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/codeforge-ide/codeforgeai.go/config"
+	"github.com/codeforge-ide/codeforgeai.go/modeliface"
+)
+
+// TestMain lets the test binary act as one of several processes writing to
+// the ledger at once.
+func TestMain(m *testing.M) {
+	if n, err := strconv.Atoi(os.Getenv("USAGE_TEST_WRITER")); err == nil {
+		cfg := config.Config{}
+		for i := 0; i < n; i++ {
+			if _, err := Add(&cfg, "/p", "op", "ollama", "m", modeliface.Usage{PromptTokens: 1}); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestAddConcurrentProcesses(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const writers, each = 4, 25
+	cmds := make([]*exec.Cmd, writers)
+	for i := range cmds {
+		cmds[i] = exec.Command(os.Args[0], "-test.run=^$")
+		cmds[i].Env = append(os.Environ(), "USAGE_TEST_WRITER="+strconv.Itoa(each))
+		cmds[i].Stderr = os.Stderr
+		if err := cmds[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("writer failed: %v", err)
+		}
+	}
+	records, err := Load()
+	if err != nil {
+		t.Fatalf("ledger is corrupt: %v", err)
+	}
+	if len(records) != 1 || records[0].Requests != writers*each || records[0].PromptTokens != writers*each {
+		t.Fatalf("records = %+v, want one record of %d requests", records, writers*each)
+	}
+}
+
+func TestGroupByDate(t *testing.T) {
+	records := []Record{
+		{Day: "2026-09-30", Requests: 1},
+		{Day: "2026-10-01", Requests: 2},
+		{Day: "2026-10-02", Requests: 4},
+		{Day: "2026-10", Requests: 8},
+		{Day: "", Requests: 16},
+	}
+	tests := []struct {
+		by   string
+		want map[string]int
+	}{
+		{"day", map[string]int{"2026-09-30": 1, "2026-10-01": 2, "2026-10-02": 4}},
+		{"month", map[string]int{"2026-09": 1, "2026-10": 6}},
+	}
+	for _, tt := range tests {
+		groups, keys, err := Group(records, tt.by)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("Group by %s keys = %q, want %d", tt.by, keys, len(tt.want))
+		}
+		for k, n := range tt.want {
+			if groups[k].Requests != n {
+				t.Errorf("Group by %s [%s] = %d requests, want %d", tt.by, k, groups[k].Requests, n)
+			}
+		}
+	}
+}